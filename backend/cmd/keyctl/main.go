@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/database"
+	"user-service/internal/secrets"
+	"user-service/internal/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: keyctl [-grace 1h] <command> [args]
+
+Commands:
+  list                 show signing keys and recent rotation events
+  generate [algorithm] create a pending key (HS256, RS256, ES256, EdDSA; default RS256)
+  promote <kid>        make the key the current signing key; the previous one retires after -grace
+  retire <kid>         stop accepting tokens signed with a pending or retiring key
+`
+
+func main() {
+	defaultGrace := os.Getenv("JWT_KEY_GRACE_PERIOD")
+	if defaultGrace == "" {
+		defaultGrace = "1h"
+	}
+	graceFlag := flag.String("grace", defaultGrace, "how long tokens signed with the previous key stay valid after promote")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	grace, err := time.ParseDuration(*graceFlag)
+	if err != nil {
+		log.Fatalf("invalid -grace: %v", err)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.ConnectFromEnv()
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+	defer db.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	svc := services.NewKeyRotationService(database.NewSigningKeyRepo(db), nil, grace, logger)
+
+	// Новые ключи шифруются тем же TOKEN_ENCRYPTION_KEY, что и у сервера
+	encryptionKey, previousKeys, err := config.LoadEncryptionKeys()
+	if err != nil {
+		log.Fatalf("invalid encryption key: %v", err)
+	}
+	if encryptionKey != nil {
+		cipher, err := secrets.NewCipher(encryptionKey, previousKeys...)
+		if err != nil {
+			log.Fatalf("invalid TOKEN_ENCRYPTION_KEY: %v", err)
+		}
+		svc.WithCipher(cipher)
+	}
+
+	actor := "cli"
+	if u, err := user.Current(); err == nil {
+		actor = "cli:" + u.Username
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "list":
+		keys, err := svc.ListKeys()
+		if err != nil {
+			log.Fatalf("failed to list keys: %v", err)
+		}
+		for _, k := range keys {
+			fmt.Printf("%-45s %-6s %-9s created=%s\n", k.ID, k.Algorithm, k.Status, k.CreatedAt.Format(time.RFC3339))
+		}
+		events, err := svc.ListEvents(20)
+		if err != nil {
+			log.Fatalf("failed to list events: %v", err)
+		}
+		fmt.Println()
+		for _, e := range events {
+			fmt.Printf("%s %-10s %s by %s %s\n", e.CreatedAt.Format(time.RFC3339), e.Event, e.KeyID, e.Actor, e.Details)
+		}
+	case "generate":
+		algorithm := "RS256"
+		if flag.NArg() > 1 {
+			algorithm = flag.Arg(1)
+		}
+		key, err := svc.GenerateKey(algorithm, actor)
+		if err != nil {
+			log.Fatalf("failed to generate key: %v", err)
+		}
+		fmt.Printf("generated %s key %s (pending)\n", key.Algorithm, key.ID)
+	case "promote", "retire":
+		if flag.NArg() < 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		kid := flag.Arg(1)
+		if cmd == "promote" {
+			err = svc.PromoteKey(kid, actor)
+		} else {
+			err = svc.RetireKey(kid, actor)
+		}
+		if err != nil {
+			log.Fatalf("failed to %s key: %v", cmd, err)
+		}
+		fmt.Printf("%sd key %s\n", cmd, kid)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -1,22 +1,89 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DiscordClientID     string
-	DiscordClientSecret string
-	DiscordRedirectURI  string
-	FrontendURL         string
-	JWTSecret           string
-	ServerPort          string
-	Environment         string
-	AdminDiscordIDs     []string
+	Discord         ProviderConfig
+	GitHub          ProviderConfig
+	Google          ProviderConfig
+	OIDC            ProviderConfig
+	FrontendURL     string
+	JWTSecret       string
+	ServerPort      string
+	Environment     string
+	AdminDiscordIDs []string
+
+	// Вход только для участников серверов Discord (любого из списка); роли сервера
+	// сопоставляются ролям приложения, первое совпадение по порядку побеждает
+	DiscordGuildIDs   []string
+	DiscordGuildRoles []GuildRoleMapping
+	// Токен бота на этих серверах: членство перепроверяется при /refresh без участия пользователя
+	DiscordBotToken string
+	// Как часто фоновая задача обновляет профиль и роли по сохраненным токенам Discord; 0 — не обновлять
+	DiscordSyncInterval time.Duration
+
+	// TokenEncryptionKey — мастер-ключ AES-256 для секретов в БД (токены внешних провайдеров,
+	// секреты TOTP). Без него токены провайдеров не сохраняются, а секреты TOTP хранятся открыто.
+	// Прежние ключи после ротации нужны только для чтения еще не перезаписанных значений
+	TokenEncryptionKey          []byte
+	TokenEncryptionPreviousKeys [][]byte
+
+	// Куда POST-ом отправлять уведомления о входе с нового устройства или из новой сети;
+	// секрет подписывает тело (X-Webhook-Signature). Без URL уведомления видны только в /me/login-alerts
+	LoginAlertWebhookURL    string
+	LoginAlertWebhookSecret string
+
+	// PublicURL — внешний адрес сервиса; он же issuer, когда мы выступаем OIDC-провайдером
+	PublicURL string
+
+	// MFAIssuer — название сервиса в приложении-аутентификаторе
+	MFAIssuer string
+
+	// Passkey (WebAuthn): RP ID — домен фронтенда, origin — откуда браузер вызывает WebAuthn
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	// JWTAlgorithm — HS256 (общий JWT_SECRET) или RS256/ES256/EdDSA (ключ из JWT_PRIVATE_KEY_PATH)
+	JWTAlgorithm      string
+	JWTPrivateKeyPath string
+	JWTKeyID          string
+	// Сколько после ротации принимаются токены, подписанные прежним ключом
+	JWTKeyGracePeriod time.Duration
+
+	// Доставка refresh token: "query" (в URL редиректа) или "cookie" (HttpOnly cookie + CSRF)
+	RefreshTokenDelivery string
+	CookieDomain         string
+	CookiePath           string
+	CookieSecure         bool
+	CookieSameSite       http.SameSite
+}
+
+// GuildRoleMapping — роль на сервере Discord и соответствующая ей роль приложения
+type GuildRoleMapping struct {
+	DiscordRoleID string
+	Role          string
+}
+
+// ProviderConfig — настройки одного внешнего провайдера входа
+type ProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+	IssuerURL    string
 }
 
 func Load() (*Config, error) {
@@ -24,32 +91,110 @@ func Load() (*Config, error) {
 	godotenv.Load("config.env")
 
 	cfg := &Config{
-		DiscordClientID:     getEnv("DISCORD_CLIENT_ID", ""),
-		DiscordClientSecret: getEnv("DISCORD_CLIENT_SECRET", ""),
-		DiscordRedirectURI:  getEnv("DISCORD_REDIRECT_URI", "http://localhost:8080/callback"),
-		FrontendURL:         getEnv("FRONTEND_URL", "http://localhost:3000"),
-		JWTSecret:           getEnv("JWT_SECRET", "default-secret-key"),
-		ServerPort:          getEnv("SERVER_PORT", "8080"),
-		Environment:         getEnv("ENVIRONMENT", "development"),
-	}
-
-	if ids := getEnv("ADMIN_DISCORD_IDS", ""); ids != "" {
-		// Split by comma and trim spaces
-		parts := strings.Split(ids, ",")
-		cleaned := make([]string, 0, len(parts))
-		for _, p := range parts {
-			v := strings.TrimSpace(p)
-			if v != "" {
-				cleaned = append(cleaned, v)
-			}
+		Discord:     loadProvider("DISCORD", "discord", "http://localhost:8080/callback"),
+		GitHub:      loadProvider("GITHUB", "github", "http://localhost:8080/callback/github"),
+		Google:      loadProvider("GOOGLE", "google", "http://localhost:8080/callback/google"),
+		OIDC:        loadProvider("OIDC", getEnv("OIDC_PROVIDER_NAME", "oidc"), "http://localhost:8080/callback/"+getEnv("OIDC_PROVIDER_NAME", "oidc")),
+		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+		JWTSecret:   getEnv("JWT_SECRET", "default-secret-key"),
+		ServerPort:  getEnv("SERVER_PORT", "8080"),
+		Environment: getEnv("ENVIRONMENT", "development"),
+	}
+
+	cfg.AdminDiscordIDs = splitList(getEnv("ADMIN_DISCORD_IDS", ""), ",")
+	cfg.DiscordGuildIDs = splitList(getEnv("DISCORD_GUILD_IDS", ""), ",")
+	cfg.DiscordBotToken = getEnv("DISCORD_BOT_TOKEN", "")
+	for _, pair := range splitList(getEnv("DISCORD_GUILD_ROLES", ""), ",") {
+		roleID, role, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(roleID) == "" || strings.TrimSpace(role) == "" {
+			return nil, fmt.Errorf("DISCORD_GUILD_ROLES must be a list of discord_role_id=app_role")
+		}
+		cfg.DiscordGuildRoles = append(cfg.DiscordGuildRoles, GuildRoleMapping{DiscordRoleID: strings.TrimSpace(roleID), Role: strings.TrimSpace(role)})
+	}
+	if len(cfg.DiscordGuildRoles) > 0 && len(cfg.DiscordGuildIDs) == 0 {
+		return nil, fmt.Errorf("DISCORD_GUILD_ROLES requires DISCORD_GUILD_IDS")
+	}
+	// Без бота членство нельзя перепроверить при /refresh и входе по passkey
+	if len(cfg.DiscordGuildIDs) > 0 && cfg.DiscordBotToken == "" {
+		return nil, fmt.Errorf("DISCORD_GUILD_IDS requires DISCORD_BOT_TOKEN")
+	}
+	// Без этого scope Discord не отдаст участника сервера по токену пользователя
+	if len(cfg.DiscordGuildIDs) > 0 {
+		if len(cfg.Discord.Scopes) == 0 {
+			cfg.Discord.Scopes = []string{"identify"}
+		}
+		hasScope := false
+		for _, scope := range cfg.Discord.Scopes {
+			hasScope = hasScope || scope == "guilds.members.read"
+		}
+		if !hasScope {
+			cfg.Discord.Scopes = append(cfg.Discord.Scopes, "guilds.members.read")
 		}
-		cfg.AdminDiscordIDs = cleaned
-	} else {
-		cfg.AdminDiscordIDs = []string{}
+	}
+	syncInterval, err := time.ParseDuration(getEnv("DISCORD_SYNC_INTERVAL", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DISCORD_SYNC_INTERVAL: %w", err)
+	}
+	cfg.DiscordSyncInterval = syncInterval
+	if cfg.TokenEncryptionKey, cfg.TokenEncryptionPreviousKeys, err = LoadEncryptionKeys(); err != nil {
+		return nil, err
+	}
+
+	cfg.LoginAlertWebhookURL = getEnv("LOGIN_ALERT_WEBHOOK_URL", "")
+	cfg.LoginAlertWebhookSecret = getEnv("LOGIN_ALERT_WEBHOOK_SECRET", "")
+	if cfg.LoginAlertWebhookURL != "" {
+		if u, err := url.Parse(cfg.LoginAlertWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("LOGIN_ALERT_WEBHOOK_URL must be an http(s) URL")
+		}
+	}
+
+	cfg.PublicURL = strings.TrimRight(getEnv("PUBLIC_URL", "http://localhost:8080"), "/")
+	cfg.MFAIssuer = getEnv("MFA_ISSUER", "SF5RP")
+
+	frontend, err := url.Parse(cfg.FrontendURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FRONTEND_URL: %w", err)
+	}
+	cfg.WebAuthnRPID = getEnv("WEBAUTHN_RP_ID", frontend.Hostname())
+	cfg.WebAuthnRPName = getEnv("WEBAUTHN_RP_NAME", cfg.MFAIssuer)
+	cfg.WebAuthnOrigins = splitList(getEnv("WEBAUTHN_ORIGINS", strings.TrimRight(cfg.FrontendURL, "/")), ",")
+
+	cfg.JWTAlgorithm = getEnv("JWT_ALGORITHM", "HS256")
+	cfg.JWTPrivateKeyPath = getEnv("JWT_PRIVATE_KEY_PATH", "")
+	cfg.JWTKeyID = getEnv("JWT_KEY_ID", "")
+	switch cfg.JWTAlgorithm {
+	case "HS256", "RS256", "ES256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("JWT_ALGORITHM must be one of HS256, RS256, ES256, EdDSA")
+	}
+	grace, err := time.ParseDuration(getEnv("JWT_KEY_GRACE_PERIOD", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_KEY_GRACE_PERIOD: %w", err)
+	}
+	cfg.JWTKeyGracePeriod = grace
+
+	cfg.RefreshTokenDelivery = getEnv("REFRESH_TOKEN_DELIVERY", "query")
+	if cfg.RefreshTokenDelivery != "query" && cfg.RefreshTokenDelivery != "cookie" {
+		return nil, fmt.Errorf("REFRESH_TOKEN_DELIVERY must be query or cookie")
+	}
+	cfg.CookieDomain = getEnv("COOKIE_DOMAIN", "")
+	cfg.CookiePath = getEnv("COOKIE_PATH", "/")
+	cfg.CookieSecure = getEnv("COOKIE_SECURE", strconv.FormatBool(cfg.Environment == "production")) == "true"
+	switch strings.ToLower(getEnv("COOKIE_SAMESITE", "lax")) {
+	case "lax":
+		cfg.CookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		cfg.CookieSameSite = http.SameSiteStrictMode
+	case "none":
+		// Браузеры принимают SameSite=None только вместе с Secure
+		cfg.CookieSameSite = http.SameSiteNoneMode
+		cfg.CookieSecure = true
+	default:
+		return nil, fmt.Errorf("COOKIE_SAMESITE must be lax, strict or none")
 	}
 
 	// Валидация обязательных переменных окружения
-	if cfg.DiscordClientID == "" || cfg.DiscordClientSecret == "" || cfg.DiscordRedirectURI == "" {
+	if cfg.Discord.ClientID == "" || cfg.Discord.ClientSecret == "" || cfg.Discord.RedirectURI == "" {
 		return nil, fmt.Errorf("missing required env vars: DISCORD_CLIENT_ID, DISCORD_CLIENT_SECRET, DISCORD_REDIRECT_URI")
 	}
 	if cfg.JWTSecret == "default-secret-key" {
@@ -60,9 +205,67 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// loadProvider читает блок <PREFIX>_CLIENT_ID, <PREFIX>_CLIENT_SECRET, <PREFIX>_REDIRECT_URI, <PREFIX>_SCOPES, <PREFIX>_ISSUER_URL
+func loadProvider(prefix, name, defaultRedirectURI string) ProviderConfig {
+	return ProviderConfig{
+		Name:         name,
+		ClientID:     getEnv(prefix+"_CLIENT_ID", ""),
+		ClientSecret: getEnv(prefix+"_CLIENT_SECRET", ""),
+		RedirectURI:  getEnv(prefix+"_REDIRECT_URI", defaultRedirectURI),
+		Scopes:       splitList(getEnv(prefix+"_SCOPES", ""), " "),
+		IssuerURL:    getEnv(prefix+"_ISSUER_URL", ""),
+	}
+}
+
+// LoadEncryptionKeys читает TOKEN_ENCRYPTION_KEY и TOKEN_ENCRYPTION_PREVIOUS_KEYS; нужен и утилитам
+// (keyctl), которым не нужна остальная конфигурация. Без ключа возвращает nil.
+func LoadEncryptionKeys() ([]byte, [][]byte, error) {
+	var key []byte
+	if encoded := getEnv("TOKEN_ENCRYPTION_KEY", ""); encoded != "" {
+		var err error
+		if key, err = decodeKey("TOKEN_ENCRYPTION_KEY", encoded); err != nil {
+			return nil, nil, err
+		}
+	}
+	var previous [][]byte
+	for _, encoded := range splitList(getEnv("TOKEN_ENCRYPTION_PREVIOUS_KEYS", ""), ",") {
+		k, err := decodeKey("TOKEN_ENCRYPTION_PREVIOUS_KEYS", encoded)
+		if err != nil {
+			return nil, nil, err
+		}
+		previous = append(previous, k)
+	}
+	if len(previous) > 0 && key == nil {
+		return nil, nil, fmt.Errorf("TOKEN_ENCRYPTION_PREVIOUS_KEYS requires TOKEN_ENCRYPTION_KEY")
+	}
+	return key, previous, nil
+}
+
+// decodeKey читает ключ шифрования: 32 байта в base64
+func decodeKey(name, encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes in base64 (openssl rand -base64 32)", name)
+	}
+	return key, nil
+}
+
+// splitList разбивает строку по разделителю и выкидывает пустые элементы
+func splitList(value, sep string) []string {
+	parts := strings.Split(value, sep)
+	cleaned := make([]string, 0, len(parts))
+	for _, p := range parts {
+		v := strings.TrimSpace(p)
+		if v != "" {
+			cleaned = append(cleaned, v)
+		}
+	}
+	return cleaned
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
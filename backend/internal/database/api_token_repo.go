@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"user-service/internal/models"
+)
+
+type APITokenRepo struct {
+	db *DB
+}
+
+func NewAPITokenRepo(db *DB) *APITokenRepo { return &APITokenRepo{db: db} }
+
+const apiTokenColumns = `id, user_id, name, token_hash, prefix, scopes, expires_at, last_used_at, last_used_ip, created_at, revoked_at`
+
+func scanAPIToken(row interface{ Scan(...any) error }) (*models.APIToken, error) {
+	var t models.APIToken
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Prefix, &scopes, &expiresAt, &lastUsedAt, &t.LastUsedIP, &t.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	t.ExpiresAt = nullTime(expiresAt)
+	t.LastUsedAt = nullTime(lastUsedAt)
+	t.RevokedAt = nullTime(revokedAt)
+	return &t, nil
+}
+
+func (r *APITokenRepo) Create(t *models.APIToken) error {
+	var expiresAt sql.NullTime
+	if t.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *t.ExpiresAt, Valid: true}
+	}
+	return r.db.SQL.QueryRow(`
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, t.UserID, t.Name, t.TokenHash, t.Prefix, strings.Join(t.Scopes, " "), expiresAt).Scan(&t.ID, &t.CreatedAt)
+}
+
+func (r *APITokenRepo) FindByHash(tokenHash string) (*models.APIToken, error) {
+	t, err := scanAPIToken(r.db.SQL.QueryRow(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash=$1`, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// FindByUserID возвращает неотозванные ключи пользователя, включая истекшие
+func (r *APITokenRepo) FindByUserID(userID uint) ([]models.APIToken, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT `+apiTokenColumns+` FROM api_tokens
+		WHERE user_id=$1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke отзывает ключ, только если он принадлежит пользователю
+func (r *APITokenRepo) Revoke(userID, id uint) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		UPDATE api_tokens SET revoked_at=NOW()
+		WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// TouchLastUsed обновляет время использования не чаще раза в минуту, чтобы не писать в БД на каждый запрос
+func (r *APITokenRepo) TouchLastUsed(id uint, ip string) error {
+	_, err := r.db.SQL.Exec(`
+		UPDATE api_tokens SET last_used_at=NOW(), last_used_ip=$2
+		WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip<>$2)
+	`, id, ip)
+	return err
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"user-service/internal/models"
+)
+
+type AuditRepo struct {
+	db *DB
+}
+
+func NewAuditRepo(db *DB) *AuditRepo { return &AuditRepo{db: db} }
+
+func (r *AuditRepo) Create(e *models.AuditEvent) error {
+	return r.db.SQL.QueryRow(`
+		INSERT INTO audit_events (actor, action, target, before, after, ip, user_agent, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, e.Actor, e.Action, e.Target, nullJSON(e.Before), nullJSON(e.After), e.IP, e.UserAgent, e.RequestID).Scan(&e.ID, &e.CreatedAt)
+}
+
+// Find возвращает события по фильтру, новые первыми
+func (r *AuditRepo) Find(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var where []string
+	var args []any
+	add := func(condition string, value any) {
+		args = append(args, value)
+		where = append(where, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.Actor != "" {
+		add("actor = ?", filter.Actor)
+	}
+	if filter.Target != "" {
+		add("target = ?", filter.Target)
+	}
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		add("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < ?", *filter.To)
+	}
+	if filter.BeforeID > 0 {
+		add("id < ?", filter.BeforeID)
+	}
+
+	query := `SELECT id, actor, action, target, before, after, ip, user_agent, request_id, created_at FROM audit_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := r.db.SQL.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var e models.AuditEvent
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &before, &after, &e.IP, &e.UserAgent, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Before = json.RawMessage(before)
+		e.After = json.RawMessage(after)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// nullJSON — NULL вместо пустого значения для колонки JSONB
+func nullJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
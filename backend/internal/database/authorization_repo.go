@@ -0,0 +1,89 @@
+package database
+
+import (
+	"database/sql"
+
+	"user-service/internal/models"
+)
+
+// AuthorizationRepo хранит запросы /authorize и выданные по ним authorization code
+type AuthorizationRepo struct {
+	db *DB
+}
+
+func NewAuthorizationRepo(db *DB) *AuthorizationRepo { return &AuthorizationRepo{db: db} }
+
+func (r *AuthorizationRepo) SaveRequest(req *models.AuthorizationRequest) error {
+	_, err := r.db.SQL.Exec(`
+		INSERT INTO oauth_authorization_requests (id, client_id, redirect_uri, scope, state, nonce, code_challenge, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, req.ID, req.ClientID, req.RedirectURI, req.Scope, req.State, req.Nonce, req.CodeChallenge, req.ExpiresAt, req.CreatedAt)
+	return err
+}
+
+func (r *AuthorizationRepo) FindRequest(id string) (*models.AuthorizationRequest, error) {
+	return scanAuthorizationRequest(r.db.SQL.QueryRow(`
+		SELECT id, client_id, redirect_uri, scope, state, nonce, code_challenge, expires_at, created_at
+		FROM oauth_authorization_requests WHERE id=$1
+	`, id))
+}
+
+// ConsumeRequest удаляет запрос, чтобы его нельзя было подтвердить дважды
+func (r *AuthorizationRepo) ConsumeRequest(id string) (*models.AuthorizationRequest, error) {
+	return scanAuthorizationRequest(r.db.SQL.QueryRow(`
+		DELETE FROM oauth_authorization_requests WHERE id=$1
+		RETURNING id, client_id, redirect_uri, scope, state, nonce, code_challenge, expires_at, created_at
+	`, id))
+}
+
+func scanAuthorizationRequest(row *sql.Row) (*models.AuthorizationRequest, error) {
+	var req models.AuthorizationRequest
+	err := row.Scan(&req.ID, &req.ClientID, &req.RedirectURI, &req.Scope, &req.State, &req.Nonce, &req.CodeChallenge, &req.ExpiresAt, &req.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *AuthorizationRepo) SaveCode(code *models.AuthorizationCode) error {
+	_, err := r.db.SQL.Exec(`
+		INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope, code.Nonce, code.CodeChallenge, code.AuthTime, code.ExpiresAt, code.CreatedAt)
+	return err
+}
+
+// ConsumeCode помечает code использованным. Уже использованный code возвращается
+// с заполненным UsedAt, чтобы вызывающий мог заметить повтор.
+func (r *AuthorizationRepo) ConsumeCode(codeHash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	var usedAt sql.NullTime
+	err := r.db.SQL.QueryRow(`
+		WITH prev AS (
+			SELECT code_hash, used_at FROM oauth_authorization_codes WHERE code_hash=$1 FOR UPDATE
+		)
+		UPDATE oauth_authorization_codes c SET used_at=COALESCE(prev.used_at, NOW())
+		FROM prev WHERE c.code_hash=prev.code_hash
+		RETURNING c.code_hash, c.client_id, c.user_id, c.redirect_uri, c.scope, c.nonce, c.code_challenge, c.auth_time, c.expires_at,
+			prev.used_at, c.created_at
+	`, codeHash).Scan(&code.CodeHash, &code.ClientID, &code.UserID, &code.RedirectURI, &code.Scope, &code.Nonce, &code.CodeChallenge, &code.AuthTime, &code.ExpiresAt, &usedAt, &code.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	code.UsedAt = nullTime(usedAt)
+	return &code, nil
+}
+
+func (r *AuthorizationRepo) DeleteExpired() error {
+	if _, err := r.db.SQL.Exec(`DELETE FROM oauth_authorization_requests WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	_, err := r.db.SQL.Exec(`DELETE FROM oauth_authorization_codes WHERE expires_at < NOW() - INTERVAL '1 day'`)
+	return err
+}
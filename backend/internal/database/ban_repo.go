@@ -0,0 +1,104 @@
+package database
+
+import (
+	"database/sql"
+
+	"user-service/internal/models"
+)
+
+type BanRepo struct {
+	db *DB
+}
+
+func NewBanRepo(db *DB) *BanRepo { return &BanRepo{db: db} }
+
+const banColumns = `id, user_id, kind, reason, banned_by, created_at, expires_at, lifted_at, lifted_by`
+
+func scanBan(row interface{ Scan(...any) error }) (*models.UserBan, error) {
+	var b models.UserBan
+	var bannedBy, liftedBy sql.NullInt64
+	var expiresAt, liftedAt sql.NullTime
+	if err := row.Scan(&b.ID, &b.UserID, &b.Kind, &b.Reason, &bannedBy, &b.CreatedAt, &expiresAt, &liftedAt, &liftedBy); err != nil {
+		return nil, err
+	}
+	b.BannedBy = nullUint(bannedBy)
+	b.LiftedBy = nullUint(liftedBy)
+	b.ExpiresAt = nullTime(expiresAt)
+	b.LiftedAt = nullTime(liftedAt)
+	return &b, nil
+}
+
+func nullUint(v sql.NullInt64) *uint {
+	if !v.Valid {
+		return nil
+	}
+	u := uint(v.Int64)
+	return &u
+}
+
+func (r *BanRepo) scanAll(rows *sql.Rows, err error) ([]models.UserBan, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []models.UserBan
+	for rows.Next() {
+		b, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, *b)
+	}
+	return bans, rows.Err()
+}
+
+// Create блокирует пользователя; прежняя не снятая блокировка (в том числе истекшая) снимается тем же, кто блокирует
+func (r *BanRepo) Create(b *models.UserBan) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE user_bans SET lifted_at=NOW(), lifted_by=$2 WHERE user_id=$1 AND lifted_at IS NULL
+	`, b.UserID, b.BannedBy); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(`
+		INSERT INTO user_bans (user_id, kind, reason, banned_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, b.UserID, b.Kind, b.Reason, b.BannedBy, b.ExpiresAt).Scan(&b.ID, &b.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindActive возвращает действующие блокировки
+func (r *BanRepo) FindActive() ([]models.UserBan, error) {
+	return r.scanAll(r.db.SQL.Query(`
+		SELECT ` + banColumns + ` FROM user_bans
+		WHERE lifted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC
+	`))
+}
+
+// FindByUserID возвращает историю блокировок пользователя, новые первыми
+func (r *BanRepo) FindByUserID(userID uint) ([]models.UserBan, error) {
+	return r.scanAll(r.db.SQL.Query(`SELECT `+banColumns+` FROM user_bans WHERE user_id=$1 ORDER BY created_at DESC`, userID))
+}
+
+// Lift снимает действующую блокировку; false — ее нет
+func (r *BanRepo) Lift(userID, liftedBy uint) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		UPDATE user_bans SET lifted_at=NOW(), lifted_by=$2
+		WHERE user_id=$1 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, userID, liftedBy)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
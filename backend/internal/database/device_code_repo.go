@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"user-service/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrUserCodeTaken — такой user_code уже выдан другому устройству; нужно сгенерировать новый
+var ErrUserCodeTaken = errors.New("user code already taken")
+
+type DeviceCodeRepo struct {
+	db *DB
+}
+
+func NewDeviceCodeRepo(db *DB) *DeviceCodeRepo { return &DeviceCodeRepo{db: db} }
+
+const deviceCodeColumns = `id, device_code_hash, user_code, client_id, scope, COALESCE(user_id, 0), status, interval_seconds, expires_at, created_at`
+
+func scanDeviceCode(row interface{ Scan(...any) error }, extra ...any) (*models.DeviceCode, error) {
+	var d models.DeviceCode
+	dest := append([]any{&d.ID, &d.DeviceCodeHash, &d.UserCode, &d.ClientID, &d.Scope, &d.UserID, &d.Status, &d.Interval, &d.ExpiresAt, &d.CreatedAt}, extra...)
+	err := row.Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DeviceCodeRepo) Create(d *models.DeviceCode) error {
+	err := r.db.SQL.QueryRow(`
+		INSERT INTO device_codes (device_code_hash, user_code, client_id, scope, status, interval_seconds, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, d.DeviceCodeHash, d.UserCode, d.ClientID, d.Scope, d.Status, d.Interval, d.ExpiresAt).Scan(&d.ID, &d.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "device_codes_user_code_key" {
+		return ErrUserCodeTaken
+	}
+	return err
+}
+
+func (r *DeviceCodeRepo) FindByUserCode(userCode string) (*models.DeviceCode, error) {
+	return scanDeviceCode(r.db.SQL.QueryRow(`SELECT `+deviceCodeColumns+` FROM device_codes WHERE user_code=$1`, userCode))
+}
+
+// Poll отмечает опрос устройства. tooFast — устройство опрашивает чаще интервала;
+// тогда интервал увеличивается на 5 секунд (RFC 8628, раздел 3.5).
+func (r *DeviceCodeRepo) Poll(deviceCodeHash string) (d *models.DeviceCode, tooFast bool, err error) {
+	d, err = scanDeviceCode(r.db.SQL.QueryRow(`
+		WITH prev AS (
+			SELECT id, last_polled_at, interval_seconds,
+				(last_polled_at IS NOT NULL AND last_polled_at > NOW() - make_interval(secs => interval_seconds)) AS too_fast
+			FROM device_codes WHERE device_code_hash=$1 FOR UPDATE
+		)
+		UPDATE device_codes d
+		SET last_polled_at=NOW(),
+			interval_seconds=CASE WHEN prev.too_fast THEN prev.interval_seconds + 5 ELSE prev.interval_seconds END
+		FROM prev WHERE d.id=prev.id
+		RETURNING d.id, d.device_code_hash, d.user_code, d.client_id, d.scope, COALESCE(d.user_id, 0), d.status, d.interval_seconds,
+			d.expires_at, d.created_at, prev.too_fast
+	`, deviceCodeHash), &tooFast)
+	return d, tooFast, err
+}
+
+// Resolve подтверждает или отклоняет ожидающий код
+func (r *DeviceCodeRepo) Resolve(userCode string, userID uint, status string) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		UPDATE device_codes SET status=$3, user_id=$2
+		WHERE user_code=$1 AND status='pending' AND expires_at > NOW()
+	`, userCode, userID, status)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Consume переводит подтвержденный код в consumed; токены по коду выдаются один раз
+func (r *DeviceCodeRepo) Consume(id uint) (bool, error) {
+	res, err := r.db.SQL.Exec(`UPDATE device_codes SET status='consumed' WHERE id=$1 AND status='approved'`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *DeviceCodeRepo) DeleteExpired() error {
+	_, err := r.db.SQL.Exec(`DELETE FROM device_codes WHERE expires_at < NOW() - INTERVAL '1 day'`)
+	return err
+}
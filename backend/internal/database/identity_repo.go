@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"user-service/internal/models"
+)
+
+var ErrLastIdentity = errors.New("cannot remove the last login method")
+
+type IdentityRepo struct {
+	db *DB
+}
+
+func NewIdentityRepo(db *DB) *IdentityRepo { return &IdentityRepo{db: db} }
+
+const identityColumns = `id, user_id, provider, subject, username, email, avatar, created_at, updated_at, grant_revoked_at`
+
+func scanIdentity(row interface{ Scan(...any) error }) (*models.UserIdentity, error) {
+	var i models.UserIdentity
+	var grantRevokedAt sql.NullTime
+	if err := row.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Username, &i.Email, &i.Avatar, &i.CreatedAt, &i.UpdatedAt, &grantRevokedAt); err != nil {
+		return nil, err
+	}
+	i.GrantRevokedAt = nullTime(grantRevokedAt)
+	return &i, nil
+}
+
+func (r *IdentityRepo) FindByUserID(userID uint) ([]models.UserIdentity, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT `+identityColumns+`
+		FROM user_identities WHERE user_id=$1 ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []models.UserIdentity
+	for rows.Next() {
+		i, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *i)
+	}
+	return identities, rows.Err()
+}
+
+func (r *IdentityRepo) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	i, err := scanIdentity(r.db.SQL.QueryRow(`
+		SELECT `+identityColumns+`
+		FROM user_identities WHERE provider=$1 AND subject=$2
+	`, provider, subject))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return i, err
+}
+
+func (r *IdentityRepo) Create(i *models.UserIdentity) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO user_identities (user_id, provider, subject, username, email, avatar)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, i.UserID, i.Provider, i.Subject, i.Username, i.Email, i.Avatar).Scan(&i.ID, &i.CreatedAt, &i.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Для совместимости заполняем users.discord_id, если его еще нет
+	if i.Provider == "discord" {
+		if _, err := tx.Exec(`UPDATE users SET discord_id=$1 WHERE id=$2 AND discord_id IS NULL`, i.Subject, i.UserID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *IdentityRepo) UpdateProfile(i *models.UserIdentity) error {
+	_, err := r.db.SQL.Exec(`
+		UPDATE user_identities SET username=$1, email=$2, avatar=$3, updated_at=NOW() WHERE id=$4
+	`, i.Username, i.Email, i.Avatar, i.ID)
+	return err
+}
+
+// Delete отвязывает учетную запись; последний способ входа пользователя удалить нельзя
+func (r *IdentityRepo) Delete(userID, identityID uint) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Чужая или несуществующая учетная запись — «не найдена», а не «последняя»
+	var owned bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM user_identities WHERE id=$1 AND user_id=$2)
+	`, identityID, userID).Scan(&owned); err != nil {
+		return err
+	}
+	if !owned {
+		return sql.ErrNoRows
+	}
+
+	count, err := countLoginMethods(tx, userID)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastIdentity
+	}
+
+	var provider, subject string
+	err = tx.QueryRow(`
+		DELETE FROM user_identities WHERE id=$1 AND user_id=$2 RETURNING provider, subject
+	`, identityID, userID).Scan(&provider, &subject)
+	if err != nil {
+		return err
+	}
+
+	if provider == "discord" {
+		_, err = tx.Exec(`
+			UPDATE users SET discord_id = (
+				SELECT subject FROM user_identities WHERE user_id=$1 AND provider='discord' ORDER BY id LIMIT 1
+			) WHERE id=$1 AND discord_id=$2
+		`, userID, subject)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// countLoginMethods считает учетные записи провайдеров и passkey пользователя.
+// Строки блокируются (всегда в этом порядке), чтобы два параллельных запроса не удалили все способы входа.
+func countLoginMethods(tx *sql.Tx, userID uint) (int, error) {
+	var identities, passkeys int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM (SELECT id FROM user_identities WHERE user_id=$1 FOR UPDATE) AS locked
+	`, userID).Scan(&identities); err != nil {
+		return 0, err
+	}
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM (SELECT id FROM passkeys WHERE user_id=$1 FOR UPDATE) AS locked
+	`, userID).Scan(&passkeys); err != nil {
+		return 0, err
+	}
+	return identities + passkeys, nil
+}
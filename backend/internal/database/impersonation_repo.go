@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+
+	"user-service/internal/models"
+)
+
+type ImpersonationRepo struct {
+	db *DB
+}
+
+func NewImpersonationRepo(db *DB) *ImpersonationRepo { return &ImpersonationRepo{db: db} }
+
+const impersonationColumns = `id, actor_id, user_id, reason, ip, created_at, expires_at, ended_at`
+
+func scanImpersonation(row interface{ Scan(...any) error }) (*models.Impersonation, error) {
+	var imp models.Impersonation
+	var endedAt sql.NullTime
+	if err := row.Scan(&imp.ID, &imp.ActorID, &imp.UserID, &imp.Reason, &imp.IP, &imp.CreatedAt, &imp.ExpiresAt, &endedAt); err != nil {
+		return nil, err
+	}
+	imp.EndedAt = nullTime(endedAt)
+	return &imp, nil
+}
+
+func (r *ImpersonationRepo) Create(imp *models.Impersonation) error {
+	return r.db.SQL.QueryRow(`
+		INSERT INTO impersonations (id, actor_id, user_id, reason, ip, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+	`, imp.ID, imp.ActorID, imp.UserID, imp.Reason, imp.IP, imp.ExpiresAt).Scan(&imp.CreatedAt)
+}
+
+func (r *ImpersonationRepo) Find(id string) (*models.Impersonation, error) {
+	imp, err := scanImpersonation(r.db.SQL.QueryRow(`SELECT `+impersonationColumns+` FROM impersonations WHERE id=$1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return imp, err
+}
+
+// FindRecent возвращает последние входы от имени пользователей, новые первыми
+func (r *ImpersonationRepo) FindRecent(limit int) ([]models.Impersonation, error) {
+	rows, err := r.db.SQL.Query(`SELECT `+impersonationColumns+` FROM impersonations ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.Impersonation
+	for rows.Next() {
+		imp, err := scanImpersonation(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *imp)
+	}
+	return result, rows.Err()
+}
+
+func (r *ImpersonationRepo) End(id string) (bool, error) {
+	res, err := r.db.SQL.Exec(`UPDATE impersonations SET ended_at=NOW() WHERE id=$1 AND ended_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *ImpersonationRepo) AddRequest(impersonationID string, req *models.ImpersonationRequest) error {
+	_, err := r.db.SQL.Exec(`
+		INSERT INTO impersonation_requests (impersonation_id, method, path, status, ip)
+		VALUES ($1, $2, $3, $4, $5)
+	`, impersonationID, req.Method, req.Path, req.Status, req.IP)
+	return err
+}
+
+func (r *ImpersonationRepo) FindRequests(impersonationID string) ([]models.ImpersonationRequest, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT id, method, path, status, ip, created_at
+		FROM impersonation_requests WHERE impersonation_id=$1 ORDER BY id
+	`, impersonationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.ImpersonationRequest
+	for rows.Next() {
+		var req models.ImpersonationRequest
+		if err := rows.Scan(&req.ID, &req.Method, &req.Path, &req.Status, &req.IP, &req.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, req)
+	}
+	return result, rows.Err()
+}
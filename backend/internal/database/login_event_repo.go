@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"user-service/internal/models"
+)
+
+type LoginEventRepo struct {
+	db *DB
+}
+
+func NewLoginEventRepo(db *DB) *LoginEventRepo { return &LoginEventRepo{db: db} }
+
+func (r *LoginEventRepo) Create(e *models.LoginEvent) error {
+	return r.db.SQL.QueryRow(`
+		INSERT INTO login_events (user_id, kind, success, failure_reason, ip, ip_range, user_agent, device, fingerprint)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`, e.UserID, e.Kind, e.Success, e.FailureReason, e.IP, e.IPRange, e.UserAgent, e.Device, e.Fingerprint).Scan(&e.ID, &e.CreatedAt)
+}
+
+// Seen сообщает, были ли у пользователя успешные входы вообще, с этого устройства и из этой сети
+func (r *LoginEventRepo) Seen(userID uint, fingerprint, ipRange string) (known, device, network bool, err error) {
+	err = r.db.SQL.QueryRow(`
+		SELECT COUNT(*) > 0,
+			COALESCE(BOOL_OR(fingerprint = $2), FALSE),
+			COALESCE(BOOL_OR(ip_range = $3), FALSE)
+		FROM login_events WHERE user_id=$1 AND success
+	`, userID, fingerprint, ipRange).Scan(&known, &device, &network)
+	return
+}
+
+// FindByUserID возвращает последние события, новые первыми
+func (r *LoginEventRepo) FindByUserID(userID uint, limit int) ([]models.LoginEvent, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT id, user_id, kind, success, failure_reason, ip, ip_range, user_agent, device, fingerprint, created_at
+		FROM login_events WHERE user_id=$1 ORDER BY id DESC LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.LoginEvent
+	for rows.Next() {
+		var e models.LoginEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Kind, &e.Success, &e.FailureReason, &e.IP, &e.IPRange, &e.UserAgent, &e.Device, &e.Fingerprint, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// DeleteBefore удаляет историю пользователя старше before
+func (r *LoginEventRepo) DeleteBefore(userID uint, before time.Time) error {
+	_, err := r.db.SQL.Exec(`DELETE FROM login_events WHERE user_id=$1 AND created_at < $2`, userID, before)
+	return err
+}
+
+func (r *LoginEventRepo) CreateAlert(a *models.LoginAlert) error {
+	return r.db.SQL.QueryRow(`
+		INSERT INTO login_alerts (user_id, new_device, new_ip_range, ip, user_agent, device)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, a.UserID, a.NewDevice, a.NewIPRange, a.IP, a.UserAgent, a.Device).Scan(&a.ID, &a.CreatedAt)
+}
+
+func (r *LoginEventRepo) FindAlerts(userID uint, limit int) ([]models.LoginAlert, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT id, user_id, new_device, new_ip_range, ip, user_agent, device, created_at, read_at
+		FROM login_alerts WHERE user_id=$1 ORDER BY id DESC LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.LoginAlert
+	for rows.Next() {
+		var a models.LoginAlert
+		var readAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.UserID, &a.NewDevice, &a.NewIPRange, &a.IP, &a.UserAgent, &a.Device, &a.CreatedAt, &readAt); err != nil {
+			return nil, err
+		}
+		a.ReadAt = nullTime(readAt)
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// MarkAlertsRead отмечает прочитанными все уведомления пользователя
+func (r *LoginEventRepo) MarkAlertsRead(userID uint) error {
+	_, err := r.db.SQL.Exec(`UPDATE login_alerts SET read_at=NOW() WHERE user_id=$1 AND read_at IS NULL`, userID)
+	return err
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"database/sql"
+
+	"user-service/internal/models"
+)
+
+type MFARepo struct {
+	db *DB
+}
+
+func NewMFARepo(db *DB) *MFARepo { return &MFARepo{db: db} }
+
+func (r *MFARepo) Find(userID uint) (*models.UserMFA, error) {
+	var m models.UserMFA
+	var enabledAt, lockedUntil sql.NullTime
+	err := r.db.SQL.QueryRow(`
+		SELECT user_id, secret, enabled_at, last_used_step, failed_attempts, locked_until, created_at
+		FROM user_mfa WHERE user_id=$1
+	`, userID).Scan(&m.UserID, &m.Secret, &enabledAt, &m.LastUsedStep, &m.FailedAttempts, &lockedUntil, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.EnabledAt = nullTime(enabledAt)
+	m.LockedUntil = nullTime(lockedUntil)
+	return &m, nil
+}
+
+// SavePending сохраняет новый секрет для подключения; уже включенный второй фактор не трогает
+func (r *MFARepo) SavePending(userID uint, secret string) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret=EXCLUDED.secret, last_used_step=0, failed_attempts=0, locked_until=NULL, created_at=NOW()
+		WHERE user_mfa.enabled_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UpdateSecret перезаписывает тот же секрет (перешифрованный новым ключом)
+func (r *MFARepo) UpdateSecret(userID uint, secret string) error {
+	_, err := r.db.SQL.Exec(`UPDATE user_mfa SET secret=$2 WHERE user_id=$1`, userID, secret)
+	return err
+}
+
+// Enable включает второй фактор и заменяет резервные коды
+func (r *MFARepo) Enable(userID uint, backupCodeHashes []string) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE user_mfa SET enabled_at=NOW() WHERE user_id=$1 AND enabled_at IS NULL`, userID); err != nil {
+		return err
+	}
+	if err := replaceBackupCodes(tx, userID, backupCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *MFARepo) ReplaceBackupCodes(userID uint, backupCodeHashes []string) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceBackupCodes(tx, userID, backupCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceBackupCodes(tx *sql.Tx, userID uint, hashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM mfa_backup_codes WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(`INSERT INTO mfa_backup_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MFARepo) Delete(userID uint) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_backup_codes WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_mfa WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseStep принимает TOTP-код шага step; false — код этого или более позднего шага уже использован
+func (r *MFARepo) UseStep(userID uint, step int64) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		UPDATE user_mfa SET last_used_step=$2, failed_attempts=0, locked_until=NULL
+		WHERE user_id=$1 AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UseBackupCode гасит резервный код; false — такого неиспользованного кода нет
+func (r *MFARepo) UseBackupCode(userID uint, codeHash string) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		UPDATE mfa_backup_codes SET used_at=NOW()
+		WHERE id = (
+			SELECT id FROM mfa_backup_codes
+			WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL
+			LIMIT 1
+		)
+	`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	_, err = r.db.SQL.Exec(`UPDATE user_mfa SET failed_attempts=0, locked_until=NULL WHERE user_id=$1`, userID)
+	return true, err
+}
+
+func (r *MFARepo) CountBackupCodes(userID uint) (int, error) {
+	var n int
+	err := r.db.SQL.QueryRow(`SELECT COUNT(*) FROM mfa_backup_codes WHERE user_id=$1 AND used_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
+
+// RecordFailure считает неверные коды; после maxAttempts подряд ввод блокируется на lockSeconds
+func (r *MFARepo) RecordFailure(userID uint, maxAttempts, lockSeconds int) error {
+	_, err := r.db.SQL.Exec(`
+		UPDATE user_mfa SET
+			failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN NOW() + make_interval(secs => $3) ELSE locked_until END
+		WHERE user_id=$1
+	`, userID, maxAttempts, lockSeconds)
+	return err
+}
+
+func (r *MFARepo) SaveChallenge(ch *models.MFAChallenge) error {
+	_, err := r.db.SQL.Exec(`
+		INSERT INTO mfa_challenges (id_hash, user_id, scope, return_to, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, ch.IDHash, ch.UserID, ch.Scope, ch.ReturnTo, ch.ExpiresAt)
+	return err
+}
+
+func (r *MFARepo) FindChallenge(idHash string) (*models.MFAChallenge, error) {
+	var ch models.MFAChallenge
+	err := r.db.SQL.QueryRow(`
+		SELECT id_hash, user_id, scope, return_to, expires_at, created_at
+		FROM mfa_challenges WHERE id_hash=$1
+	`, idHash).Scan(&ch.IDHash, &ch.UserID, &ch.Scope, &ch.ReturnTo, &ch.ExpiresAt, &ch.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// ConsumeChallenge удаляет challenge; false — его уже использовал параллельный запрос
+func (r *MFARepo) ConsumeChallenge(idHash string) (bool, error) {
+	res, err := r.db.SQL.Exec(`DELETE FROM mfa_challenges WHERE id_hash=$1`, idHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *MFARepo) DeleteExpiredChallenges() error {
+	_, err := r.db.SQL.Exec(`DELETE FROM mfa_challenges WHERE expires_at < NOW()`)
+	return err
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"user-service/internal/models"
+)
+
+type OAuthClientRepo struct {
+	db *DB
+}
+
+func NewOAuthClientRepo(db *DB) *OAuthClientRepo { return &OAuthClientRepo{db: db} }
+
+const oauthClientColumns = `id, name, COALESCE(secret_hash, ''), grant_types, redirect_uris, scopes, created_at, updated_at, disabled_at`
+
+func scanOAuthClient(row interface{ Scan(...any) error }) (*models.OAuthClient, error) {
+	var c models.OAuthClient
+	var grantTypes, redirectURIs, scopes string
+	var disabledAt sql.NullTime
+	if err := row.Scan(&c.ID, &c.Name, &c.SecretHash, &grantTypes, &redirectURIs, &scopes, &c.CreatedAt, &c.UpdatedAt, &disabledAt); err != nil {
+		return nil, err
+	}
+	c.GrantTypes = strings.Fields(grantTypes)
+	c.DisabledAt = nullTime(disabledAt)
+	c.RedirectURIs = strings.Fields(redirectURIs)
+	c.Scopes = strings.Fields(scopes)
+	return &c, nil
+}
+
+func (r *OAuthClientRepo) FindByID(id string) (*models.OAuthClient, error) {
+	c, err := scanOAuthClient(r.db.SQL.QueryRow(`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE id=$1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+func (r *OAuthClientRepo) FindAll() ([]models.OAuthClient, error) {
+	rows, err := r.db.SQL.Query(`SELECT ` + oauthClientColumns + ` FROM oauth_clients ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []models.OAuthClient
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *c)
+	}
+	return clients, rows.Err()
+}
+
+func (r *OAuthClientRepo) Create(c *models.OAuthClient) error {
+	var secretHash sql.NullString
+	if c.SecretHash != "" {
+		secretHash = sql.NullString{String: c.SecretHash, Valid: true}
+	}
+	return r.db.SQL.QueryRow(`
+		INSERT INTO oauth_clients (id, name, secret_hash, grant_types, redirect_uris, scopes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at
+	`, c.ID, c.Name, secretHash, strings.Join(c.GrantTypes, " "), strings.Join(c.RedirectURIs, " "), strings.Join(c.Scopes, " ")).Scan(&c.CreatedAt, &c.UpdatedAt)
+}
+
+// UpdateSecret заменяет секрет; у публичных клиентов секрета нет
+func (r *OAuthClientRepo) UpdateSecret(id, secretHash string) error {
+	res, err := r.db.SQL.Exec(`
+		UPDATE oauth_clients SET secret_hash=$2, updated_at=NOW()
+		WHERE id=$1 AND secret_hash IS NOT NULL
+	`, id, secretHash)
+	return expectRow(res, err)
+}
+
+func (r *OAuthClientRepo) SetDisabled(id string, disabled bool) error {
+	res, err := r.db.SQL.Exec(`
+		UPDATE oauth_clients
+		SET disabled_at=CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) ELSE NULL END, updated_at=NOW()
+		WHERE id=$1
+	`, id, disabled)
+	return expectRow(res, err)
+}
+
+// expectRow превращает UPDATE без затронутых строк в sql.ErrNoRows
+func expectRow(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
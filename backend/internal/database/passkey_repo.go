@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+
+	"user-service/internal/models"
+)
+
+type PasskeyRepo struct {
+	db *DB
+}
+
+func NewPasskeyRepo(db *DB) *PasskeyRepo { return &PasskeyRepo{db: db} }
+
+const passkeyColumns = `id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at, last_used_at`
+
+func scanPasskey(row interface{ Scan(...any) error }) (*models.Passkey, error) {
+	var p models.Passkey
+	var signCount int64
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.UserID, &p.CredentialID, &p.PublicKey, &signCount, &p.AAGUID, &p.Name, &p.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	p.SignCount = uint32(signCount)
+	p.LastUsedAt = nullTime(lastUsedAt)
+	return &p, nil
+}
+
+func (r *PasskeyRepo) Create(p *models.Passkey) error {
+	return r.db.SQL.QueryRow(`
+		INSERT INTO passkeys (user_id, credential_id, public_key, sign_count, aaguid, name)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, p.UserID, p.CredentialID, p.PublicKey, int64(p.SignCount), p.AAGUID, p.Name).Scan(&p.ID, &p.CreatedAt)
+}
+
+func (r *PasskeyRepo) FindByUserID(userID uint) ([]models.Passkey, error) {
+	rows, err := r.db.SQL.Query(`SELECT `+passkeyColumns+` FROM passkeys WHERE user_id=$1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passkeys []models.Passkey
+	for rows.Next() {
+		p, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, *p)
+	}
+	return passkeys, rows.Err()
+}
+
+func (r *PasskeyRepo) FindByCredentialID(credentialID string) (*models.Passkey, error) {
+	p, err := scanPasskey(r.db.SQL.QueryRow(`SELECT `+passkeyColumns+` FROM passkeys WHERE credential_id=$1`, credentialID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// UpdateSignCount сохраняет счетчик после входа; false — параллельный вход уже записал такой же или больший
+func (r *PasskeyRepo) UpdateSignCount(id uint, signCount uint32) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		UPDATE passkeys SET sign_count=$2, last_used_at=NOW()
+		WHERE id=$1 AND (sign_count < $2 OR $2 = 0)
+	`, id, int64(signCount))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Delete удаляет passkey; последний способ входа (учетная запись провайдера или passkey) удалить нельзя
+func (r *PasskeyRepo) Delete(userID, id uint) (bool, error) {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Сначала владелец: по чужому id нельзя узнать, последний ли это способ входа
+	var owned bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM passkeys WHERE id=$1 AND user_id=$2)
+	`, id, userID).Scan(&owned); err != nil {
+		return false, err
+	}
+	if !owned {
+		return false, nil
+	}
+
+	count, err := countLoginMethods(tx, userID)
+	if err != nil {
+		return false, err
+	}
+	if count <= 1 {
+		return false, ErrLastIdentity
+	}
+
+	res, err := tx.Exec(`DELETE FROM passkeys WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+func (r *PasskeyRepo) SaveChallenge(ch *models.WebAuthnChallenge) error {
+	_, err := r.db.SQL.Exec(`
+		INSERT INTO webauthn_challenges (challenge_hash, user_id, purpose, scope, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, ch.ChallengeHash, ch.UserID, ch.Purpose, ch.Scope, ch.ExpiresAt)
+	return err
+}
+
+// ConsumeChallenge забирает challenge одним запросом: повторно его использовать нельзя
+func (r *PasskeyRepo) ConsumeChallenge(challengeHash, purpose string) (*models.WebAuthnChallenge, error) {
+	var ch models.WebAuthnChallenge
+	var userID sql.NullInt64
+	err := r.db.SQL.QueryRow(`
+		DELETE FROM webauthn_challenges WHERE challenge_hash=$1 AND purpose=$2
+		RETURNING challenge_hash, user_id, purpose, scope, expires_at
+	`, challengeHash, purpose).Scan(&ch.ChallengeHash, &userID, &ch.Purpose, &ch.Scope, &ch.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		id := uint(userID.Int64)
+		ch.UserID = &id
+	}
+	return &ch, nil
+}
+
+func (r *PasskeyRepo) DeleteExpiredChallenges() error {
+	_, err := r.db.SQL.Exec(`DELETE FROM webauthn_challenges WHERE expires_at < NOW()`)
+	return err
+}
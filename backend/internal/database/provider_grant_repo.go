@@ -0,0 +1,91 @@
+package database
+
+import (
+	"time"
+
+	"user-service/internal/models"
+)
+
+type ProviderGrantRepo struct {
+	db *DB
+}
+
+func NewProviderGrantRepo(db *DB) *ProviderGrantRepo { return &ProviderGrantRepo{db: db} }
+
+// Save сохраняет токены учетной записи (новые заменяют прежние) и снимает отметку об отзыве доступа
+func (r *ProviderGrantRepo) Save(g *models.ProviderGrant) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO provider_grants (identity_id, access_token, refresh_token, scope, expires_at, synced_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (identity_id) DO UPDATE SET
+			access_token = EXCLUDED.access_token,
+			refresh_token = EXCLUDED.refresh_token,
+			scope = EXCLUDED.scope,
+			expires_at = EXCLUDED.expires_at,
+			synced_at = NOW(),
+			updated_at = NOW()
+	`, g.IdentityID, g.AccessToken, g.RefreshToken, g.Scope, g.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE user_identities SET grant_revoked_at=NULL WHERE id=$1`, g.IdentityID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClaimDue забирает до limit учетных записей провайдера, синхронизированных раньше syncedBefore,
+// и сразу сдвигает им synced_at: параллельный экземпляр сервиса их уже не возьмет
+func (r *ProviderGrantRepo) ClaimDue(provider string, syncedBefore time.Time, limit int) ([]models.ProviderGrant, error) {
+	rows, err := r.db.SQL.Query(`
+		WITH due AS (
+			SELECT g.identity_id FROM provider_grants g
+			JOIN user_identities i ON i.id = g.identity_id
+			WHERE i.provider=$1 AND g.synced_at < $2
+			ORDER BY g.synced_at
+			LIMIT $3
+			FOR UPDATE OF g SKIP LOCKED
+		)
+		UPDATE provider_grants g SET synced_at=NOW()
+		FROM due, user_identities i
+		WHERE g.identity_id = due.identity_id AND i.id = g.identity_id
+		RETURNING g.identity_id, i.user_id, i.provider, i.subject, g.access_token, g.refresh_token, g.scope, g.expires_at, g.synced_at
+	`, provider, syncedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []models.ProviderGrant
+	for rows.Next() {
+		var g models.ProviderGrant
+		if err := rows.Scan(&g.IdentityID, &g.UserID, &g.Provider, &g.Subject, &g.AccessToken, &g.RefreshToken, &g.Scope, &g.ExpiresAt, &g.SyncedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// MarkRevoked удаляет токены, которые провайдер больше не принимает, и отмечает учетную запись
+func (r *ProviderGrantRepo) MarkRevoked(identityID uint) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM provider_grants WHERE identity_id=$1`, identityID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE user_identities SET grant_revoked_at=NOW() WHERE id=$1`, identityID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
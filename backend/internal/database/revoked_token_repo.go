@@ -0,0 +1,42 @@
+package database
+
+import "time"
+
+type RevokedTokenRepo struct {
+	db *DB
+}
+
+func NewRevokedTokenRepo(db *DB) *RevokedTokenRepo { return &RevokedTokenRepo{db: db} }
+
+func (r *RevokedTokenRepo) Add(jti string, expiresAt time.Time) error {
+	_, err := r.db.SQL.Exec(`
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, expiresAt)
+	return err
+}
+
+// FindActive возвращает еще не истекшие отозванные токены: jti -> expires_at
+func (r *RevokedTokenRepo) FindActive() (map[string]time.Time, error) {
+	rows, err := r.db.SQL.Query(`SELECT jti, expires_at FROM revoked_tokens WHERE expires_at > NOW()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revoked := map[string]time.Time{}
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			return nil, err
+		}
+		revoked[jti] = expiresAt
+	}
+	return revoked, rows.Err()
+}
+
+func (r *RevokedTokenRepo) DeleteExpired() error {
+	_, err := r.db.SQL.Exec(`DELETE FROM revoked_tokens WHERE expires_at < NOW()`)
+	return err
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"user-service/internal/models"
+)
+
+type RoleRepo struct {
+	db *DB
+}
+
+func NewRoleRepo(db *DB) *RoleRepo { return &RoleRepo{db: db} }
+
+// FindAll возвращает роли вместе с их правами
+func (r *RoleRepo) FindAll() ([]models.Role, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT r.name, r.description, r.builtin, r.require_mfa, r.created_at, r.updated_at,
+			COALESCE(string_agg(rp.permission, ' ' ORDER BY rp.permission), '')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+		GROUP BY r.name
+		ORDER BY r.created_at, r.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		var role models.Role
+		var permissions string
+		if err := rows.Scan(&role.Name, &role.Description, &role.Builtin, &role.RequireMFA, &role.CreatedAt, &role.UpdatedAt, &permissions); err != nil {
+			return nil, err
+		}
+		role.Permissions = strings.Fields(permissions)
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *RoleRepo) FindAllPermissions() ([]models.Permission, error) {
+	rows, err := r.db.SQL.Query(`SELECT name, description FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var p models.Permission
+		if err := rows.Scan(&p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+func (r *RoleRepo) Create(role *models.Role) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO roles (name, description) VALUES ($1, $2)
+		RETURNING builtin, created_at, updated_at
+	`, role.Name, role.Description).Scan(&role.Builtin, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := setRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update меняет описание и набор прав роли; false — роли нет
+func (r *RoleRepo) Update(role *models.Role) (bool, error) {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE roles SET description=$2, updated_at=NOW() WHERE name=$1
+		RETURNING builtin, require_mfa, created_at, updated_at
+	`, role.Name, role.Description).Scan(&role.Builtin, &role.RequireMFA, &role.CreatedAt, &role.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role=$1`, role.Name); err != nil {
+		return false, err
+	}
+	if err := setRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// SetRequireMFA включает или выключает обязательный второй фактор для роли
+func (r *RoleRepo) SetRequireMFA(name string, required bool) (bool, error) {
+	res, err := r.db.SQL.Exec(`UPDATE roles SET require_mfa=$2, updated_at=NOW() WHERE name=$1`, name, required)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func setRolePermissions(tx *sql.Tx, role string, permissions []string) error {
+	for _, permission := range permissions {
+		if _, err := tx.Exec(`
+			INSERT INTO role_permissions (role, permission) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, role, permission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete удаляет роль, если она не встроенная и не назначена ни одному пользователю
+func (r *RoleRepo) Delete(name string) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		DELETE FROM roles
+		WHERE name=$1 AND NOT builtin AND NOT EXISTS (SELECT 1 FROM users WHERE role=$1)
+	`, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *RoleRepo) CountUsers(name string) (int, error) {
+	var n int
+	err := r.db.SQL.QueryRow(`SELECT COUNT(*) FROM users WHERE role=$1`, name).Scan(&n)
+	return n, err
+}
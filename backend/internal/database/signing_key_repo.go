@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"user-service/internal/models"
+)
+
+type SigningKeyRepo struct {
+	db *DB
+}
+
+func NewSigningKeyRepo(db *DB) *SigningKeyRepo { return &SigningKeyRepo{db: db} }
+
+func (r *SigningKeyRepo) FindAll() ([]models.SigningKey, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT kid, algorithm, private_key, status, created_at, activated_at, retire_after, retired_at
+		FROM signing_keys ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKey
+	for rows.Next() {
+		var k models.SigningKey
+		var activatedAt, retireAfter, retiredAt sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Algorithm, &k.PrivateKey, &k.Status, &k.CreatedAt, &activatedAt, &retireAfter, &retiredAt); err != nil {
+			return nil, err
+		}
+		k.ActivatedAt = nullTime(activatedAt)
+		k.RetireAfter = nullTime(retireAfter)
+		k.RetiredAt = nullTime(retiredAt)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *SigningKeyRepo) Create(k *models.SigningKey) error {
+	return r.db.SQL.QueryRow(`
+		INSERT INTO signing_keys (kid, algorithm, private_key, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, k.ID, k.Algorithm, k.PrivateKey, k.Status).Scan(&k.CreatedAt)
+}
+
+// UpdatePrivateKey перезаписывает материал ключа (шифрование открытого или смена мастер-ключа)
+func (r *SigningKeyRepo) UpdatePrivateKey(kid, privateKey string) error {
+	_, err := r.db.SQL.Exec(`UPDATE signing_keys SET private_key=$2 WHERE kid=$1`, kid, privateKey)
+	return err
+}
+
+// Promote делает ключ текущим; прежний активный ключ принимается до retireAfter
+func (r *SigningKeyRepo) Promote(kid string, retireAfter time.Time) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE signing_keys SET status='retiring', retire_after=$1
+		WHERE status='active' AND kid<>$2
+	`, retireAfter, kid)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE signing_keys SET status='active', activated_at=COALESCE(activated_at, NOW()), retire_after=NULL
+		WHERE kid=$1 AND status IN ('pending', 'retiring')
+	`, kid)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// Retire выводит ключ из использования; активный ключ так убрать нельзя
+func (r *SigningKeyRepo) Retire(kid string) error {
+	res, err := r.db.SQL.Exec(`
+		UPDATE signing_keys SET status='retired', retired_at=NOW()
+		WHERE kid=$1 AND status IN ('pending', 'retiring')
+	`, kid)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RetireExpired выводит из использования ключи, у которых закончился grace period
+func (r *SigningKeyRepo) RetireExpired() ([]string, error) {
+	rows, err := r.db.SQL.Query(`
+		UPDATE signing_keys SET status='retired', retired_at=NOW()
+		WHERE status='retiring' AND retire_after < NOW()
+		RETURNING kid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var kids []string
+	for rows.Next() {
+		var kid string
+		if err := rows.Scan(&kid); err != nil {
+			return nil, err
+		}
+		kids = append(kids, kid)
+	}
+	return kids, rows.Err()
+}
+
+func (r *SigningKeyRepo) AddEvent(e *models.SigningKeyEvent) error {
+	return r.db.SQL.QueryRow(`
+		INSERT INTO signing_key_events (kid, event, actor, details)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, e.KeyID, e.Event, e.Actor, e.Details).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (r *SigningKeyRepo) FindEvents(limit int) ([]models.SigningKeyEvent, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT id, kid, event, actor, details, created_at
+		FROM signing_key_events ORDER BY id DESC LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.SigningKeyEvent
+	for rows.Next() {
+		var e models.SigningKeyEvent
+		if err := rows.Scan(&e.ID, &e.KeyID, &e.Event, &e.Actor, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"database/sql"
+
+	"user-service/internal/models"
+)
+
+type StateRepo struct {
+	db *DB
+}
+
+func NewStateRepo(db *DB) *StateRepo { return &StateRepo{db: db} }
+
+func (r *StateRepo) Save(st *models.OAuthState) error {
+	var linkUserID sql.NullInt64
+	if st.LinkUserID != 0 {
+		linkUserID = sql.NullInt64{Int64: int64(st.LinkUserID), Valid: true}
+	}
+	_, err := r.db.SQL.Exec(`
+		INSERT INTO oauth_states (state, provider, code_verifier, return_to, link_user_id, scope, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, st.State, st.Provider, st.CodeVerifier, st.ReturnTo, linkUserID, st.Scope, st.ExpiresAt, st.CreatedAt)
+	return err
+}
+
+// Consume atomically removes the state so it can't be replayed.
+func (r *StateRepo) Consume(state string) (*models.OAuthState, error) {
+	var st models.OAuthState
+	var linkUserID sql.NullInt64
+	err := r.db.SQL.QueryRow(`
+		DELETE FROM oauth_states WHERE state=$1
+		RETURNING id, state, provider, code_verifier, return_to, link_user_id, scope, expires_at, created_at
+	`, state).Scan(&st.ID, &st.State, &st.Provider, &st.CodeVerifier, &st.ReturnTo, &linkUserID, &st.Scope, &st.ExpiresAt, &st.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.LinkUserID = uint(linkUserID.Int64)
+	return &st, nil
+}
+
+func (r *StateRepo) DeleteExpired() error {
+	_, err := r.db.SQL.Exec(`DELETE FROM oauth_states WHERE expires_at < NOW()`)
+	return err
+}
@@ -1,9 +1,15 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
+
 	"user-service/internal/models"
 )
 
+// ErrTokenAlreadyRotated — токен уже был обменян на новый (или отозван) другим запросом
+var ErrTokenAlreadyRotated = errors.New("refresh token already rotated")
+
 type TokenRepo struct {
 	db *DB
 }
@@ -12,21 +18,126 @@ func NewTokenRepo(db *DB) *TokenRepo { return &TokenRepo{db: db} }
 
 func (r *TokenRepo) Save(rt *models.RefreshToken) error {
 	_, err := r.db.SQL.Exec(`
-		INSERT INTO refresh_tokens (user_id, token, expires_at, created_at)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (token) DO NOTHING
-	`, rt.UserID, rt.Token, rt.ExpiresAt, rt.CreatedAt)
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, client_id, scope, ip, user_agent, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (token_hash) DO NOTHING
+	`, rt.UserID, rt.TokenHash, rt.FamilyID, rt.ClientID, rt.Scope, rt.IP, rt.UserAgent, rt.ExpiresAt, rt.CreatedAt)
 	return err
 }
 
-func (r *TokenRepo) Find(token string) (*models.RefreshToken, error) {
+func (r *TokenRepo) FindByHash(tokenHash string) (*models.RefreshToken, error) {
 	var rt models.RefreshToken
-	err := r.db.SQL.QueryRow(`SELECT id, user_id, token, expires_at, created_at FROM refresh_tokens WHERE token=$1`, token).
-		Scan(&rt.ID, &rt.UserID, &rt.Token, &rt.ExpiresAt, &rt.CreatedAt)
+	var rotatedAt, revokedAt sql.NullTime
+	err := r.db.SQL.QueryRow(`
+		SELECT id, user_id, token_hash, family_id, client_id, scope, ip, user_agent, expires_at, created_at, rotated_at, revoked_at
+		FROM refresh_tokens WHERE token_hash=$1
+	`, tokenHash).Scan(&rt.ID, &rt.UserID, &rt.TokenHash, &rt.FamilyID, &rt.ClientID, &rt.Scope, &rt.IP, &rt.UserAgent, &rt.ExpiresAt, &rt.CreatedAt, &rotatedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	if rotatedAt.Valid {
+		rt.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		rt.RevokedAt = &revokedAt.Time
+	}
 	return &rt, nil
 }
 
+// Rotate помечает старый токен использованным и сохраняет его замену в той же семье
+func (r *TokenRepo) Rotate(old, next *models.RefreshToken) error {
+	tx, err := r.db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE refresh_tokens SET rotated_at=NOW()
+		WHERE id=$1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`, old.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTokenAlreadyRotated
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, client_id, scope, ip, user_agent, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, next.UserID, next.TokenHash, next.FamilyID, next.ClientID, next.Scope, next.IP, next.UserAgent, next.ExpiresAt, next.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TokenRepo) RevokeFamily(familyID string) error {
+	_, err := r.db.SQL.Exec(`UPDATE refresh_tokens SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL`, familyID)
+	return err
+}
+
+// RevokeUserFamily отзывает сессию, только если она принадлежит пользователю
+func (r *TokenRepo) RevokeUserFamily(userID uint, familyID string) (bool, error) {
+	res, err := r.db.SQL.Exec(`
+		UPDATE refresh_tokens SET revoked_at=NOW()
+		WHERE user_id=$1 AND family_id=$2 AND revoked_at IS NULL
+	`, userID, familyID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *TokenRepo) RevokeAllForUser(userID uint) error {
+	_, err := r.db.SQL.Exec(`UPDATE refresh_tokens SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL`, userID)
+	return err
+}
 
+// SessionActive — есть ли в семье действующий (не обменянный, не отозванный, не истекший) токен
+func (r *TokenRepo) SessionActive(familyID string) (bool, error) {
+	var active bool
+	err := r.db.SQL.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE family_id=$1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`, familyID).Scan(&active)
+	return active, err
+}
+
+// FindSessions возвращает живые сессии: по одному действующему токену на семью
+func (r *TokenRepo) FindSessions(userID uint) ([]models.Session, error) {
+	rows, err := r.db.SQL.Query(`
+		SELECT t.family_id, t.ip, t.user_agent, f.started_at, t.created_at, t.expires_at
+		FROM refresh_tokens t
+		JOIN (
+			SELECT family_id, MIN(created_at) AS started_at
+			FROM refresh_tokens WHERE user_id=$1 GROUP BY family_id
+		) f ON f.family_id = t.family_id
+		WHERE t.user_id=$1 AND t.rotated_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > NOW()
+		ORDER BY t.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
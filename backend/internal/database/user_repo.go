@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+
 	"user-service/internal/models"
 )
 
@@ -10,25 +12,64 @@ type UserRepo struct {
 
 func NewUserRepo(db *DB) *UserRepo { return &UserRepo{db: db} }
 
-func (r *UserRepo) UpsertByDiscordID(discordID, username, discriminator, avatar string) (*models.User, error) {
-	var u models.User
-	// Upsert by discord_id
-	query := `
-		INSERT INTO users (discord_id, username, discriminator, avatar)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (discord_id) DO UPDATE SET username = EXCLUDED.username, discriminator = EXCLUDED.discriminator, avatar = EXCLUDED.avatar
-		RETURNING id, discord_id, username, discriminator, avatar, role, created_at
-	`
-	err := r.db.SQL.QueryRow(query, discordID, username, discriminator, avatar).Scan(&u.ID, &u.DiscordID, &u.Username, &u.Discriminator, &u.Avatar, &u.Role, &u.CreatedAt)
+// UpsertByIdentity находит пользователя по внешней учетной записи или создает нового вместе с ней
+func (r *UserRepo) UpsertByIdentity(provider, subject, username, discriminator, email, avatar string) (*models.User, error) {
+	tx, err := r.db.SQL.Begin()
 	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	defer tx.Rollback()
+
+	var userID uint
+	err = tx.QueryRow(`SELECT user_id FROM user_identities WHERE provider=$1 AND subject=$2 FOR UPDATE`, provider, subject).Scan(&userID)
+	switch {
+	case err == sql.ErrNoRows:
+		// discord_id заполняем только для Discord, для остальных провайдеров он NULL
+		var discordID sql.NullString
+		if provider == "discord" {
+			discordID = sql.NullString{String: subject, Valid: true}
+		}
+		err = tx.QueryRow(`
+			INSERT INTO users (discord_id, username, discriminator, avatar)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (discord_id) DO UPDATE SET username = EXCLUDED.username, discriminator = EXCLUDED.discriminator, avatar = EXCLUDED.avatar
+			RETURNING id
+		`, discordID, username, discriminator, avatar).Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO user_identities (user_id, provider, subject, username, email, avatar)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, userID, provider, subject, username, email, avatar)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		_, err = tx.Exec(`
+			UPDATE user_identities SET username=$1, email=$2, avatar=$3, updated_at=NOW()
+			WHERE provider=$4 AND subject=$5
+		`, username, email, avatar, provider, subject)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`UPDATE users SET username=$1, discriminator=$2, avatar=$3 WHERE id=$4`, username, discriminator, avatar, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.FindByID(userID)
 }
 
 func (r *UserRepo) FindByID(id uint) (*models.User, error) {
 	var u models.User
-	err := r.db.SQL.QueryRow(`SELECT id, discord_id, username, discriminator, avatar, role, created_at FROM users WHERE id=$1`, id).
+	err := r.db.SQL.QueryRow(`SELECT id, COALESCE(discord_id, ''), username, discriminator, avatar, role, created_at FROM users WHERE id=$1`, id).
 		Scan(&u.ID, &u.DiscordID, &u.Username, &u.Discriminator, &u.Avatar, &u.Role, &u.CreatedAt)
 	if err != nil {
 		return nil, err
@@ -37,7 +78,7 @@ func (r *UserRepo) FindByID(id uint) (*models.User, error) {
 }
 
 func (r *UserRepo) FindAll() ([]models.User, error) {
-	rows, err := r.db.SQL.Query(`SELECT id, discord_id, username, discriminator, avatar, role, created_at FROM users ORDER BY id`)
+	rows, err := r.db.SQL.Query(`SELECT id, COALESCE(discord_id, ''), username, discriminator, avatar, role, created_at FROM users ORDER BY id`)
 	if err != nil {
 		return nil, err
 	}
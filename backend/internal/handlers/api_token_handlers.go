@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAPITokens возвращает личные API-ключи пользователя (без самих ключей)
+func (h *UserHandler) GetAPITokens(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.authService.GetAPITokens(userID)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to get API tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAPIToken создает ключ; поле token есть только в этом ответе
+func (h *UserHandler) CreateAPIToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name      string     `json:"name" binding:"required"`
+		Scopes    []string   `json:"scopes" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, plain, err := h.authService.CreateAPIToken(userID, req.Name, req.Scopes, req.ExpiresAt)
+	switch {
+	case errors.Is(err, services.ErrInvalidAPITokenParams):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrTooManyAPITokens):
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many API tokens, revoke unused ones first"})
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to create API token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":     plain,
+		"api_token": token,
+	})
+}
+
+// RevokeAPIToken отзывает ключ
+func (h *UserHandler) RevokeAPIToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	err = h.authService.RevokeAPIToken(userID, uint(tokenID))
+	if errors.Is(err, services.ErrAPITokenNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to revoke API token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked successfully"})
+}
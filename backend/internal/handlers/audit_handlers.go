@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEvents — журнал аудита с фильтрами actor, target, action, from, to (RFC 3339).
+// Страницы листаются через before_id; export=csv|json отдает файл со всеми подходящими событиями.
+func (h *UserHandler) ListAuditEvents(c *gin.Context) {
+	filter := models.AuditFilter{
+		Actor:  c.Query("actor"),
+		Target: c.Query("target"),
+		Action: c.Query("action"),
+	}
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 timestamp"})
+			return
+		}
+		*dst = &t
+	}
+	if value := c.Query("before_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before_id"})
+			return
+		}
+		filter.BeforeID = id
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	export := c.Query("export")
+	if export != "" && export != "csv" && export != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "export must be csv or json"})
+		return
+	}
+	if export != "" {
+		events, err := h.authService.ExportAuditEvents(filter)
+		if err != nil {
+			h.auditError(c, err)
+			return
+		}
+		filename := "audit-" + time.Now().UTC().Format("20060102-150405") + "." + export
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if export == "json" {
+			c.JSON(http.StatusOK, events)
+			return
+		}
+		writeAuditCSV(c, events)
+		return
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = services.DefaultAuditPageSize
+	}
+	events, err := h.authService.ListAuditEvents(filter)
+	if err != nil {
+		h.auditError(c, err)
+		return
+	}
+	resp := gin.H{"events": events}
+	// Полная страница — возможно, есть следующая
+	if n := len(events); n > 0 && n == filter.Limit {
+		resp["next_before_id"] = events[n-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func writeAuditCSV(c *gin.Context, events []models.AuditEvent) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "created_at", "actor", "action", "target", "before", "after", "ip", "user_agent", "request_id"})
+	for _, e := range events {
+		w.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			csvCell(e.Actor),
+			csvCell(e.Action),
+			csvCell(e.Target),
+			csvCell(string(e.Before)),
+			csvCell(string(e.After)),
+			csvCell(e.IP),
+			csvCell(e.UserAgent),
+			csvCell(e.RequestID),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.Error(fmt.Errorf("write audit csv: %w", err))
+	}
+}
+
+// csvCell не дает табличным редакторам принять значение (например, User-Agent) за формулу
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (h *UserHandler) auditError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidAuditFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.WithError(err).Error("Failed to read audit log")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit log"})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BanUser блокирует пользователя и завершает его сессии
+func (h *UserHandler) BanUser(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req struct {
+		Kind      string     `json:"kind" binding:"required"`
+		Reason    string     `json:"reason" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ban, err := h.authService.BanUser(actorID, currentPermissions(c), uint(userID), services.BanParams{
+		Kind:      req.Kind,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		h.banError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, ban)
+}
+
+// LiftBan снимает действующую блокировку
+func (h *UserHandler) LiftBan(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if err := h.authService.LiftBan(actorID, currentPermissions(c), uint(userID)); err != nil {
+		h.banError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Ban lifted successfully"})
+}
+
+// ListBans возвращает действующие блокировки
+func (h *UserHandler) ListBans(c *gin.Context) {
+	bans, err := h.authService.ListBans()
+	if err != nil {
+		h.banError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bans)
+}
+
+// GetUserBans возвращает историю блокировок пользователя
+func (h *UserHandler) GetUserBans(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	bans, err := h.authService.GetUserBans(uint(userID))
+	if err != nil {
+		h.banError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bans)
+}
+
+func (h *UserHandler) banError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidBanParams):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		h.roleError(c, err)
+	}
+}
+
+// bannedError отвечает 403 с причиной и сроком, если err — блокировка пользователя
+func bannedError(c *gin.Context, err error) bool {
+	var banned *services.BannedError
+	if !errors.As(err, &banned) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, services.BanResponse(banned.Ban))
+	return true
+}
@@ -30,7 +30,7 @@ func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 		return
 	}
 
-	userIDUint, ok := userID.(float64)
+	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
@@ -42,7 +42,7 @@ func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 		return
 	}
 
-	character, err := h.characterService.CreateCharacter(&req, uint(userIDUint))
+	character, err := h.characterService.CreateCharacter(&req, userIDUint, clientInfo(c))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to create character")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create character"})
@@ -60,14 +60,14 @@ func (h *CharacterHandler) GetCharacter(c *gin.Context) {
 		return
 	}
 
-	userIDUint, ok := userID.(float64)
+	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
 	}
 
 	characterID := c.Param("id")
-	character, err := h.characterService.GetCharacterByID(characterID, uint(userIDUint))
+	character, err := h.characterService.GetCharacterByID(characterID, userIDUint)
 	if err != nil {
 		h.logger.WithError(err).WithField("character_id", characterID).Error("Failed to get character")
 		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
@@ -85,7 +85,7 @@ func (h *CharacterHandler) GetCharactersByServer(c *gin.Context) {
 		return
 	}
 
-	userIDUint, ok := userID.(float64)
+	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
@@ -98,7 +98,7 @@ func (h *CharacterHandler) GetCharactersByServer(c *gin.Context) {
 		return
 	}
 
-	characters, err := h.characterService.GetCharactersByServerID(serverID, uint(userIDUint))
+	characters, err := h.characterService.GetCharactersByServerID(serverID, userIDUint)
 	if err != nil {
 		h.logger.WithError(err).WithField("server_id", serverID).Error("Failed to get characters by server")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get characters"})
@@ -116,13 +116,13 @@ func (h *CharacterHandler) GetUserCharacters(c *gin.Context) {
 		return
 	}
 
-	userIDUint, ok := userID.(float64)
+	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
 	}
 
-	characters, err := h.characterService.GetUserCharacters(uint(userIDUint))
+	characters, err := h.characterService.GetUserCharacters(userIDUint)
 	if err != nil {
 		h.logger.WithError(err).WithField("user_id", userIDUint).Error("Failed to get user characters")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user characters"})
@@ -140,7 +140,7 @@ func (h *CharacterHandler) UpdateCharacter(c *gin.Context) {
 		return
 	}
 
-	userIDUint, ok := userID.(float64)
+	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
@@ -153,7 +153,7 @@ func (h *CharacterHandler) UpdateCharacter(c *gin.Context) {
 		return
 	}
 
-	character, err := h.characterService.UpdateCharacter(characterID, &req, uint(userIDUint))
+	character, err := h.characterService.UpdateCharacter(characterID, &req, userIDUint, clientInfo(c))
 	if err != nil {
 		h.logger.WithError(err).WithField("character_id", characterID).Error("Failed to update character")
 		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
@@ -171,14 +171,14 @@ func (h *CharacterHandler) DeleteCharacter(c *gin.Context) {
 		return
 	}
 
-	userIDUint, ok := userID.(float64)
+	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
 	}
 
 	characterID := c.Param("id")
-	if err := h.characterService.DeleteCharacter(characterID, uint(userIDUint)); err != nil {
+	if err := h.characterService.DeleteCharacter(characterID, userIDUint, clientInfo(c)); err != nil {
 		h.logger.WithError(err).WithField("character_id", characterID).Error("Failed to delete character")
 		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
 		return
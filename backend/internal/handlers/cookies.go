@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	refreshTokenCookie = "refresh_token"
+	csrfTokenCookie    = "csrf_token"
+	csrfTokenHeader    = "X-CSRF-Token"
+
+	refreshCookieMaxAge = 7 * 24 * 60 * 60
+)
+
+var errCSRFMismatch = errors.New("csrf token mismatch")
+
+func (h *AuthHandler) cookieMode() bool {
+	return h.cfg.RefreshTokenDelivery == "cookie"
+}
+
+// setRefreshCookies кладет refresh token в HttpOnly cookie, а рядом — CSRF-токен,
+// который фронтенд читает и отправляет в заголовке X-CSRF-Token (double submit)
+func (h *AuthHandler) setRefreshCookies(c *gin.Context, refreshToken string) error {
+	csrf := make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		return err
+	}
+
+	c.SetSameSite(h.cfg.CookieSameSite)
+	c.SetCookie(refreshTokenCookie, refreshToken, refreshCookieMaxAge, h.cfg.CookiePath, h.cfg.CookieDomain, h.cfg.CookieSecure, true)
+	c.SetCookie(csrfTokenCookie, base64.RawURLEncoding.EncodeToString(csrf), refreshCookieMaxAge, "/", h.cfg.CookieDomain, h.cfg.CookieSecure, false)
+	return nil
+}
+
+func (h *AuthHandler) clearRefreshCookies(c *gin.Context) {
+	c.SetSameSite(h.cfg.CookieSameSite)
+	c.SetCookie(refreshTokenCookie, "", -1, h.cfg.CookiePath, h.cfg.CookieDomain, h.cfg.CookieSecure, true)
+	c.SetCookie(csrfTokenCookie, "", -1, "/", h.cfg.CookieDomain, h.cfg.CookieSecure, false)
+}
+
+// refreshTokenFromCookie возвращает refresh token из cookie, если он есть, проверяя CSRF-заголовок
+func (h *AuthHandler) refreshTokenFromCookie(c *gin.Context) (string, bool, error) {
+	if !h.cookieMode() {
+		return "", false, nil
+	}
+	token, err := c.Cookie(refreshTokenCookie)
+	if err != nil || token == "" {
+		return "", false, nil
+	}
+
+	csrfCookie, _ := c.Cookie(csrfTokenCookie)
+	csrfHeader := c.GetHeader(csrfTokenHeader)
+	if csrfCookie == "" || subtle.ConstantTimeCompare([]byte(csrfCookie), []byte(csrfHeader)) != 1 {
+		return "", true, errCSRFMismatch
+	}
+	return token, true, nil
+}
+
+// refreshTokenFromRequest берет refresh token из cookie (cookie-режим) или из JSON-тела
+func (h *AuthHandler) refreshTokenFromRequest(c *gin.Context) (token string, fromCookie bool, ok bool) {
+	token, fromCookie, err := h.refreshTokenFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+		return "", false, false
+	}
+	if fromCookie {
+		return token, true, true
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false, false
+	}
+	return req.RefreshToken, false, true
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceAuthorization — device authorization endpoint (RFC 8628, раздел 3.1)
+func (h *OIDCHandler) DeviceAuthorization(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.oauthErrorJSON(c, err)
+		return
+	}
+
+	auth, err := h.authService.StartDeviceAuthorization(client, c.PostForm("scope"))
+	if err != nil {
+		h.oauthErrorJSON(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, auth)
+}
+
+// GetDeviceRequest возвращает данные для страницы подтверждения входа устройства
+func (h *OIDCHandler) GetDeviceRequest(c *gin.Context) {
+	d, client, err := h.authService.GetDeviceCode(c.Param("code"))
+	if errors.Is(err, services.ErrDeviceCodeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device code not found or expired"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get device code")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get device code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_code":   d.UserCode,
+		"client_id":   client.ID,
+		"client_name": client.Name,
+		"scopes":      strings.Fields(d.Scope),
+		"expires_at":  d.ExpiresAt,
+	})
+}
+
+// ApproveDeviceRequest подтверждает вход устройства; токены оно заберет при следующем опросе /token
+func (h *OIDCHandler) ApproveDeviceRequest(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	h.deviceDecision(c, h.authService.ApproveDeviceCode(c.Param("code"), userID))
+}
+
+// DenyDeviceRequest отклоняет вход устройства
+func (h *OIDCHandler) DenyDeviceRequest(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	h.deviceDecision(c, h.authService.DenyDeviceCode(c.Param("code"), userID))
+}
+
+func (h *OIDCHandler) deviceDecision(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrDeviceCodeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device code not found or expired"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to complete device authorization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete device authorization"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Device authorization completed"})
+}
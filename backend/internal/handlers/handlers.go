@@ -1,28 +1,36 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"user-service/internal/config"
+	"user-service/internal/keys"
+	"user-service/internal/providers"
 	"user-service/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
+const oauthStateCookie = "oauth_state"
+
 type AuthHandler struct {
 	authService *services.AuthService
+	providers   *providers.Registry
 	cfg         *config.Config
 	logger      *logrus.Logger
 }
 
-func NewAuthHandler(authService *services.AuthService, logger *logrus.Logger, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, providerRegistry *providers.Registry, logger *logrus.Logger, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		providers:   providerRegistry,
 		cfg:         cfg,
 		logger:      logger,
 	}
@@ -40,6 +48,19 @@ func NewUserHandler(authService *services.AuthService, logger *logrus.Logger) *U
 	}
 }
 
+// Providers возвращает список доступных провайдеров входа
+func (h *AuthHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.providers.Names()})
+}
+
+// JWKS отдает публичные ключи, которыми другие сервисы проверяют наши access token
+func JWKS(keyring *keys.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": keyring.JWKS()})
+	}
+}
+
 func HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
@@ -48,144 +69,234 @@ func HealthCheck(c *gin.Context) {
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
-	q := url.Values{}
-	q.Set("client_id", h.cfg.DiscordClientID)
-	q.Set("redirect_uri", h.cfg.DiscordRedirectURI)
-	q.Set("response_type", "code")
-	q.Set("scope", "identify")
-	q.Set("prompt", "consent")
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
 
-	authURL := url.URL{
-		Scheme:   "https",
-		Host:     "discord.com",
-		Path:     "/api/oauth2/authorize",
-		RawQuery: q.Encode(),
+	st, err := h.authService.BeginLogin(provider.Name(), c.Query("return_to"), c.Query("scope"))
+	if errors.Is(err, services.ErrInvalidScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start login")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
 	}
 
-	c.Redirect(http.StatusFound, authURL.String())
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), st.State, services.CodeChallengeS256(st.CodeVerifier))
+	if err != nil {
+		h.logger.WithError(err).WithField("provider", provider.Name()).Error("Failed to build authorization URL")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider is unavailable"})
+		return
+	}
+
+	// Привязываем state к браузеру, чтобы callback нельзя было подсунуть чужому пользователю
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, st.State, int(st.ExpiresAt.Sub(st.CreatedAt).Seconds()), "/", "", h.cfg.Environment == "production", true)
+
+	c.Redirect(http.StatusFound, authURL)
 }
 
 func (h *AuthHandler) Callback(c *gin.Context) {
-	code := c.Query("code")
-	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code is required"})
+	provider, ok := h.provider(c)
+	if !ok {
 		return
 	}
 
-	// Обмен code -> access_token
-	tokenReq := url.Values{}
-	tokenReq.Set("client_id", h.cfg.DiscordClientID)
-	tokenReq.Set("client_secret", h.cfg.DiscordClientSecret)
-	tokenReq.Set("grant_type", "authorization_code")
-	tokenReq.Set("code", code)
-	tokenReq.Set("redirect_uri", h.cfg.DiscordRedirectURI)
-
-	tokenResp, err := http.Post(
-		"https://discord.com/api/oauth2/token",
-		"application/x-www-form-urlencoded",
-		bytes.NewBufferString(tokenReq.Encode()),
-	)
+	state := c.Query("state")
+	cookieState, _ := c.Cookie(oauthStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, "/", "", h.cfg.Environment == "production", true)
+
+	if state == "" || cookieState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		h.logger.Warn("OAuth state is missing or does not match cookie")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth state"})
+		return
+	}
+
+	st, err := h.authService.ConsumeLoginState(state)
 	if err != nil {
-		h.logger.WithError(err).Error("Discord token request failed")
-		c.JSON(http.StatusBadGateway, gin.H{"error": "discord token request failed"})
+		if errors.Is(err, services.ErrInvalidState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth state"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to check OAuth state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check oauth state"})
+		return
+	}
+	if st.Provider != provider.Name() {
+		h.logger.Warnf("OAuth state was issued for %s, got callback from %s", st.Provider, provider.Name())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth state"})
 		return
 	}
-	defer tokenResp.Body.Close()
 
-	if tokenResp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "discord token request error", "status": tokenResp.Status})
+	if oauthErr := c.Query("error"); oauthErr != "" {
+		c.Redirect(http.StatusFound, h.cfg.FrontendURL+"/login?error="+url.QueryEscape(oauthErr))
 		return
 	}
 
-	var tokenBody struct {
-		AccessToken  string `json:"access_token"`
-		TokenType    string `json:"token_type"`
-		Scope        string `json:"scope"`
-		ExpiresIn    int    `json:"expires_in"`
-		RefreshToken string `json:"refresh_token"`
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code is required"})
+		return
 	}
-	if err := json.NewDecoder(tokenResp.Body).Decode(&tokenBody); err != nil {
-		h.logger.WithError(err).Error("Failed to decode discord token response")
-		c.JSON(http.StatusBadGateway, gin.H{"error": "invalid token response"})
+
+	// Обмен code -> access_token
+	token, err := provider.Exchange(c.Request.Context(), code, st.CodeVerifier)
+	if err != nil {
+		h.logger.WithError(err).WithField("provider", provider.Name()).Error("Provider token request failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider token request failed"})
 		return
 	}
 
 	// Запрос пользователя
-	req, _ := http.NewRequest("GET", "https://discord.com/api/users/@me", nil)
-	req.Header.Set("Authorization", tokenBody.TokenType+" "+tokenBody.AccessToken)
-	userResp, err := http.DefaultClient.Do(req)
+	identity, err := provider.FetchIdentity(c.Request.Context(), token)
 	if err != nil {
-		h.logger.WithError(err).Error("Discord user request failed")
-		c.JSON(http.StatusBadGateway, gin.H{"error": "discord user request failed"})
+		h.logger.WithError(err).WithField("provider", provider.Name()).Error("Provider user request failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider user request failed"})
 		return
 	}
-	defer userResp.Body.Close()
-	if userResp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "discord user request error", "status": userResp.Status})
+
+	if st.LinkUserID != 0 {
+		h.finishLink(c, st, identity, token)
 		return
 	}
 
-	var discordUser struct {
-		ID            string `json:"id"`
-		Username      string `json:"username"`
-		Discriminator string `json:"discriminator"`
-		GlobalName    string `json:"global_name"`
-		Avatar        string `json:"avatar"`
+	// Членство на серверах Discord (DISCORD_GUILD_IDS) проверяем до того, как создать пользователя
+	var discordToken *providers.Token
+	if provider.Name() == "discord" {
+		discordToken = token
 	}
-	if err := json.NewDecoder(userResp.Body).Decode(&discordUser); err != nil {
-		h.logger.WithError(err).Error("Failed to decode discord user response")
-		c.JSON(http.StatusBadGateway, gin.H{"error": "invalid user response"})
+	guildRoles, err := h.authService.CheckIdentityGuildMembership(c.Request.Context(), identity, discordToken, clientInfo(c))
+	if errors.Is(err, services.ErrGuildMembershipRequired) {
+		c.Redirect(http.StatusFound, h.cfg.FrontendURL+"/login?error=guild_membership_required&error_description="+url.QueryEscape(err.Error()))
 		return
 	}
-
-	// Сборка URL аватара
-	avatarURL := ""
-	if discordUser.Avatar != "" {
-		avatarURL = "https://cdn.discordapp.com/avatars/" + discordUser.ID + "/" + discordUser.Avatar + ".png"
-	}
-
-	displayName := discordUser.GlobalName
-	if displayName == "" {
-		displayName = discordUser.Username
+	if err != nil {
+		h.logger.WithError(err).WithField("provider", provider.Name()).Error("Failed to check Discord guild membership")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to check Discord server membership"})
+		return
 	}
 
-	user, err := h.authService.CreateOrUpdateUser(discordUser.ID, displayName, discordUser.Discriminator, avatarURL)
+	user, err := h.authService.CreateOrUpdateUser(identity, clientInfo(c))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to create/update user")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process user"})
 		return
 	}
+	h.storeProviderGrant(identity, token)
+
+	var banned *services.BannedError
+	if err := h.authService.CheckBan(user.ID); errors.As(err, &banned) {
+		h.authService.RecordLoginFailure(user.ID, services.LoginFailureBanned, clientInfo(c))
+		q := url.Values{}
+		q.Set("error", "account_"+banned.Ban.Kind)
+		q.Set("reason", banned.Ban.Reason)
+		if banned.Ban.ExpiresAt != nil {
+			q.Set("until", banned.Ban.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		c.Redirect(http.StatusFound, h.cfg.FrontendURL+"/login?"+q.Encode())
+		return
+	}
+
+	// Роль по ролям на сервере Discord (DISCORD_GUILD_ROLES)
+	h.authService.SyncGuildRole(user, guildRoles)
+
+	// Если включен или обязателен второй фактор, токены выдаст POST /mfa/verify
+	challenge, err := h.authService.BeginMFAChallenge(user, st.Scope, st.ReturnTo)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start MFA challenge")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start mfa challenge"})
+		return
+	}
+	if challenge != nil {
+		q := url.Values{}
+		q.Set("challenge", challenge.Token)
+		if challenge.Enroll {
+			q.Set("enroll", "1")
+		}
+		c.Redirect(http.StatusFound, h.cfg.FrontendURL+"/mfa?"+q.Encode())
+		return
+	}
 
-	accessToken, refreshToken, err := h.authService.GenerateTokens(user)
+	accessToken, refreshToken, err := h.authService.GenerateTokens(user, strings.Fields(st.Scope), clientInfo(c))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to generate tokens")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
 		return
 	}
 
-	// Перенаправляем на фронтенд с токенами
-	callbackURL := h.cfg.FrontendURL + "/callback?access_token=" + accessToken + "&refresh_token=" + refreshToken
-	c.Redirect(http.StatusFound, callbackURL)
+	q := url.Values{}
+	if h.cookieMode() {
+		// Токены не попадают в URL: фронтенд получит access token через POST /refresh
+		if err := h.setRefreshCookies(c, refreshToken); err != nil {
+			h.logger.WithError(err).Error("Failed to set refresh cookie")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set refresh cookie"})
+			return
+		}
+	} else {
+		// Перенаправляем на фронтенд с токенами
+		q.Set("access_token", accessToken)
+		q.Set("refresh_token", refreshToken)
+	}
+	if st.ReturnTo != "" {
+		q.Set("return_to", st.ReturnTo)
+	}
+	c.Redirect(http.StatusFound, h.cfg.FrontendURL+"/callback?"+q.Encode())
 }
 
-func (h *AuthHandler) Refresh(c *gin.Context) {
-	var req struct {
-		RefreshToken string `json:"refresh_token" binding:"required"`
+// provider определяет провайдера по параметру маршрута; /login и /callback без параметра — это Discord
+func (h *AuthHandler) provider(c *gin.Context) (providers.Provider, bool) {
+	name := c.Param("provider")
+	if name == "" {
+		name = "discord"
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	provider, ok := h.providers.Get(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return nil, false
+	}
+	return provider, true
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	presented, fromCookie, ok := h.refreshTokenFromRequest(c)
+	if !ok {
 		return
 	}
 
-	accessToken, err := h.authService.RefreshAccessToken(req.RefreshToken)
+	accessToken, refreshToken, err := h.authService.RefreshTokens(presented, clientInfo(c))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to refresh token")
+		if fromCookie {
+			h.clearRefreshCookies(c)
+		}
+		if bannedError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrGuildMembershipRequired) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
+	if fromCookie {
+		if err := h.setRefreshCookies(c, refreshToken); err != nil {
+			h.logger.WithError(err).Error("Failed to set refresh cookie")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set refresh cookie"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"access_token": accessToken})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"access_token": accessToken,
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
 	})
 }
 
@@ -196,18 +307,29 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 		return
 	}
 
-	userIDUint, ok := userID.(float64)
+	userIDUint, ok := userID.(uint)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
 		return
 	}
 
-	user, err := h.authService.GetUserByID(uint(userIDUint))
+	user, err := h.authService.GetUserByID(userIDUint)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get user")
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	// Фронтенд по правам решает, какие разделы админки показывать
+	user.Permissions = h.authService.RolePermissions(user.Role)
+	if actorID, impersonated := c.Get("actorID"); impersonated {
+		impersonator, err := h.authService.Impersonator(actorID.(uint))
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to get impersonator")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+			return
+		}
+		user.ImpersonatedBy = impersonator
+	}
 
 	c.JSON(http.StatusOK, user)
 }
@@ -234,11 +356,15 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.UpdateUserRole(uint(userID), req.Role); err != nil {
-		h.logger.WithError(err).Error("Failed to update user role")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+	if err := h.authService.UpdateUserRole(currentPermissions(c), uint(userID), req.Role, clientInfo(c)); err != nil {
+		if errors.Is(err, services.ErrRoleNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+			return
+		}
+		h.roleError(c, err)
 		return
 	}
+	h.logger.WithField("user_id", userID).Infof("Role %s assigned by %s", req.Role, adminActor(c))
 
 	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
 }
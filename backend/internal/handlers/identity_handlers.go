@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"user-service/internal/models"
+	"user-service/internal/providers"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetIdentities возвращает привязанные к пользователю учетные записи провайдеров
+func (h *AuthHandler) GetIdentities(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.authService.GetUserIdentities(userID)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to get identities")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// StartLink начинает привязку новой учетной записи и возвращает URL провайдера
+func (h *AuthHandler) StartLink(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+
+	st, err := h.authService.BeginLink(provider.Name(), userID, c.Query("return_to"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start identity linking")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start linking"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), st.State, services.CodeChallengeS256(st.CodeVerifier))
+	if err != nil {
+		h.logger.WithError(err).WithField("provider", provider.Name()).Error("Failed to build authorization URL")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider is unavailable"})
+		return
+	}
+
+	// Cookie ставится в ответ на запрос фронтенда (credentials: include), поэтому
+	// чужую ссылку на привязку нельзя завершить в браузере другого пользователя
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, st.State, int(st.ExpiresAt.Sub(st.CreatedAt).Seconds()), "/", "", h.cfg.Environment == "production", true)
+
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// UnlinkIdentity отвязывает учетную запись провайдера
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	identityID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+
+	err = h.authService.UnlinkIdentity(userID, uint(identityID))
+	switch {
+	case errors.Is(err, services.ErrLastIdentity):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last login method"})
+		return
+	case errors.Is(err, services.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to unlink identity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
+
+// finishLink завершает привязку в callback и возвращает пользователя на фронтенд
+func (h *AuthHandler) finishLink(c *gin.Context, st *models.OAuthState, identity *providers.Identity, token *providers.Token) {
+	q := url.Values{}
+	if _, err := h.authService.LinkIdentity(st.LinkUserID, identity); err != nil {
+		if errors.Is(err, services.ErrIdentityTaken) {
+			q.Set("link_error", "identity_taken")
+		} else {
+			h.logger.WithError(err).Error("Failed to link identity")
+			q.Set("link_error", "link_failed")
+		}
+	} else {
+		h.storeProviderGrant(identity, token)
+		q.Set("linked", identity.Provider)
+	}
+
+	returnTo := st.ReturnTo
+	if returnTo == "" {
+		returnTo = "/profile"
+	}
+	target, _ := url.Parse(returnTo)
+	target.RawQuery = q.Encode()
+	c.Redirect(http.StatusFound, h.cfg.FrontendURL+target.String())
+}
+
+// storeProviderGrant сохраняет токены провайдера для фонового обновления профиля; вход из-за ошибки не прерывается
+func (h *AuthHandler) storeProviderGrant(identity *providers.Identity, token *providers.Token) {
+	if err := h.authService.StoreProviderGrant(identity, token); err != nil {
+		h.logger.WithError(err).WithField("provider", identity.Provider).Warn("Failed to store provider tokens")
+	}
+}
+
+// currentUserID достает ID пользователя, который AuthMiddleware положил в контекст
+func currentUserID(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return 0, false
+	}
+
+	id, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return 0, false
+	}
+	return id, true
+}
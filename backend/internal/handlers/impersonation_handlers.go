@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Impersonate выдает администратору токен от имени пользователя
+func (h *UserHandler) Impersonate(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, imp, err := h.authService.Impersonate(actorID, currentPermissions(c), uint(userID), req.Reason, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImpersonation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.roleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  token,
+		"token_type":    "Bearer",
+		"expires_in":    int(imp.ExpiresAt.Sub(imp.CreatedAt).Seconds()),
+		"impersonation": imp,
+	})
+}
+
+// ListImpersonations возвращает последние входы от имени пользователей
+func (h *UserHandler) ListImpersonations(c *gin.Context) {
+	result, err := h.authService.ListImpersonations()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list impersonations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list impersonations"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetImpersonationRequests возвращает запросы, сделанные от имени пользователя
+func (h *UserHandler) GetImpersonationRequests(c *gin.Context) {
+	requests, err := h.authService.GetImpersonationRequests(c.Param("id"))
+	if errors.Is(err, services.ErrImpersonationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Impersonation not found"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get impersonation requests")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get impersonation requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// EndImpersonation досрочно отзывает токен impersonation из админки
+func (h *UserHandler) EndImpersonation(c *gin.Context) {
+	h.endImpersonation(c, c.Param("id"))
+}
+
+// EndCurrentImpersonation — выход администратора из режима «от имени пользователя»
+func (h *UserHandler) EndCurrentImpersonation(c *gin.Context) {
+	id, ok := c.Get("impersonationID")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not an impersonation session"})
+		return
+	}
+	impersonationID, _ := id.(string)
+	h.endImpersonation(c, impersonationID)
+}
+
+func (h *UserHandler) endImpersonation(c *gin.Context, id string) {
+	err := h.authService.EndImpersonation(id)
+	if errors.Is(err, services.ErrImpersonationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Impersonation not found"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to end impersonation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end impersonation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonation ended"})
+}
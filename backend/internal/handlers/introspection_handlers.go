@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Introspect — RFC 7662: сервисы проверяют токен по текущему состоянию БД
+// (отозванные сессии, удаленные пользователи, смена роли)
+func (h *OIDCHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.oauthErrorJSON(c, err)
+		return
+	}
+	// Публичный клиент не может доказать, кто он, — такому клиенту состояние чужих токенов не отдаем
+	if client.Public() {
+		h.oauthErrorJSON(c, &services.OAuthError{Code: "invalid_client", Description: "introspection requires a confidential client"})
+		return
+	}
+
+	result, err := h.authService.IntrospectToken(c.PostForm("token"), c.PostForm("token_type_hint"))
+	if err != nil {
+		h.logger.WithError(err).WithField("client_id", client.ID).Error("Token introspection failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"user-service/internal/keys"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type KeyHandler struct {
+	keyService *services.KeyRotationService
+	keyring    *keys.Keyring
+	logger     *logrus.Logger
+}
+
+func NewKeyHandler(keyService *services.KeyRotationService, keyring *keys.Keyring, logger *logrus.Logger) *KeyHandler {
+	return &KeyHandler{
+		keyService: keyService,
+		keyring:    keyring,
+		logger:     logger,
+	}
+}
+
+// ListKeys возвращает ключи подписи и последние события ротации
+func (h *KeyHandler) ListKeys(c *gin.Context) {
+	list, err := h.keyService.ListKeys()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list signing keys")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list signing keys"})
+		return
+	}
+	events, err := h.keyService.ListEvents(50)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list signing key events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list signing key events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current_kid": h.keyring.SigningKey().ID,
+		"keys":        list,
+		"events":      events,
+	})
+}
+
+// GenerateKey создает новый ключ в статусе pending
+func (h *KeyHandler) GenerateKey(c *gin.Context) {
+	var req struct {
+		Algorithm string `json:"algorithm"`
+	}
+	// Тело необязательно: по умолчанию берется алгоритм текущего ключа
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = h.keyring.SigningKey().Algorithm
+	}
+
+	key, err := h.keyService.GenerateKey(req.Algorithm, adminActor(c))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to generate signing key")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to generate signing key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// PromoteKey делает ключ текущим ключом подписи
+func (h *KeyHandler) PromoteKey(c *gin.Context) {
+	err := h.keyService.PromoteKey(c.Param("kid"), adminActor(c))
+	if errors.Is(err, services.ErrSigningKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Signing key not found or cannot be promoted"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to promote signing key")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote signing key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signing key promoted successfully"})
+}
+
+// RetireKey выводит ключ из использования
+func (h *KeyHandler) RetireKey(c *gin.Context) {
+	err := h.keyService.RetireKey(c.Param("kid"), adminActor(c))
+	if errors.Is(err, services.ErrSigningKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Signing key not found or cannot be retired"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to retire signing key")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retire signing key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signing key retired successfully"})
+}
+
+// adminActor — кто выполняет действие, для журналов
+func adminActor(c *gin.Context) string {
+	userID, _ := c.Get("userID")
+	return fmt.Sprintf("user:%v", userID)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLoginHistory возвращает последние входы и обновления сессий текущего пользователя, в том числе неудачные
+func (h *UserHandler) GetLoginHistory(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.authService.GetLoginHistory(userID)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to get login history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get login history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// GetLoginAlerts возвращает уведомления о входах с новых устройств и из новых сетей
+func (h *UserHandler) GetLoginAlerts(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	alerts, err := h.authService.GetLoginAlerts(userID)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to get login alerts")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get login alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+// MarkLoginAlertsRead отмечает все уведомления о входах прочитанными
+func (h *UserHandler) MarkLoginAlertsRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.MarkLoginAlertsRead(userID); err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to mark login alerts read")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark login alerts read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login alerts marked as read"})
+}
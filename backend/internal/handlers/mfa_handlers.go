@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// EnrollMFAChallenge выдает секрет для приложения-аутентификатора, когда роль требует 2FA,
+// а пользователь его еще не подключил (вход остановлен в Callback с enroll=1)
+func (h *AuthHandler) EnrollMFAChallenge(c *gin.Context) {
+	var req struct {
+		Challenge string `json:"challenge" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.authService.EnrollWithChallenge(req.Challenge)
+	if err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// VerifyMFAChallenge завершает вход: проверяет код второго фактора и выдает токены
+func (h *AuthHandler) VerifyMFAChallenge(c *gin.Context) {
+	var req struct {
+		Challenge string `json:"challenge" binding:"required"`
+		Code      string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	login, err := h.authService.CompleteMFAChallenge(req.Challenge, req.Code, clientInfo(c))
+	if err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+
+	resp, ok := h.loginResponse(c, login.AccessToken, login.RefreshToken)
+	if !ok {
+		return
+	}
+	if login.ReturnTo != "" {
+		resp["return_to"] = login.ReturnTo
+	}
+	if login.BackupCodes != nil {
+		resp["backup_codes"] = login.BackupCodes
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+// loginResponse — ответ на вход без редиректа (второй фактор, passkey): в cookie-режиме
+// refresh token уходит в cookie, как после /callback
+func (h *AuthHandler) loginResponse(c *gin.Context, accessToken, refreshToken string) (gin.H, bool) {
+	resp := gin.H{"access_token": accessToken}
+	if !h.cookieMode() {
+		resp["refresh_token"] = refreshToken
+		return resp, true
+	}
+	if err := h.setRefreshCookies(c, refreshToken); err != nil {
+		h.logger.WithError(err).Error("Failed to set refresh cookie")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set refresh cookie"})
+		return nil, false
+	}
+	return resp, true
+}
+
+func (h *UserHandler) GetMFAStatus(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	status, err := h.authService.GetMFAStatus(userID, c.GetString("userRole"))
+	if err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// BeginMFAEnrollment начинает подключение 2FA; повторный вызов выдает новый секрет
+func (h *UserHandler) BeginMFAEnrollment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	enrollment, err := h.authService.BeginMFAEnrollment(userID)
+	if err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFAEnrollment включает 2FA по коду из приложения; резервные коды показываются один раз
+func (h *UserHandler) ConfirmMFAEnrollment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	code, ok := bindMFACode(c)
+	if !ok {
+		return
+	}
+	backupCodes, err := h.authService.ConfirmMFAEnrollment(userID, code)
+	if err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"backup_codes": backupCodes})
+}
+
+func (h *UserHandler) DisableMFA(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	code, ok := bindMFACode(c)
+	if !ok {
+		return
+	}
+	if err := h.authService.DisableMFA(userID, c.GetString("userRole"), code); err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateBackupCodes заменяет резервные коды; нужен код из приложения
+func (h *UserHandler) RegenerateBackupCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	code, ok := bindMFACode(c)
+	if !ok {
+		return
+	}
+	backupCodes, err := h.authService.RegenerateBackupCodes(userID, code)
+	if err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"backup_codes": backupCodes})
+}
+
+// ResetMFA сбрасывает 2FA пользователя, потерявшего доступ к приложению и резервным кодам
+func (h *UserHandler) ResetMFA(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if err := h.authService.ResetMFA(currentPermissions(c), uint(userID)); err != nil {
+		mfaError(c, h.logger, err)
+		return
+	}
+	h.logger.WithField("user_id", userID).Warnf("MFA reset by %s", adminActor(c))
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// SetRoleRequireMFA включает или выключает обязательный второй фактор для роли
+func (h *UserHandler) SetRoleRequireMFA(c *gin.Context) {
+	var req struct {
+		Required *bool `json:"required" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.authService.SetRoleRequireMFA(c.Param("name"), *req.Required); err != nil {
+		h.roleError(c, err)
+		return
+	}
+	h.logger.WithField("role", c.Param("name")).Infof("Role MFA requirement set to %t by %s", *req.Required, adminActor(c))
+	c.JSON(http.StatusOK, gin.H{"name": c.Param("name"), "require_mfa": *req.Required})
+}
+
+func bindMFACode(c *gin.Context) (string, bool) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return req.Code, true
+}
+
+func mfaError(c *gin.Context, logger *logrus.Logger, err error) {
+	if bannedError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrMFAChallengeInvalid), errors.Is(err, services.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFALocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled),
+		errors.Is(err, services.ErrMFANotEnrolling):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFARequiredByRole), errors.Is(err, services.ErrRoleEscalation):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("MFA operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "MFA operation failed"})
+	}
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"user-service/internal/config"
+	"user-service/internal/keys"
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// OIDCHandler — мы как OpenID Connect провайдер для внутренних приложений
+type OIDCHandler struct {
+	authService *services.AuthService
+	keyring     *keys.Keyring
+	cfg         *config.Config
+	logger      *logrus.Logger
+}
+
+func NewOIDCHandler(authService *services.AuthService, keyring *keys.Keyring, logger *logrus.Logger, cfg *config.Config) *OIDCHandler {
+	return &OIDCHandler{
+		authService: authService,
+		keyring:     keyring,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+// Discovery отдает /.well-known/openid-configuration
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	issuer := h.authService.Issuer()
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                         issuer,
+		"authorization_endpoint":                         issuer + "/authorize",
+		"token_endpoint":                                 issuer + "/token",
+		"userinfo_endpoint":                              issuer + "/userinfo",
+		"jwks_uri":                                       issuer + "/.well-known/jwks.json",
+		"device_authorization_endpoint":                  issuer + "/device/code",
+		"response_types_supported":                       []string{"code"},
+		"grant_types_supported":                          []string{"authorization_code", "client_credentials", services.DeviceCodeGrantType},
+		"subject_types_supported":                        []string{"public"},
+		"id_token_signing_alg_values_supported":          []string{h.keyring.SigningAlgorithm()},
+		"scopes_supported":                               services.SupportedOIDCScopes,
+		"token_endpoint_auth_methods_supported":          []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"claims_supported":                               []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "preferred_username", "picture", "email"},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
+
+// Authorize принимает запрос клиента и отправляет пользователя на страницу подтверждения фронтенда
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	// GET — параметры в query, POST — в теле формы
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "malformed request"})
+		return
+	}
+	form := c.Request.Form
+
+	client, redirectURI, err := h.authService.ResolveRedirect(form.Get("client_id"), form.Get("redirect_uri"))
+	if err != nil {
+		// redirect_uri не проверен — перенаправлять на него нельзя
+		var oauthErr *services.OAuthError
+		if errors.As(err, &oauthErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to resolve OAuth client")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	req, err := h.authService.BeginAuthorization(client, redirectURI, services.AuthorizeParams{
+		ResponseType:        form.Get("response_type"),
+		Scope:               form.Get("scope"),
+		State:               form.Get("state"),
+		Nonce:               form.Get("nonce"),
+		CodeChallenge:       form.Get("code_challenge"),
+		CodeChallengeMethod: form.Get("code_challenge_method"),
+	})
+	var oauthErr *services.OAuthError
+	if errors.As(err, &oauthErr) {
+		c.Redirect(http.StatusFound, h.authService.AuthorizationErrorRedirect(redirectURI, form.Get("state"), oauthErr))
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start authorization")
+		c.Redirect(http.StatusFound, h.authService.AuthorizationErrorRedirect(redirectURI, form.Get("state"),
+			&services.OAuthError{Code: "server_error", Description: "failed to start authorization"}))
+		return
+	}
+
+	// Фронтенд покажет, какое приложение просит доступ; если пользователь не вошел,
+	// он пройдет /login с return_to обратно на эту страницу
+	c.Redirect(http.StatusFound, h.cfg.FrontendURL+"/authorize?request_id="+url.QueryEscape(req.ID))
+}
+
+// GetAuthorizationRequest возвращает данные для страницы подтверждения
+func (h *OIDCHandler) GetAuthorizationRequest(c *gin.Context) {
+	req, client, err := h.authService.GetAuthorizationRequest(c.Param("id"))
+	if errors.Is(err, services.ErrAuthorizationRequestNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Authorization request not found or expired"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get authorization request")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get authorization request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":           req.ID,
+		"client_id":    client.ID,
+		"client_name":  client.Name,
+		"redirect_uri": req.RedirectURI,
+		"scopes":       strings.Fields(req.Scope),
+		"expires_at":   req.ExpiresAt,
+	})
+}
+
+// ApproveAuthorization подтверждает вход в приложение; фронтенд переходит по redirect_url
+func (h *OIDCHandler) ApproveAuthorization(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	redirectURL, err := h.authService.ApproveAuthorization(c.Param("id"), userID)
+	h.authorizationDecision(c, redirectURL, err)
+}
+
+// DenyAuthorization отклоняет вход в приложение
+func (h *OIDCHandler) DenyAuthorization(c *gin.Context) {
+	redirectURL, err := h.authService.DenyAuthorization(c.Param("id"))
+	h.authorizationDecision(c, redirectURL, err)
+}
+
+func (h *OIDCHandler) authorizationDecision(c *gin.Context, redirectURL string, err error) {
+	if errors.Is(err, services.ErrAuthorizationRequestNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Authorization request not found or expired"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to complete authorization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete authorization"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirect_url": redirectURL})
+}
+
+// Token — token endpoint (RFC 6749, раздел 3.2)
+func (h *OIDCHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.oauthErrorJSON(c, err)
+		return
+	}
+
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		tokens, err := h.authService.ExchangeAuthorizationCode(client, c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"))
+		if err != nil {
+			h.oauthErrorJSON(c, err)
+			return
+		}
+		resp := gin.H{
+			"access_token": tokens.AccessToken,
+			"token_type":   "Bearer",
+			"expires_in":   tokens.ExpiresIn,
+			"scope":        tokens.Scope,
+		}
+		if tokens.IDToken != "" {
+			resp["id_token"] = tokens.IDToken
+		}
+		c.JSON(http.StatusOK, resp)
+	case "client_credentials":
+		tokens, err := h.authService.IssueClientCredentialsToken(client, c.PostForm("scope"))
+		if err != nil {
+			h.oauthErrorJSON(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"access_token": tokens.AccessToken,
+			"token_type":   "Bearer",
+			"expires_in":   tokens.ExpiresIn,
+			"scope":        tokens.Scope,
+		})
+	case services.DeviceCodeGrantType:
+		tokens, err := h.authService.ExchangeDeviceCode(client, c.PostForm("device_code"), clientInfo(c))
+		if err != nil {
+			h.oauthErrorJSON(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"access_token":  tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"token_type":    "Bearer",
+			"expires_in":    tokens.ExpiresIn,
+		})
+	case "":
+		h.oauthErrorJSON(c, &services.OAuthError{Code: "invalid_request", Description: "grant_type is required"})
+	default:
+		h.oauthErrorJSON(c, &services.OAuthError{Code: "unsupported_grant_type", Description: "grant_type is not supported"})
+	}
+}
+
+// UserInfo — userinfo endpoint; набор claims зависит от scope токена
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Токену приложения нужен openid, сессии фронтенда — profile (тогда отдаем все claims);
+	// личный API-ключ получает claims по своим scope
+	value, _ := c.Get("scopes")
+	scopes, _ := value.([]string)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	_, isClientToken := c.Get("clientID")
+	_, isAPIToken := c.Get("apiTokenID")
+	required := ""
+	switch {
+	case isClientToken:
+		required = "openid"
+	case !isAPIToken:
+		required = services.ScopeProfile
+	}
+	if required != "" && !containsScope(scopes, required) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+required+`"`)
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+		return
+	}
+	if !isClientToken && !isAPIToken {
+		scopes = nil
+	}
+
+	claims, err := h.authService.UserInfo(userID, scopes)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to build userinfo")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+		return
+	}
+	c.JSON(http.StatusOK, claims)
+}
+
+// ListClients возвращает зарегистрированные приложения
+func (h *OIDCHandler) ListClients(c *gin.Context) {
+	clients, err := h.authService.ListOAuthClients()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list OAuth clients")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list clients"})
+		return
+	}
+	c.JSON(http.StatusOK, clients)
+}
+
+// CreateClient регистрирует приложение; client_secret показывается только в этом ответе
+func (h *OIDCHandler) CreateClient(c *gin.Context) {
+	var req struct {
+		Name         string   `json:"name" binding:"required"`
+		GrantTypes   []string `json:"grant_types"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Public       bool     `json:"public"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, secret, err := h.authService.CreateOAuthClient(services.OAuthClientParams{
+		Name:         req.Name,
+		GrantTypes:   req.GrantTypes,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+	})
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to create OAuth client")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{"client": client}
+	if secret != "" {
+		resp["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RotateClientSecret выдает клиенту новый секрет; старый перестает действовать сразу
+func (h *OIDCHandler) RotateClientSecret(c *gin.Context) {
+	secret, err := h.authService.RotateClientSecret(c.Param("id"))
+	if errors.Is(err, services.ErrOAuthClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Confidential client not found"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to rotate client secret")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate client secret"})
+		return
+	}
+	h.logger.WithField("client_id", c.Param("id")).Infof("Client secret rotated by %s", adminActor(c))
+	c.JSON(http.StatusOK, gin.H{"client_secret": secret})
+}
+
+// DisableClient отключает клиента
+func (h *OIDCHandler) DisableClient(c *gin.Context) {
+	h.setClientDisabled(c, true)
+}
+
+// EnableClient снова включает отключенного клиента
+func (h *OIDCHandler) EnableClient(c *gin.Context) {
+	h.setClientDisabled(c, false)
+}
+
+func (h *OIDCHandler) setClientDisabled(c *gin.Context, disabled bool) {
+	err := h.authService.SetClientDisabled(c.Param("id"), disabled)
+	if errors.Is(err, services.ErrOAuthClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update client status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update client status"})
+		return
+	}
+	h.logger.WithField("client_id", c.Param("id")).Infof("Client disabled=%v by %s", disabled, adminActor(c))
+	c.JSON(http.StatusOK, gin.H{"message": "Client status updated successfully"})
+}
+
+// authenticateClient достает учетные данные клиента из Basic auth или из тела формы
+func (h *OIDCHandler) authenticateClient(c *gin.Context) (*models.OAuthClient, error) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749, раздел 2.3.1: перед Basic значения кодируются как form-urlencoded
+		if v, err := url.QueryUnescape(clientID); err == nil {
+			clientID = v
+		}
+		if v, err := url.QueryUnescape(secret); err == nil {
+			secret = v
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+	return h.authService.AuthenticateClient(clientID, secret)
+}
+
+// oauthErrorJSON отвечает в формате RFC 6749: {"error": "...", "error_description": "..."}
+func (h *OIDCHandler) oauthErrorJSON(c *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		h.logger.WithError(err).Error("OAuth request failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="token"`)
+	}
+	c.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
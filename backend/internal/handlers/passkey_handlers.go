@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// BeginPasskeyLogin выдает параметры для navigator.credentials.get(); ?scope — как в /login
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	opts, err := h.authService.BeginPasskeyLogin(c.Query("scope"))
+	if errors.Is(err, services.ErrInvalidScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		passkeyError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKey": opts})
+}
+
+// FinishPasskeyLogin проверяет ответ аутентификатора и выдает токены
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var cred services.PasskeyCredential
+	if err := c.ShouldBindJSON(&cred); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, refreshToken, err := h.authService.FinishPasskeyLogin(&cred, clientInfo(c))
+	if err != nil {
+		passkeyError(c, h.logger, err)
+		return
+	}
+	resp, ok := h.loginResponse(c, accessToken, refreshToken)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *UserHandler) GetPasskeys(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	passkeys, err := h.authService.GetPasskeys(userID)
+	if err != nil {
+		passkeyError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, passkeys)
+}
+
+// BeginPasskeyRegistration выдает параметры для navigator.credentials.create()
+func (h *UserHandler) BeginPasskeyRegistration(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	opts, err := h.authService.BeginPasskeyRegistration(userID)
+	if err != nil {
+		passkeyError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKey": opts})
+}
+
+func (h *UserHandler) FinishPasskeyRegistration(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name       string                     `json:"name" binding:"required"`
+		Credential services.PasskeyCredential `json:"credential" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	passkey, err := h.authService.FinishPasskeyRegistration(userID, req.Name, &req.Credential)
+	if err != nil {
+		passkeyError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusCreated, passkey)
+}
+
+func (h *UserHandler) DeletePasskey(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	passkeyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+	if err := h.authService.DeletePasskey(userID, uint(passkeyID)); err != nil {
+		passkeyError(c, h.logger, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
+
+func passkeyError(c *gin.Context, logger *logrus.Logger, err error) {
+	if bannedError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidPasskey), errors.Is(err, services.ErrPasskeyChallengeInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPasskeyParams):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPasskeyNotFound), errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPasskeyExists), errors.Is(err, services.ErrTooManyPasskeys):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGuildMembershipRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLastIdentity):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last login method"})
+	default:
+		logger.WithError(err).Error("Passkey operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Passkey operation failed"})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"user-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Revoke — RFC 7009: отзыв refresh token (вся сессия) или access token (по jti).
+// Клиенты OIDC аутентифицируются, наш фронтенд может отозвать свой токен без учетных данных.
+func (h *OIDCHandler) Revoke(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	var client *models.OAuthClient
+	if h.hasClientCredentials(c) {
+		var err error
+		client, err = h.authenticateClient(c)
+		if err != nil {
+			h.oauthErrorJSON(c, err)
+			return
+		}
+	}
+
+	if err := h.authService.RevokeToken(c.PostForm("token"), client); err != nil {
+		h.logger.WithError(err).Error("Token revocation failed")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server_error"})
+		return
+	}
+	// Ответ одинаковый и для неизвестных токенов, чтобы по нему нельзя было проверять токены
+	c.Status(http.StatusOK)
+}
+
+func (h *OIDCHandler) hasClientCredentials(c *gin.Context) bool {
+	if _, _, ok := c.Request.BasicAuth(); ok {
+		return true
+	}
+	return c.PostForm("client_id") != ""
+}
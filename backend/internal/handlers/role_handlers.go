@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRoles возвращает роли и их права
+func (h *UserHandler) ListRoles(c *gin.Context) {
+	roles, err := h.authService.ListRoles()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list roles")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roles"})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// ListPermissions возвращает все права, которые можно выдать роли
+func (h *UserHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.authService.ListPermissions()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list permissions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list permissions"})
+		return
+	}
+	c.JSON(http.StatusOK, permissions)
+}
+
+func (h *UserHandler) CreateRole(c *gin.Context) {
+	var req struct {
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.authService.CreateRole(currentPermissions(c), services.RoleParams{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		h.roleError(c, err)
+		return
+	}
+	h.logger.WithField("role", role.Name).Infof("Role created by %s", adminActor(c))
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole заменяет описание и набор прав роли
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	var req struct {
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.authService.UpdateRole(currentPermissions(c), services.RoleParams{
+		Name:        c.Param("name"),
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		h.roleError(c, err)
+		return
+	}
+	h.logger.WithField("role", role.Name).Infof("Role permissions updated by %s", adminActor(c))
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *UserHandler) DeleteRole(c *gin.Context) {
+	if err := h.authService.DeleteRole(c.Param("name")); err != nil {
+		h.roleError(c, err)
+		return
+	}
+	h.logger.WithField("role", c.Param("name")).Infof("Role deleted by %s", adminActor(c))
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+func (h *UserHandler) roleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound), errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRoleExists), errors.Is(err, services.ErrRoleInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRoleProtected), errors.Is(err, services.ErrRoleEscalation):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRoleParams):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Role operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Role operation failed"})
+	}
+}
+
+// currentPermissions — права роли текущего пользователя (кладет AuthMiddleware)
+func currentPermissions(c *gin.Context) []string {
+	value, _ := c.Get("permissions")
+	permissions, _ := value.([]string)
+	return permissions
+}
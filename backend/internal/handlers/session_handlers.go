@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logout завершает сессию, к которой относится refresh token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	refreshToken, fromCookie, ok := h.refreshTokenFromRequest(c)
+	if !ok {
+		return
+	}
+	if fromCookie {
+		h.clearRefreshCookies(c)
+	}
+
+	if err := h.authService.Logout(refreshToken); err != nil {
+		h.logger.WithError(err).Error("Failed to logout")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// GetSessions возвращает активные сессии текущего пользователя
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.authService.GetSessions(userID, c.GetString("sessionID"))
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to get sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession завершает одну сессию текущего пользователя
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	err := h.authService.RevokeSession(userID, c.Param("id"))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to revoke session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions завершает все сессии текущего пользователя
+func (h *UserHandler) RevokeAllSessions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.RevokeAllSessions(userID); err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to revoke sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked successfully"})
+}
+
+// RevokeUserSessions завершает все сессии указанного пользователя (админ)
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	err = h.authService.RevokeUserSessions(currentPermissions(c), uint(userID))
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrRoleEscalation):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to revoke user sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User sessions revoked successfully"})
+}
+
+// clientInfo собирает IP и User-Agent запроса для сохранения в сессии
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("requestID"),
+		Actor:     c.GetString("actor"),
+	}
+}
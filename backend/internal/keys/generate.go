@@ -0,0 +1,70 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+)
+
+// Generate создает новый ключ для algorithm; kid — JWK thumbprint (для HS256 — хэш секрета)
+func Generate(algorithm string) (*Key, error) {
+	if algorithm == AlgHS256 {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey("", secret), nil
+	}
+
+	var private interface{}
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 3072)
+	case AlgES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKeyPEM("", algorithm, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// Marshal сериализует ключ для хранения: PKCS#8 PEM или base64 секрета для HS256
+func (k *Key) Marshal() (string, error) {
+	if k.Symmetric() {
+		return base64.StdEncoding.EncodeToString(k.Secret), nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// Unmarshal восстанавливает ключ, сохраненный через Marshal
+func Unmarshal(id, algorithm, material string) (*Key, error) {
+	if algorithm == AlgHS256 {
+		secret, err := base64.StdEncoding.DecodeString(material)
+		if err != nil {
+			return nil, fmt.Errorf("decode secret: %w", err)
+		}
+		return NewHMACKey(id, secret), nil
+	}
+	return ParsePrivateKeyPEM(id, algorithm, []byte(material))
+}
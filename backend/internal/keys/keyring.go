@@ -0,0 +1,156 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"user-service/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Keyring хранит текущий ключ подписи и ключи, которыми можно проверять токены (по kid)
+type Keyring struct {
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*Key
+}
+
+func NewKeyring(signing *Key) *Keyring {
+	return &Keyring{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+}
+
+// Replace атомарно меняет ключ подписи и набор ключей проверки
+func (r *Keyring) Replace(signing *Key, verification []*Key) {
+	keys := map[string]*Key{signing.ID: signing}
+	for _, k := range verification {
+		keys[k.ID] = k
+	}
+
+	r.mu.Lock()
+	r.signing = signing
+	r.keys = keys
+	r.mu.Unlock()
+}
+
+// SigningKey — текущий ключ подписи
+func (r *Keyring) SigningKey() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.signing
+}
+
+// LoadFromConfig собирает keyring по JWT_ALGORITHM: HS256 использует JWT_SECRET,
+// остальные алгоритмы — приватный ключ из JWT_PRIVATE_KEY_PATH
+func LoadFromConfig(cfg *config.Config) (*Keyring, error) {
+	if cfg.JWTAlgorithm == AlgHS256 {
+		return NewKeyring(NewHMACKey(cfg.JWTKeyID, []byte(cfg.JWTSecret))), nil
+	}
+
+	if cfg.JWTPrivateKeyPath == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_PATH is required for %s", cfg.JWTAlgorithm)
+	}
+	data, err := os.ReadFile(cfg.JWTPrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	key, err := ParsePrivateKeyPEM(cfg.JWTKeyID, cfg.JWTAlgorithm, data)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(key), nil
+}
+
+// Sign подписывает claims текущим ключом и проставляет kid в заголовок
+func (r *Keyring) Sign(claims jwt.Claims) (string, error) {
+	r.mu.RLock()
+	key := r.signing
+	r.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signingKey())
+}
+
+// Keyfunc выбирает ключ проверки по kid; подходит для jwt.Parse
+func (r *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	key := r.keys[kid]
+	if kid == "" && r.signing.Symmetric() {
+		// Токены, выданные до появления kid
+		key = r.signing
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("token algorithm does not match key")
+	}
+	return key.verificationKey(), nil
+}
+
+// Algorithms — алгоритмы, которые допускаются при проверке (защита от подмены alg)
+func (r *Keyring) Algorithms() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var algs []string
+	for _, k := range r.keys {
+		if !seen[k.Algorithm] {
+			seen[k.Algorithm] = true
+			algs = append(algs, k.Algorithm)
+		}
+	}
+	return algs
+}
+
+// Parse проверяет подпись и срок действия токена
+func (r *Keyring) Parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, r.Keyfunc, jwt.WithValidMethods(r.Algorithms()))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+// JWKS возвращает публичные ключи; симметричные ключи никогда не публикуются
+func (r *Keyring) JWKS() []JWK {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jwks := []JWK{}
+	for _, k := range r.keys {
+		if k.Symmetric() {
+			continue
+		}
+		jwk, err := k.JWK()
+		if err != nil {
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+// SigningAlgorithm — алгоритм текущего ключа подписи
+func (r *Keyring) SigningAlgorithm() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.signing.Algorithm
+}
@@ -0,0 +1,212 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key — ключ подписи JWT. Для HS256 есть только Secret, для остальных — пара ключей.
+type Key struct {
+	ID        string
+	Algorithm string
+	Secret    []byte
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+// JWK — публичный ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// NewHMACKey создает симметричный ключ HS256
+func NewHMACKey(id string, secret []byte) *Key {
+	if id == "" {
+		sum := sha256.Sum256(secret)
+		id = "hs-" + base64.RawURLEncoding.EncodeToString(sum[:6])
+	}
+	return &Key{ID: id, Algorithm: AlgHS256, Secret: secret}
+}
+
+// ParsePrivateKeyPEM разбирает приватный ключ (PKCS#8, PKCS#1 или SEC1) и проверяет,
+// что он подходит для algorithm. Если id пустой, используется JWK thumbprint (RFC 7638).
+func ParsePrivateKeyPEM(id, algorithm string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+
+	key := &Key{ID: id, Algorithm: algorithm, Private: signer, Public: signer.Public()}
+	if err := key.checkAlgorithm(); err != nil {
+		return nil, err
+	}
+	if key.ID == "" {
+		key.ID, err = key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func (k *Key) checkAlgorithm() error {
+	switch k.Algorithm {
+	case AlgRS256:
+		pub, ok := k.Public.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 requires an RSA key")
+		}
+		if pub.N.BitLen() < 2048 {
+			return errors.New("RSA key must be at least 2048 bits")
+		}
+	case AlgES256:
+		pub, ok := k.Public.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return errors.New("ES256 requires a P-256 ECDSA key")
+		}
+	case AlgEdDSA:
+		if _, ok := k.Public.(ed25519.PublicKey); !ok {
+			return errors.New("EdDSA requires an Ed25519 key")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+	return nil
+}
+
+// Symmetric — ключ HS256, его нельзя публиковать в JWKS
+func (k *Key) Symmetric() bool {
+	return k.Algorithm == AlgHS256
+}
+
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *Key) signingKey() interface{} {
+	if k.Symmetric() {
+		return k.Secret
+	}
+	return k.Private
+}
+
+func (k *Key) verificationKey() interface{} {
+	if k.Symmetric() {
+		return k.Secret
+	}
+	return k.Public
+}
+
+// JWK возвращает публичную часть ключа
+func (k *Key) JWK() (JWK, error) {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, errors.New("key has no public part")
+	}
+	return jwk, nil
+}
+
+// Thumbprint — JWK thumbprint по RFC 7638
+func (k *Key) Thumbprint() (string, error) {
+	jwk, err := k.JWK()
+	if err != nil {
+		return "", err
+	}
+
+	// Только обязательные поля в лексикографическом порядке
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return b64(sum[:]), nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -1,11 +1,19 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"user-service/internal/keys"
+	"user-service/internal/models"
+	"user-service/internal/services"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,7 +29,7 @@ func CORS(frontendURL string) gin.HandlerFunc {
 		}
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, X-CSRF-Token, X-Request-ID")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Max-Age", "86400") // 24 часа
 
@@ -35,7 +43,49 @@ func CORS(frontendURL string) gin.HandlerFunc {
 	}
 }
 
-func AuthMiddleware(jwtSecret string, logger *logrus.Logger) gin.HandlerFunc {
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID принимает X-Request-ID от прокси или клиента (если он похож на ID) либо
+// создает новый; ID возвращается в ответе и попадает в журнал аудита
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set("requestID", requestID)
+		c.Header("X-Request-ID", requestID)
+		c.Next()
+	}
+}
+
+// RevocationChecker сообщает, отозван ли access token с данным jti
+type RevocationChecker interface {
+	IsRevoked(jti string) bool
+	IsSessionRevoked(sessionID string) bool
+}
+
+// BanChecker возвращает действующую блокировку пользователя
+type BanChecker interface {
+	ActiveBan(userID uint) *models.UserBan
+}
+
+// ClientChecker сообщает, отключен ли OAuth-клиент
+type ClientChecker interface {
+	ClientDisabled(clientID string) bool
+}
+
+// APITokenAuthenticator проверяет личные API-ключи пользователей
+type APITokenAuthenticator interface {
+	AuthenticateAPIToken(token, ip string) (*models.APIToken, *models.User, error)
+}
+
+// PermissionResolver возвращает права роли
+type PermissionResolver interface {
+	Permissions(role string) []string
+}
+
+func AuthMiddleware(keyring *keys.Keyring, revocations RevocationChecker, bans BanChecker, clients ClientChecker, apiTokens APITokenAuthenticator, roles PermissionResolver, logger *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -51,52 +101,259 @@ func AuthMiddleware(jwtSecret string, logger *logrus.Logger) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-		if err != nil || !token.Valid {
+		if strings.HasPrefix(tokenString, services.APITokenPrefix) && apiTokens != nil {
+			authenticateAPIToken(c, apiTokens, roles, tokenString, logger)
+			return
+		}
+
+		claims, err := keyring.Parse(tokenString)
+		if err != nil {
 			logger.WithError(err).Error("Invalid token")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		if jti, ok := claims["jti"].(string); ok && revocations.IsRevoked(jti) {
+			logger.WithField("sub", claims["sub"]).Warn("Revoked token presented")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+		// Сессия завершена (выход, отзыв в списке сессий): ее access token не ждут истечения
+		if sid, ok := claims["sid"].(string); ok && revocations.IsSessionRevoked(sid) {
+			logger.WithField("sub", claims["sub"]).Warn("Access token of revoked session presented")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// ID token предназначен клиенту OIDC (aud = client_id), а не для доступа к API
+		if _, isIDToken := claims["aud"]; isIDToken {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "ID token cannot be used as access token"})
 			c.Abort()
 			return
 		}
 
 		// Добавляем информацию о пользователе в контекст
-		userID := claims["sub"]
+		userID, hasUser := services.SubjectUserID(claims)
 		userRole := claims["role"]
 
-		logger.Infof("Token validated: userID=%v, role=%v", userID, userRole)
+		logger.Infof("Token validated: sub=%v, role=%v", claims["sub"], userRole)
+
+		// Заблокированный пользователь теряет доступ сразу, не дожидаясь истечения токена
+		if hasUser && bans != nil {
+			if ban := bans.ActiveBan(userID); ban != nil {
+				abortBanned(c, ban)
+				return
+			}
+		}
+
+		// У токена сервиса (client_credentials) нет пользователя
+		if hasUser {
+			c.Set("userID", userID)
+			c.Set("userRole", userRole)
+			// Права берем по текущей роли, а не из токена: изменение прав роли действует сразу
+			role, _ := userRole.(string)
+			c.Set("permissions", roles.Permissions(role))
+		}
+		if sessionID, ok := claims["sid"].(string); ok {
+			c.Set("sessionID", sessionID)
+		}
+		// Администратор вошел от имени пользователя (RFC 8693, claim act)
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			actorID, _ := strconv.ParseUint(fmt.Sprint(act["sub"]), 10, 32)
+			c.Set("actorID", uint(actorID))
+			c.Set("impersonationID", claims["jti"])
+		}
+		// Токен, выданный стороннему приложению через OIDC, сервису или устройству
+		if clientID, ok := claims["client_id"].(string); ok {
+			// Отключенный клиент теряет доступ сразу, а не по истечении токенов
+			if clients != nil && clients.ClientDisabled(clientID) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Client is disabled"})
+				c.Abort()
+				return
+			}
+			// У сессии устройства (device flow) есть sid: это вход в наш сервис, а не токен стороннего приложения
+			if _, isSession := claims["sid"]; !isSession {
+				c.Set("clientID", clientID)
+			}
+		}
+		// Кто действует — для журнала аудита: под impersonation это администратор, а не пользователь
+		if actorID, ok := c.Get("actorID"); ok {
+			c.Set("actor", services.UserActor(actorID.(uint)))
+		} else if hasUser {
+			c.Set("actor", services.UserActor(userID))
+		} else if clientID, ok := c.Get("clientID"); ok {
+			c.Set("actor", fmt.Sprintf("client:%v", clientID))
+		}
+		scope, _ := claims["scope"].(string)
+		c.Set("scopes", strings.Fields(scope))
+		c.Next()
+	}
+}
+
+// authenticateAPIToken кладет в контекст то же, что и для JWT, плюс scope ключа
+func authenticateAPIToken(c *gin.Context, apiTokens APITokenAuthenticator, roles PermissionResolver, tokenString string, logger *logrus.Logger) {
+	token, user, err := apiTokens.AuthenticateAPIToken(tokenString, c.ClientIP())
+	if errors.Is(err, services.ErrInvalidAPIToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+	var banned *services.BannedError
+	if errors.As(err, &banned) {
+		abortBanned(c, banned.Ban)
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to check API token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("userRole", user.Role)
+	c.Set("permissions", roles.Permissions(user.Role))
+	c.Set("apiTokenID", token.ID)
+	c.Set("actor", services.UserActor(user.ID))
+	c.Set("scopes", token.Scopes)
+	c.Next()
+}
+
+func abortBanned(c *gin.Context, ban *models.UserBan) {
+	c.JSON(http.StatusForbidden, services.BanResponse(ban))
+	c.Abort()
+}
+
+// RejectAPITokens закрывает маршруты управления аккаунтом от личных API-ключей:
+// ключом нельзя выпустить новый ключ, завершить сессии или войти в админку
+func RejectAPITokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiTokenID"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API tokens cannot be used here"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ImpersonationRecorder пишет журнал запросов, сделанных от имени пользователя
+type ImpersonationRecorder interface {
+	RecordImpersonatedRequest(impersonationID, method, path string, status int, ip string) error
+}
 
-		c.Set("userID", userID)
-		c.Set("userRole", userRole)
+// ImpersonationAudit записывает каждый запрос с токеном impersonation вместе с кодом ответа
+func ImpersonationAudit(recorder ImpersonationRecorder, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		c.Next()
+
+		impersonationID, ok := c.Get("impersonationID")
+		if !ok {
+			return
+		}
+		id, _ := impersonationID.(string)
+		if err := recorder.RecordImpersonatedRequest(id, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), c.ClientIP()); err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"impersonation_id": id,
+				"method":           c.Request.Method,
+				"path":             c.Request.URL.Path,
+			}).Error("Failed to record impersonated request")
+		}
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+// RejectImpersonation закрывает маршрут для администратора, вошедшего от имени пользователя
+func RejectImpersonation() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("userRole")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
+		if _, ok := c.Get("impersonationID"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating a user"})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		if userRole != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+// FirstPartyOnly пропускает только токены нашего фронтенда: приложениям, вошедшим
+// через OIDC, доступен лишь /userinfo
+func FirstPartyOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("clientID"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token issued to a client application cannot be used here"})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
+// RequireScope пропускает только токены, в которых есть все перечисленные scope.
+// Токен без claim scope (выданный до появления scope) не получает ничего.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("scopes")
+		granted, _ := value.([]string)
+		for _, scope := range scopes {
+			found := false
+			for _, g := range granted {
+				if g == scope {
+					found = true
+					break
+				}
+			}
+			if !found {
+				c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "required_scope": strings.Join(scopes, " ")})
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
 
+// UserFromPath — для сервисных токенов: пользователь, с чьими данными работает
+// запрос, берется из параметра пути, дальше работают обычные обработчики
+func UserFromPath(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, hasUser := c.Get("userID"); hasUser {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Service token required"})
+			c.Abort()
+			return
+		}
+		id, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			c.Abort()
+			return
+		}
+		c.Set("userID", uint(id))
+		c.Next()
+	}
+}
 
+// RequirePermission пропускает пользователей, у роли которых есть все перечисленные права
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("permissions")
+		granted, _ := value.([]string)
+		for _, permission := range permissions {
+			found := false
+			for _, g := range granted {
+				if g == permission {
+					found = true
+					break
+				}
+			}
+			if !found {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Permission required", "required_permission": permission})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID            uint      `json:"id"`
@@ -10,16 +13,399 @@ type User struct {
 	Avatar        string    `json:"avatar"`
 	Role          string    `json:"role"`
 	CreatedAt     time.Time `json:"created_at"`
+
+	// Заполняются только в ответе /me
+	Permissions    []string      `json:"permissions,omitempty"`
+	ImpersonatedBy *Impersonator `json:"impersonated_by,omitempty"` // администратор, вошедший от имени пользователя
 }
 
-type RefreshToken struct {
+// ClientInfo — кто и откуда прислал запрос; сохраняется вместе с сессией и в журнале аудита
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	RequestID string
+	// Actor — кто действует ("user:5", "client:app"); у администратора под impersonation — он сам
+	Actor string
+}
+
+// Session — один вход пользователя (семья refresh-токенов)
+type Session struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// TokenIntrospection — ответ /introspect (RFC 7662); у неактивного токена заполнено только Active
+type TokenIntrospection struct {
+	Active    bool           `json:"active"`
+	TokenType string         `json:"token_type,omitempty"`
+	Sub       string         `json:"sub,omitempty"`
+	Role      string         `json:"role,omitempty"`
+	Scope     string         `json:"scope,omitempty"`
+	ClientID  string         `json:"client_id,omitempty"`
+	SessionID string         `json:"sid,omitempty"`
+	Act       map[string]any `json:"act,omitempty"` // администратор, действующий от имени sub
+	Exp       int64          `json:"exp,omitempty"`
+	Iat       int64          `json:"iat,omitempty"`
+	Iss       string         `json:"iss,omitempty"`
+}
+
+// UserIdentity — учетная запись внешнего провайдера (Discord, GitHub, ...), привязанная к пользователю
+type UserIdentity struct {
 	ID        uint      `json:"id"`
 	UserID    uint      `json:"user_id"`
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Avatar    string    `json:"avatar"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Пользователь отозвал доступ приложения у провайдера, профиль больше не обновляется
+	GrantRevokedAt *time.Time `json:"grant_revoked_at,omitempty"`
+}
+
+// ProviderGrant — OAuth-токены провайдера, сохраненные для фонового обновления профиля.
+// AccessToken и RefreshToken зашифрованы (secrets.Cipher)
+type ProviderGrant struct {
+	IdentityID   uint
+	UserID       uint
+	Provider     string
+	Subject      string
+	AccessToken  []byte
+	RefreshToken []byte
+	Scope        string
+	ExpiresAt    time.Time
+	SyncedAt     time.Time
+}
+
+type RefreshToken struct {
+	ID        uint       `json:"id"`
+	UserID    uint       `json:"user_id"`
+	TokenHash string     `json:"-"`         // SHA-256 токена: сам токен знает только клиент
+	FamilyID  string     `json:"family_id"` // все токены, полученные ротацией из одного входа
+	ClientID  string     `json:"client_id"` // OAuth-клиент сессии устройства; пусто — вход через фронтенд
+	Scope     string     `json:"scope"`     // запрошенные при входе scope; пусто — все, что разрешает роль
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// DeviceCode — запрос входа с устройства без браузера (RFC 8628)
+type DeviceCode struct {
+	ID             uint      `json:"-"`
+	DeviceCodeHash string    `json:"-"`
+	UserCode       string    `json:"user_code"`
+	ClientID       string    `json:"client_id"`
+	Scope          string    `json:"scope"`
+	UserID         uint      `json:"-"`
+	Status         string    `json:"status"`
+	Interval       int       `json:"interval"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+	DeviceCodeConsumed = "consumed"
+)
+
+// APIToken — личный API-ключ пользователя; сам ключ показывается один раз при создании
+type APIToken struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+// OAuthState хранит параметры начатого OAuth-входа до возврата пользователя в /callback
+type OAuthState struct {
+	ID           uint      `json:"id"`
+	State        string    `json:"state"`
+	Provider     string    `json:"provider"`
+	CodeVerifier string    `json:"-"`
+	ReturnTo     string    `json:"return_to"`
+	LinkUserID   uint      `json:"link_user_id,omitempty"` // не 0, если это привязка учетной записи, а не вход
+	Scope        string    `json:"scope"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Impersonator — администратор, вошедший от имени пользователя
+type Impersonator struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// Impersonation — вход администратора от имени пользователя
+type Impersonation struct {
+	ID        string     `json:"id"`
+	ActorID   uint       `json:"actor_id"`
+	UserID    uint       `json:"user_id"`
+	Reason    string     `json:"reason"`
+	IP        string     `json:"ip"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// ImpersonationRequest — запрос, сделанный с токеном impersonation
+type ImpersonationRequest struct {
+	ID        uint      `json:"id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserMFA — TOTP второго фактора пользователя
+type UserMFA struct {
+	UserID         uint
+	Secret         string
+	EnabledAt      *time.Time
+	LastUsedStep   int64
+	FailedAttempts int
+	LockedUntil    *time.Time
+	CreatedAt      time.Time
+}
+
+// MFAChallenge — вход, ожидающий код второго фактора
+type MFAChallenge struct {
+	IDHash    string
+	UserID    uint
+	Scope     string
+	ReturnTo  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// Passkey — ключ WebAuthn пользователя
+type Passkey struct {
+	ID           uint       `json:"id"`
+	UserID       uint       `json:"-"`
+	CredentialID string     `json:"credential_id"`
+	PublicKey    []byte     `json:"-"`
+	SignCount    uint32     `json:"-"`
+	AAGUID       string     `json:"aaguid"`
+	Name         string     `json:"name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// WebAuthnChallenge — начатая регистрация passkey или вход по нему
+type WebAuthnChallenge struct {
+	ChallengeHash string
+	UserID        *uint
+	Purpose       string
+	Scope         string
+	ExpiresAt     time.Time
+}
+
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+)
+
+// UserBan — блокировка пользователя. Действует, пока не снята и не истекла.
+type UserBan struct {
+	ID        uint       `json:"id"`
+	UserID    uint       `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Reason    string     `json:"reason"`
+	BannedBy  *uint      `json:"banned_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	LiftedAt  *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy  *uint      `json:"lifted_by,omitempty"`
+}
+
+const (
+	BanKindSuspension = "suspension"
+	BanKindBan        = "ban"
+)
+
+// AuditEvent — запись журнала аудита; Before и After — состояние цели до и после действия
+type AuditEvent struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	RequestID string          `json:"request_id"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditFilter — условия выборки журнала аудита; пустые поля не фильтруют.
+// BeforeID — курсор: события с ID меньше указанного (следующая страница)
+type AuditFilter struct {
+	Actor    string
+	Target   string
+	Action   string
+	From     *time.Time
+	To       *time.Time
+	BeforeID int64
+	Limit    int
+}
+
+// LoginEvent — вход или обновление сессии (refresh), успешные и нет
+type LoginEvent struct {
+	ID            int64     `json:"id"`
+	UserID        uint      `json:"-"`
+	Kind          string    `json:"kind"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IP            string    `json:"ip"`
+	IPRange       string    `json:"-"`
+	UserAgent     string    `json:"user_agent"`
+	Device        string    `json:"device"`
+	Fingerprint   string    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const (
+	LoginKindLogin   = "login"
+	LoginKindRefresh = "refresh"
+)
+
+// LoginAlert — уведомление пользователю о входе с устройства или из сети, которых раньше не было
+type LoginAlert struct {
+	ID         int64      `json:"id"`
+	UserID     uint       `json:"user_id"`
+	NewDevice  bool       `json:"new_device"`
+	NewIPRange bool       `json:"new_ip_range"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	Device     string     `json:"device"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+}
+
+// Role — роль пользователя и ее права
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Builtin     bool      `json:"builtin"`
+	RequireMFA  bool      `json:"require_mfa"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Permission — право, которое проверяет RequirePermission
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// SigningKey — ключ подписи JWT, управляемый через ротацию
+type SigningKey struct {
+	ID          string     `json:"kid"`
+	Algorithm   string     `json:"algorithm"`
+	PrivateKey  string     `json:"-"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+	RetireAfter *time.Time `json:"retire_after,omitempty"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+const (
+	SigningKeyPending  = "pending"
+	SigningKeyActive   = "active"
+	SigningKeyRetiring = "retiring"
+	SigningKeyRetired  = "retired"
+)
+
+// SigningKeyEvent — запись журнала ротации ключей
+type SigningKeyEvent struct {
+	ID        uint      `json:"id"`
+	KeyID     string    `json:"kid"`
+	Event     string    `json:"event"`
+	Actor     string    `json:"actor"`
+	Details   string    `json:"details"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// OAuthClient — приложение, которое входит через нас как через OIDC-провайдер, или сервис с client_credentials
+type OAuthClient struct {
+	ID           string     `json:"client_id"`
+	Name         string     `json:"name"`
+	SecretHash   string     `json:"-"`
+	GrantTypes   []string   `json:"grant_types"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Scopes       []string   `json:"scopes"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+}
+
+// Public — клиент без секрета (SPA, нативное приложение); аутентифицируется только PKCE
+func (c *OAuthClient) Public() bool {
+	return c.SecretHash == ""
+}
+
+func (c *OAuthClient) Disabled() bool {
+	return c.DisabledAt != nil
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationRequest — параметры /authorize, пока пользователь не подтвердил вход
+type AuthorizationRequest struct {
+	ID            string    `json:"id"`
+	ClientID      string    `json:"client_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	State         string    `json:"-"`
+	Nonce         string    `json:"-"`
+	CodeChallenge string    `json:"-"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AuthorizationCode — выданный клиенту одноразовый code; в БД хранится только хеш
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        uint
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
 type Character struct {
 	ID        string    `json:"id" db:"id"`
 	Name      string    `json:"name" db:"name"`
@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"user-service/internal/config"
+)
+
+const (
+	discordAuthorizeURL = "https://discord.com/api/oauth2/authorize"
+	discordTokenURL     = "https://discord.com/api/oauth2/token"
+	discordUserURL      = "https://discord.com/api/users/@me"
+)
+
+type Discord struct {
+	cfg config.ProviderConfig
+}
+
+func NewDiscord(cfg config.ProviderConfig) *Discord {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"identify"}
+	}
+	return &Discord{cfg: cfg}
+}
+
+func (p *Discord) Name() string { return "discord" }
+
+func (p *Discord) AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	return authCodeURL(discordAuthorizeURL, p.cfg, state, codeChallenge, url.Values{"prompt": {"consent"}}), nil
+}
+
+func (p *Discord) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	return exchangeCode(ctx, discordTokenURL, p.cfg, code, codeVerifier)
+}
+
+// Refresh обновляет access token; Discord выдает вместе с ним новый refresh token
+func (p *Discord) Refresh(ctx context.Context, token string) (*Token, error) {
+	return refreshToken(ctx, discordTokenURL, p.cfg, token)
+}
+
+func (p *Discord) FetchIdentity(ctx context.Context, token *Token) (*Identity, error) {
+	var u struct {
+		ID            string `json:"id"`
+		Username      string `json:"username"`
+		Discriminator string `json:"discriminator"`
+		GlobalName    string `json:"global_name"`
+		Avatar        string `json:"avatar"`
+		Email         string `json:"email"`
+	}
+	if err := getJSON(ctx, discordUserURL, token, &u); err != nil {
+		return nil, err
+	}
+
+	// Сборка URL аватара
+	avatarURL := ""
+	if u.Avatar != "" {
+		avatarURL = "https://cdn.discordapp.com/avatars/" + u.ID + "/" + u.Avatar + ".png"
+	}
+
+	displayName := u.GlobalName
+	if displayName == "" {
+		displayName = u.Username
+	}
+
+	return &Identity{
+		Provider:      p.Name(),
+		Subject:       u.ID,
+		Username:      displayName,
+		Discriminator: u.Discriminator,
+		Email:         u.Email,
+		AvatarURL:     avatarURL,
+	}, nil
+}
+
+// DiscordGuildMember — участник сервера Discord
+type DiscordGuildMember struct {
+	Roles []string `json:"roles"`
+}
+
+// DiscordGuilds проверяет членство на серверах: по токену пользователя (scope guilds.members.read)
+// или, без пользователя, токеном бота, добавленного на сервер
+type DiscordGuilds struct {
+	botToken string
+}
+
+func NewDiscordGuilds(botToken string) *DiscordGuilds {
+	return &DiscordGuilds{botToken: botToken}
+}
+
+// CanCheckWithoutUser — задан ли токен бота
+func (g *DiscordGuilds) CanCheckWithoutUser() bool {
+	return g.botToken != ""
+}
+
+// Member возвращает участника сервера guildID по токену пользователя; nil — не состоит
+func (g *DiscordGuilds) Member(ctx context.Context, token *Token, guildID string) (*DiscordGuildMember, error) {
+	return discordGuildMember(ctx, "https://discord.com/api/users/@me/guilds/"+url.PathEscape(guildID)+"/member", "Bearer "+token.AccessToken)
+}
+
+// MemberByID возвращает участника сервера токеном бота; nil — не состоит
+func (g *DiscordGuilds) MemberByID(ctx context.Context, guildID, discordUserID string) (*DiscordGuildMember, error) {
+	if g.botToken == "" {
+		return nil, fmt.Errorf("discord bot token is not configured")
+	}
+	return discordGuildMember(ctx, "https://discord.com/api/guilds/"+url.PathEscape(guildID)+"/members/"+url.PathEscape(discordUserID), "Bot "+g.botToken)
+}
+
+func discordGuildMember(ctx context.Context, endpoint, authorization string) (*DiscordGuildMember, error) {
+	var member DiscordGuildMember
+	err := getJSONWithAuthorization(ctx, endpoint, authorization, &member)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
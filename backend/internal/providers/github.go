@@ -0,0 +1,61 @@
+package providers
+
+import (
+	"context"
+	"strconv"
+
+	"user-service/internal/config"
+)
+
+const (
+	githubAuthorizeURL = "https://github.com/login/oauth/authorize"
+	githubTokenURL     = "https://github.com/login/oauth/access_token"
+	githubUserURL      = "https://api.github.com/user"
+)
+
+type GitHub struct {
+	cfg config.ProviderConfig
+}
+
+func NewGitHub(cfg config.ProviderConfig) *GitHub {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user"}
+	}
+	return &GitHub{cfg: cfg}
+}
+
+func (p *GitHub) Name() string { return "github" }
+
+func (p *GitHub) AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	return authCodeURL(githubAuthorizeURL, p.cfg, state, codeChallenge, nil), nil
+}
+
+func (p *GitHub) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	return exchangeCode(ctx, githubTokenURL, p.cfg, code, codeVerifier)
+}
+
+func (p *GitHub) FetchIdentity(ctx context.Context, token *Token) (*Identity, error) {
+	var u struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := getJSON(ctx, githubUserURL, token, &u); err != nil {
+		return nil, err
+	}
+
+	displayName := u.Name
+	if displayName == "" {
+		displayName = u.Login
+	}
+
+	return &Identity{
+		Provider:  p.Name(),
+		Subject:   strconv.FormatInt(u.ID, 10),
+		Username:  displayName,
+		Email:     u.Email,
+		AvatarURL: u.AvatarURL,
+	}, nil
+}
@@ -0,0 +1,114 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"user-service/internal/config"
+)
+
+// OIDC — универсальный OpenID Connect провайдер; эндпоинты берутся из discovery-документа
+type OIDC struct {
+	name string
+	cfg  config.ProviderConfig
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+type oidcDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+func NewOIDC(cfg config.ProviderConfig) *OIDC {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	name := cfg.Name
+	if name == "" {
+		name = "oidc"
+	}
+	return &OIDC{name: name, cfg: cfg}
+}
+
+// NewGoogle — Google это обычный OIDC провайдер с фиксированным issuer
+func NewGoogle(cfg config.ProviderConfig) *OIDC {
+	cfg.Name = "google"
+	cfg.IssuerURL = "https://accounts.google.com"
+	return NewOIDC(cfg)
+}
+
+func (p *OIDC) Name() string { return p.name }
+
+func (p *OIDC) AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authCodeURL(d.AuthorizationEndpoint, p.cfg, state, codeChallenge, nil), nil
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return exchangeCode(ctx, d.TokenEndpoint, p.cfg, code, codeVerifier)
+}
+
+func (p *OIDC) FetchIdentity(ctx context.Context, token *Token) (*Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var u struct {
+		Sub               string `json:"sub"`
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+		Email             string `json:"email"`
+		Picture           string `json:"picture"`
+	}
+	if err := getJSON(ctx, d.UserinfoEndpoint, token, &u); err != nil {
+		return nil, err
+	}
+	if u.Sub == "" {
+		return nil, fmt.Errorf("userinfo response has no sub")
+	}
+
+	displayName := u.Name
+	if displayName == "" {
+		displayName = u.PreferredUsername
+	}
+
+	return &Identity{
+		Provider:  p.Name(),
+		Subject:   u.Sub,
+		Username:  displayName,
+		Email:     u.Email,
+		AvatarURL: u.Picture,
+	}, nil
+}
+
+func (p *OIDC) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d oidcDiscovery
+	endpoint := strings.TrimSuffix(p.cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, endpoint, nil, &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("oidc discovery document is incomplete")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
@@ -0,0 +1,206 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"user-service/internal/config"
+)
+
+// Identity — профиль пользователя у внешнего провайдера, приведенный к общему виду
+type Identity struct {
+	Provider      string
+	Subject       string
+	Username      string
+	Discriminator string
+	Email         string
+	AvatarURL     string
+}
+
+// Token — ответ token endpoint провайдера
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Provider — внешний OAuth2/OIDC провайдер, через который можно войти
+type Provider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (*Token, error)
+	FetchIdentity(ctx context.Context, token *Token) (*Identity, error)
+}
+
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry регистрирует всех провайдеров, для которых задан client_id
+func NewRegistry(cfg *config.Config) *Registry {
+	r := &Registry{providers: map[string]Provider{}}
+	if cfg.Discord.ClientID != "" {
+		r.Register(NewDiscord(cfg.Discord))
+	}
+	if cfg.GitHub.ClientID != "" {
+		r.Register(NewGitHub(cfg.GitHub))
+	}
+	if cfg.Google.ClientID != "" {
+		r.Register(NewGoogle(cfg.Google))
+	}
+	if cfg.OIDC.ClientID != "" && cfg.OIDC.IssuerURL != "" {
+		r.Register(NewOIDC(cfg.OIDC))
+	}
+	return r
+}
+
+func (r *Registry) Register(p Provider) {
+	r.providers[p.Name()] = p
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func authCodeURL(endpoint string, pc config.ProviderConfig, state, codeChallenge string, extra url.Values) string {
+	q := url.Values{}
+	q.Set("client_id", pc.ClientID)
+	q.Set("redirect_uri", pc.RedirectURI)
+	q.Set("response_type", "code")
+	q.Set("scope", strings.Join(pc.Scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	for k, v := range extra {
+		q[k] = v
+	}
+	return endpoint + "?" + q.Encode()
+}
+
+func exchangeCode(ctx context.Context, tokenURL string, pc config.ProviderConfig, code, codeVerifier string) (*Token, error) {
+	form := url.Values{}
+	form.Set("client_id", pc.ClientID)
+	form.Set("client_secret", pc.ClientSecret)
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", pc.RedirectURI)
+	form.Set("code_verifier", codeVerifier)
+	return postToken(ctx, tokenURL, form)
+}
+
+// refreshToken обменивает refresh token на новую пару (RFC 6749, раздел 6)
+func refreshToken(ctx context.Context, tokenURL string, pc config.ProviderConfig, refreshToken string) (*Token, error) {
+	form := url.Values{}
+	form.Set("client_id", pc.ClientID)
+	form.Set("client_secret", pc.ClientSecret)
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	return postToken(ctx, tokenURL, form)
+}
+
+func postToken(ctx context.Context, tokenURL string, form url.Values) (*Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var tok Token
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	if tok.TokenType == "" {
+		tok.TokenType = "Bearer"
+	}
+	return &tok, nil
+}
+
+func getJSON(ctx context.Context, endpoint string, token *Token, out interface{}) error {
+	authorization := ""
+	if token != nil {
+		authorization = "Bearer " + token.AccessToken
+	}
+	return getJSONWithAuthorization(ctx, endpoint, authorization, out)
+}
+
+// getJSONWithAuthorization — getJSON с произвольным заголовком Authorization (например, "Bot ..." для Discord)
+func getJSONWithAuthorization(ctx context.Context, endpoint, authorization string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// HTTPError — неуспешный ответ провайдера
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("provider responded with status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsGrantRevoked — провайдер больше не принимает токены: пользователь отозвал доступ приложения
+// (401 на запрос с access token или invalid_grant при обновлении)
+func IsGrantRevoked(err error) bool {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	return httpErr.StatusCode == http.StatusUnauthorized ||
+		(httpErr.StatusCode == http.StatusBadRequest && strings.Contains(httpErr.Body, "invalid_grant"))
+}
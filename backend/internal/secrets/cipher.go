@@ -0,0 +1,232 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize — длина мастер-ключа TOKEN_ENCRYPTION_KEY и ключей данных (AES-256)
+const KeySize = 32
+
+// Prefix отличает зашифрованное значение в текстовой колонке от открытого, записанного до шифрования
+const Prefix = "enc:"
+
+const (
+	// v1: nonce | AES-GCM(мастер-ключ, значение) — так сохранялись токены Discord до envelope
+	versionDirect = 1
+	// v2: version | keyID | nonce | AES-GCM(мастер-ключ, ключ данных) | nonce | AES-GCM(ключ данных, значение)
+	versionEnvelope = 2
+
+	keyIDSize  = 4
+	headerSize = 1 + keyIDSize
+	nonceSize  = 12
+	tagSize    = 16
+	wrappedDEK = nonceSize + KeySize + tagSize
+)
+
+var ErrDecrypt = errors.New("cannot decrypt secret")
+
+// Cipher — envelope-шифрование секретов в БД (токены провайдеров, секреты TOTP). Каждое значение
+// шифруется своим случайным ключом данных, а он — мастер-ключом из конфигурации. Мастер-ключ
+// указан в заголовке по ID, поэтому после смены TOKEN_ENCRYPTION_KEY старые значения читаются
+// прежними ключами, пока их не перезапишут.
+// additionalData привязывает шифротекст к записи: скопированный в чужую строку, он не расшифруется.
+type Cipher struct {
+	current  masterKey
+	previous []masterKey
+}
+
+type masterKey struct {
+	id   [keyIDSize]byte
+	aead cipher.AEAD
+}
+
+// NewCipher принимает текущий мастер-ключ и прежние, которые нужны только для чтения
+func NewCipher(key []byte, previous ...[]byte) (*Cipher, error) {
+	current, err := newMasterKey(key)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cipher{current: current}
+	for _, k := range previous {
+		mk, err := newMasterKey(k)
+		if err != nil {
+			return nil, err
+		}
+		c.previous = append(c.previous, mk)
+	}
+	return c, nil
+}
+
+func newMasterKey(key []byte) (masterKey, error) {
+	if len(key) != KeySize {
+		return masterKey{}, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return masterKey{}, err
+	}
+	mk := masterKey{aead: aead}
+	sum := sha256.Sum256(key)
+	copy(mk.id[:], sum[:])
+	return mk, nil
+}
+
+// KeyID — ID текущего мастер-ключа (начало его SHA-256), безопасен для логов
+func (c *Cipher) KeyID() string {
+	return hex.EncodeToString(c.current.id[:])
+}
+
+func (c *Cipher) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	// Заголовок — additional data для ключа данных; отдельная копия, чтобы Seal
+	// не читал AD из того же буфера, в который пишет
+	header := make([]byte, headerSize)
+	header[0] = versionEnvelope
+	copy(header[1:], c.current.id[:])
+	out := make([]byte, 0, headerSize+wrappedDEK+nonceSize+len(plaintext)+tagSize)
+	out = append(out, header...)
+	out, err = seal(out, c.current.aead, dek, header)
+	if err != nil {
+		return nil, err
+	}
+	return seal(out, dataAEAD, plaintext, additionalData)
+}
+
+func (c *Cipher) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, ErrDecrypt
+	}
+	switch ciphertext[0] {
+	case versionEnvelope:
+		if len(ciphertext) < headerSize+wrappedDEK+nonceSize+tagSize {
+			return nil, ErrDecrypt
+		}
+		mk := c.key(ciphertext[1:headerSize])
+		if mk == nil {
+			return nil, ErrDecrypt
+		}
+		dek, err := open(mk.aead, ciphertext[headerSize:headerSize+wrappedDEK], ciphertext[:headerSize])
+		if err != nil {
+			return nil, err
+		}
+		dataAEAD, err := newAEAD(dek)
+		if err != nil {
+			return nil, err
+		}
+		return open(dataAEAD, ciphertext[headerSize+wrappedDEK:], additionalData)
+	case versionDirect:
+		for _, mk := range c.keys() {
+			if plaintext, err := open(mk.aead, ciphertext[1:], additionalData); err == nil {
+				return plaintext, nil
+			}
+		}
+	}
+	return nil, ErrDecrypt
+}
+
+// NeedsRewrap — значение зашифровано не текущим мастер-ключом (или в старом формате) и его стоит перезаписать
+func (c *Cipher) NeedsRewrap(ciphertext []byte) bool {
+	return len(ciphertext) < headerSize || ciphertext[0] != versionEnvelope || !bytes.Equal(ciphertext[1:headerSize], c.current.id[:])
+}
+
+// EncryptString шифрует значение для текстовой колонки: Prefix + base64
+func (c *Cipher) EncryptString(plaintext, additionalData string) (string, error) {
+	ciphertext, err := c.Encrypt([]byte(plaintext), []byte(additionalData))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (c *Cipher) DecryptString(value, additionalData string) (string, error) {
+	ciphertext, err := decodeString(value)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := c.Decrypt(ciphertext, []byte(additionalData))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRewrapString — NeedsRewrap для значения из текстовой колонки; открытое значение тоже нужно перезаписать
+func (c *Cipher) NeedsRewrapString(value string) bool {
+	ciphertext, err := decodeString(value)
+	return err != nil || c.NeedsRewrap(ciphertext)
+}
+
+// IsEncrypted — значение записано EncryptString, а не хранится открытым
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+func decodeString(value string) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return nil, ErrDecrypt
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return ciphertext, nil
+}
+
+func (c *Cipher) key(id []byte) *masterKey {
+	for _, mk := range c.keys() {
+		if bytes.Equal(mk.id[:], id) {
+			return &mk
+		}
+	}
+	return nil
+}
+
+func (c *Cipher) keys() []masterKey {
+	return append([]masterKey{c.current}, c.previous...)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal дописывает к dst nonce и шифротекст
+func seal(dst []byte, aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < nonceSize+tagSize {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
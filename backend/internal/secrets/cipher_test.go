@@ -0,0 +1,199 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func newTestCipher(t *testing.T, key []byte, previous ...[]byte) *Cipher {
+	t.Helper()
+	c, err := NewCipher(key, previous...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// encryptV1 повторяет формат до envelope: 0x01 | nonce | AES-GCM(мастер-ключ, значение)
+func encryptV1(t *testing.T, key, plaintext, additionalData []byte) []byte {
+	t.Helper()
+	aead, err := newAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	out := append([]byte{versionDirect}, nonce...)
+	return aead.Seal(out, nonce, plaintext, additionalData)
+}
+
+func TestNewCipherKeySize(t *testing.T) {
+	if _, err := NewCipher(make([]byte, KeySize-1)); err == nil {
+		t.Error("short key accepted")
+	}
+	if _, err := NewCipher(testKey(1), make([]byte, 16)); err == nil {
+		t.Error("short previous key accepted")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := newTestCipher(t, testKey(1))
+	for _, plaintext := range [][]byte{{}, []byte("x"), bytes.Repeat([]byte("secret"), 1000)} {
+		ciphertext, err := c.Encrypt(plaintext, []byte("ad"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ciphertext[0] != versionEnvelope || !bytes.Equal(ciphertext[1:headerSize], c.current.id[:]) {
+			t.Errorf("header = %x", ciphertext[:headerSize])
+		}
+		if want := headerSize + wrappedDEK + nonceSize + len(plaintext) + tagSize; len(ciphertext) != want {
+			t.Errorf("len = %d, want %d", len(ciphertext), want)
+		}
+		got, err := c.Decrypt(ciphertext, []byte("ad"))
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Decrypt = %q, want %q", got, plaintext)
+		}
+	}
+
+	// Случайный ключ данных и nonce: одинаковые значения шифруются по-разному
+	a, _ := c.Encrypt([]byte("same"), nil)
+	b, _ := c.Encrypt([]byte("same"), nil)
+	if bytes.Equal(a, b) {
+		t.Error("equal ciphertexts for equal plaintexts")
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	c := newTestCipher(t, testKey(1))
+	ciphertext, err := c.Encrypt([]byte("secret"), []byte("users:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) []byte {
+		out := bytes.Clone(ciphertext)
+		out[i] ^= 1
+		return out
+	}
+
+	tests := []struct {
+		name           string
+		ciphertext     []byte
+		additionalData string
+		cipher         *Cipher
+	}{
+		{"wrong additional data", ciphertext, "users:2", c},
+		{"missing additional data", ciphertext, "", c},
+		{"tampered key id", flip(1), "users:1", c},
+		{"tampered wrapped key", flip(headerSize + nonceSize), "users:1", c},
+		{"tampered value", flip(len(ciphertext) - tagSize - 1), "users:1", c},
+		{"tampered tag", flip(len(ciphertext) - 1), "users:1", c},
+		{"truncated", ciphertext[:len(ciphertext)-1], "users:1", c},
+		{"header only", ciphertext[:headerSize], "users:1", c},
+		{"empty", nil, "users:1", c},
+		{"unknown version", append([]byte{3}, ciphertext[1:]...), "users:1", c},
+		{"unknown master key", ciphertext, "users:1", newTestCipher(t, testKey(2))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cipher.Decrypt(tt.ciphertext, []byte(tt.additionalData)); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Decrypt = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestPreviousKey(t *testing.T) {
+	old := newTestCipher(t, testKey(1))
+	ciphertext, err := old.Encrypt([]byte("secret"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestCipher(t, testKey(2), testKey(1))
+	got, err := rotated.Decrypt(ciphertext, []byte("ad"))
+	if err != nil || string(got) != "secret" {
+		t.Fatalf("Decrypt with previous key = %q, %v", got, err)
+	}
+	if !rotated.NeedsRewrap(ciphertext) {
+		t.Error("value under previous key does not need rewrap")
+	}
+
+	rewrapped, err := rotated.Encrypt(got, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.NeedsRewrap(rewrapped) {
+		t.Error("value under current key needs rewrap")
+	}
+	// Новое значение прежним ключом не читается: после перезаписи его можно убрать
+	if _, err := old.Decrypt(rewrapped, []byte("ad")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("old cipher decrypted rewrapped value: %v", err)
+	}
+}
+
+func TestDecryptV1(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    []byte
+		cipher *Cipher
+	}{
+		{"current key", testKey(1), newTestCipher(t, testKey(1))},
+		{"previous key", testKey(1), newTestCipher(t, testKey(2), testKey(1))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ciphertext := encryptV1(t, tt.key, []byte("discord-token"), []byte("ad"))
+			got, err := tt.cipher.Decrypt(ciphertext, []byte("ad"))
+			if err != nil || string(got) != "discord-token" {
+				t.Fatalf("Decrypt = %q, %v", got, err)
+			}
+			if _, err := tt.cipher.Decrypt(ciphertext, []byte("other")); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("v1 value decrypted with wrong additional data: %v", err)
+			}
+			if !tt.cipher.NeedsRewrap(ciphertext) {
+				t.Error("v1 value does not need rewrap")
+			}
+		})
+	}
+
+	ciphertext := encryptV1(t, testKey(3), []byte("discord-token"), nil)
+	if _, err := newTestCipher(t, testKey(1)).Decrypt(ciphertext, nil); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("v1 value decrypted with unknown key: %v", err)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	c := newTestCipher(t, testKey(1))
+	value, err := c.EncryptString("secret", "ad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(value) || c.NeedsRewrapString(value) {
+		t.Fatalf("EncryptString = %q", value)
+	}
+	got, err := c.DecryptString(value, "ad")
+	if err != nil || got != "secret" {
+		t.Fatalf("DecryptString = %q, %v", got, err)
+	}
+
+	// Открытое значение, записанное до включения шифрования
+	if IsEncrypted("secret") || !c.NeedsRewrapString("secret") {
+		t.Error("plaintext value treated as encrypted")
+	}
+	for _, value := range []string{"secret", Prefix + "!!!", Prefix} {
+		if _, err := c.DecryptString(value, "ad"); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("DecryptString(%q) = %v, want ErrDecrypt", value, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+)
+
+// APITokenPrefix отличает личный API-ключ от JWT в заголовке Authorization
+const APITokenPrefix = "pat_"
+
+const maxAPITokensPerUser = 50
+
+// APITokenScopes — что можно разрешить личному API-ключу
+var APITokenScopes = []string{ScopeProfile, ScopeCharactersRead, ScopeCharactersWrite}
+
+var (
+	ErrInvalidAPIToken       = errors.New("invalid api token")
+	ErrAPITokenNotFound      = errors.New("api token not found")
+	ErrTooManyAPITokens      = errors.New("too many api tokens")
+	ErrInvalidAPITokenParams = errors.New("invalid api token parameters")
+)
+
+type APITokenRepository interface {
+	Create(t *models.APIToken) error
+	FindByHash(tokenHash string) (*models.APIToken, error)
+	FindByUserID(userID uint) ([]models.APIToken, error)
+	Revoke(userID, id uint) (bool, error)
+	TouchLastUsed(id uint, ip string) error
+}
+
+func (s *AuthService) WithAPITokenRepository(apiTokenRepo APITokenRepository) *AuthService {
+	s.apiTokenRepo = apiTokenRepo
+	return s
+}
+
+// CreateAPIToken создает ключ; открытое значение возвращается только здесь
+func (s *AuthService) CreateAPIToken(userID uint, name string, scopes []string, expiresAt *time.Time) (*models.APIToken, string, error) {
+	if s.apiTokenRepo == nil {
+		return nil, "", fmt.Errorf("api token repository not configured")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, "", fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidAPITokenParams)
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPITokenParams)
+	}
+	for _, scope := range scopes {
+		if !containsString(APITokenScopes, scope) {
+			return nil, "", fmt.Errorf("%w: unsupported scope %q", ErrInvalidAPITokenParams, scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPITokenParams)
+	}
+
+	existing, err := s.apiTokenRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(existing) >= maxAPITokensPerUser {
+		return nil, "", ErrTooManyAPITokens
+	}
+
+	secret, err := randomURLToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	plain := APITokenPrefix + secret
+	token := &models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashSecret(plain),
+		Prefix:    plain[:len(APITokenPrefix)+6],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.apiTokenRepo.Create(token); err != nil {
+		return nil, "", err
+	}
+
+	s.logger.WithField("user_id", userID).WithField("token_id", token.ID).Info("API token created")
+	return token, plain, nil
+}
+
+func (s *AuthService) GetAPITokens(userID uint) ([]models.APIToken, error) {
+	if s.apiTokenRepo == nil {
+		return nil, fmt.Errorf("api token repository not configured")
+	}
+	tokens, err := s.apiTokenRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []models.APIToken{}
+	}
+	return tokens, nil
+}
+
+func (s *AuthService) RevokeAPIToken(userID, tokenID uint) error {
+	if s.apiTokenRepo == nil {
+		return fmt.Errorf("api token repository not configured")
+	}
+	found, err := s.apiTokenRepo.Revoke(userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrAPITokenNotFound
+	}
+	s.logger.WithField("user_id", userID).WithField("token_id", tokenID).Info("API token revoked")
+	return nil
+}
+
+// AuthenticateAPIToken проверяет ключ из заголовка Authorization и отмечает его использование
+func (s *AuthService) AuthenticateAPIToken(plain, ip string) (*models.APIToken, *models.User, error) {
+	if s.apiTokenRepo == nil {
+		return nil, nil, fmt.Errorf("api token repository not configured")
+	}
+	token, err := s.apiTokenRepo.FindByHash(hashSecret(plain))
+	if err != nil {
+		return nil, nil, err
+	}
+	if token == nil || token.RevokedAt != nil {
+		return nil, nil, ErrInvalidAPIToken
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrInvalidAPIToken
+	}
+
+	user, err := s.findUser(token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrInvalidAPIToken
+	}
+	if err := s.CheckBan(user.ID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.apiTokenRepo.TouchLastUsed(token.ID, ip); err != nil {
+		s.logger.WithError(err).WithField("token_id", token.ID).Warn("failed to update api token last use")
+	}
+	return token, user, nil
+}
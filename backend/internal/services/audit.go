@@ -0,0 +1,142 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Действия в журнале аудита
+const (
+	AuditLogin            = "auth.login"
+	AuditRefresh          = "auth.refresh"
+	AuditRoleChanged      = "user.role_changed"
+	AuditRoleAutoPromoted = "user.role_auto_promoted"
+	AuditRoleSynced       = "user.role_synced"
+	AuditCharacterCreated = "character.created"
+	AuditCharacterUpdated = "character.updated"
+	AuditCharacterDeleted = "character.deleted"
+)
+
+// AuditSystemActor — действие самого сервиса (ADMIN_DISCORD_IDS, синхронизация ролей Discord)
+const AuditSystemActor = "system"
+
+const (
+	DefaultAuditPageSize = 100
+	maxAuditPageSize     = 500
+	// MaxAuditExportSize — сколько событий отдает выгрузка в CSV/JSON
+	MaxAuditExportSize = 10000
+)
+
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+type AuditRepository interface {
+	Create(e *models.AuditEvent) error
+	Find(filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// AuditLog пишет журнал аудита. Ошибка записи не отменяет само действие: она только логируется.
+// nil *AuditLog ничего не пишет — так сервисы работают без журнала.
+type AuditLog struct {
+	repo   AuditRepository
+	logger *logrus.Logger
+}
+
+func NewAuditLog(repo AuditRepository, logger *logrus.Logger) *AuditLog {
+	return &AuditLog{repo: repo, logger: logger}
+}
+
+// Record записывает событие; before и after сохраняются как JSON, nil — пустое значение.
+// Кто действует, берется из client.Actor, а если запрос не аутентифицирован (вход) — actor.
+func (a *AuditLog) Record(action, actor, target string, before, after any, client models.ClientInfo) {
+	if a == nil {
+		return
+	}
+	if client.Actor != "" {
+		actor = client.Actor
+	}
+	event := &models.AuditEvent{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Before:    a.marshal(before),
+		After:     a.marshal(after),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+	}
+	if err := a.repo.Create(event); err != nil {
+		a.logger.WithError(err).WithFields(logrus.Fields{
+			"action": action,
+			"actor":  actor,
+			"target": target,
+		}).Error("Failed to write audit event")
+	}
+}
+
+func (a *AuditLog) marshal(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		a.logger.WithError(err).Warn("Failed to encode audit event value")
+		return nil
+	}
+	if string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+// Find возвращает страницу журнала; maxLimit — сколько событий можно получить за раз
+func (a *AuditLog) Find(filter models.AuditFilter, maxLimit int) ([]models.AuditEvent, error) {
+	if a == nil {
+		return nil, fmt.Errorf("audit repository not configured")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditPageSize
+	}
+	if filter.Limit > maxLimit {
+		return nil, fmt.Errorf("%w: limit must not exceed %d", ErrInvalidAuditFilter, maxLimit)
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidAuditFilter)
+	}
+	events, err := a.repo.Find(filter)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	return events, nil
+}
+
+// UserActor — пользователь как actor или target журнала
+func UserActor(id uint) string {
+	return "user:" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (s *AuthService) WithAuditLog(audit *AuditLog) *AuthService {
+	s.audit = audit
+	return s
+}
+
+// ListAuditEvents — страница журнала для админки
+func (s *AuthService) ListAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	return s.audit.Find(filter, maxAuditPageSize)
+}
+
+// ExportAuditEvents — выгрузка журнала целиком (до MaxAuditExportSize событий)
+func (s *AuthService) ExportAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = MaxAuditExportSize
+	}
+	return s.audit.Find(filter, MaxAuditExportSize)
+}
@@ -3,22 +3,51 @@ package services
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"user-service/internal/config"
+	"user-service/internal/database"
+	"user-service/internal/keys"
 	"user-service/internal/models"
+	"user-service/internal/providers"
+	"user-service/internal/secrets"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 type AuthService struct {
-	config    *config.Config
-	logger    *logrus.Logger
-	userRepo  UserRepository
-	tokenRepo RefreshTokenRepository
+	config            *config.Config
+	logger            *logrus.Logger
+	userRepo          UserRepository
+	tokenRepo         RefreshTokenRepository
+	stateRepo         OAuthStateRepository
+	identityRepo      IdentityRepository
+	clientRepo        OAuthClientRepository
+	authorizationRepo AuthorizationRepository
+	apiTokenRepo      APITokenRepository
+	deviceCodeRepo    DeviceCodeRepository
+	impersonationRepo ImpersonationRepository
+	mfaRepo           MFARepository
+	passkeyRepo       PasskeyRepository
+	grantRepo         ProviderGrantRepository
+	discord           DiscordAccount
+	secretCipher      *secrets.Cipher
+	audit             *AuditLog
+	loginRepo         LoginEventRepository
+	loginWebhook      WebhookSender
+	revocations       *RevocationList
+	bans              *BanList
+	disabledClients   *DisabledClients
+	guilds            GuildDirectory
+	roles             *RoleCache
+	keyring           *keys.Keyring
 
 	mutex sync.RWMutex
 }
@@ -32,7 +61,7 @@ func NewAuthService(cfg *config.Config, logger *logrus.Logger) *AuthService {
 
 // Repositories interfaces
 type UserRepository interface {
-	UpsertByDiscordID(discordID, username, discriminator, avatar string) (*models.User, error)
+	UpsertByIdentity(provider, subject, username, discriminator, email, avatar string) (*models.User, error)
 	FindByID(id uint) (*models.User, error)
 	FindAll() ([]models.User, error)
 	UpdateRole(id uint, role string) error
@@ -40,7 +69,25 @@ type UserRepository interface {
 
 type RefreshTokenRepository interface {
 	Save(rt *models.RefreshToken) error
-	Find(token string) (*models.RefreshToken, error)
+	FindByHash(tokenHash string) (*models.RefreshToken, error)
+	Rotate(old, next *models.RefreshToken) error
+	RevokeFamily(familyID string) error
+	RevokeUserFamily(userID uint, familyID string) (bool, error)
+	RevokeAllForUser(userID uint) error
+	SessionActive(familyID string) (bool, error)
+	FindSessions(userID uint) ([]models.Session, error)
+}
+
+// WithSecretCipher задает шифрование секретов в БД (TOKEN_ENCRYPTION_KEY); nil — не шифровать
+func (s *AuthService) WithSecretCipher(cipher *secrets.Cipher) *AuthService {
+	s.secretCipher = cipher
+	return s
+}
+
+// WithKeyring задает ключи, которыми подписываются access token
+func (s *AuthService) WithKeyring(keyring *keys.Keyring) *AuthService {
+	s.keyring = keyring
+	return s
 }
 
 func (s *AuthService) WithRepositories(userRepo UserRepository, tokenRepo RefreshTokenRepository) *AuthService {
@@ -49,96 +96,248 @@ func (s *AuthService) WithRepositories(userRepo UserRepository, tokenRepo Refres
 	return s
 }
 
-func (s *AuthService) GenerateTokens(user *models.User) (string, string, error) {
-	// Access token (15 минут)
-	accessClaims := jwt.MapClaims{
-		"sub":  user.ID,
-		"role": user.Role,
-		"exp":  time.Now().Add(15 * time.Minute).Unix(),
-		"iat":  time.Now().Unix(),
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// GenerateTokens выдает access token и refresh token новой семьи (новый вход).
+// scopes — запрошенные при входе scope; пустой список — все, что разрешает роль.
+func (s *AuthService) GenerateTokens(user *models.User, scopes []string, client models.ClientInfo) (string, string, error) {
+	return s.generateTokens(user, "", scopes, client)
+}
+
+// generateTokens — GenerateTokens для сессии, выданной OAuth-клиенту (device flow);
+// clientID попадает в refresh token и в каждый access token этой сессии
+func (s *AuthService) generateTokens(user *models.User, clientID string, scopes []string, client models.ClientInfo) (string, string, error) {
+	if s.tokenRepo == nil {
+		return "", "", fmt.Errorf("token repository not configured")
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(s.config.JWTSecret))
-	if err != nil {
+	if err := s.CheckBan(user.ID); err != nil {
+		s.recordLogin(user.ID, models.LoginKindLogin, LoginFailureBanned, client)
 		return "", "", err
 	}
 
-	// Refresh token (7 дней)
-	refreshTokenBytes := make([]byte, 32)
-	if _, err := rand.Read(refreshTokenBytes); err != nil {
+	refreshToken, refreshTokenString, err := newRefreshToken(user.ID, uuid.New().String(), clientID, strings.Join(scopes, " "), client)
+	if err != nil {
 		return "", "", err
 	}
-	refreshTokenString := hex.EncodeToString(refreshTokenBytes)
 
+	accessTokenString, err := s.issueAccessToken(user, refreshToken)
+	if err != nil {
+		return "", "", err
+	}
 	// Сохраняем refresh token в БД
-	if s.tokenRepo != nil {
-		_ = s.tokenRepo.Save(&models.RefreshToken{
-			UserID:    user.ID,
-			Token:     refreshTokenString,
-			ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-			CreatedAt: time.Now(),
-		})
+	if err := s.tokenRepo.Save(refreshToken); err != nil {
+		return "", "", err
 	}
+	s.audit.Record(AuditLogin, UserActor(user.ID), UserActor(user.ID), nil, map[string]string{
+		"session_id": refreshToken.FamilyID,
+		"scope":      refreshToken.Scope,
+	}, client)
+	s.recordLogin(user.ID, models.LoginKindLogin, "", client)
 
 	return accessTokenString, refreshTokenString, nil
 }
 
-func (s *AuthService) RefreshAccessToken(refreshTokenString string) (string, error) {
-	var refreshToken *models.RefreshToken
-	var err error
+// RefreshTokens обменивает refresh token на новую пару токенов; старый refresh token
+// становится недействительным. Повторное предъявление уже обменянного токена
+// отзывает всю семью.
+func (s *AuthService) RefreshTokens(refreshTokenString string, client models.ClientInfo) (string, string, error) {
+	if s.tokenRepo == nil {
+		return "", "", fmt.Errorf("token repository not configured")
+	}
 
-	if s.tokenRepo != nil {
-		refreshToken, err = s.tokenRepo.Find(refreshTokenString)
-		if err != nil || refreshToken == nil {
-			s.logger.Warnf("Refresh token not found: %s", refreshTokenString[:10]+"...")
-			return "", fmt.Errorf("invalid refresh token")
-		}
-	} else {
-		return "", fmt.Errorf("token repository not configured")
+	refreshToken, err := s.tokenRepo.FindByHash(hashSecret(refreshTokenString))
+	if err != nil || refreshToken == nil {
+		s.logger.Warnf("Refresh token not found: %s", tokenPrefix(refreshTokenString))
+		return "", "", ErrInvalidRefreshToken
 	}
 
+	// Сессии заблокированного пользователя уже отозваны, но так он увидит причину
+	if err := s.CheckBan(refreshToken.UserID); err != nil {
+		s.recordLogin(refreshToken.UserID, models.LoginKindRefresh, LoginFailureBanned, client)
+		return "", "", err
+	}
+	if refreshToken.RevokedAt != nil {
+		s.logger.Warnf("Refresh token revoked: %s", tokenPrefix(refreshTokenString))
+		s.recordLogin(refreshToken.UserID, models.LoginKindRefresh, LoginFailureTokenRevoked, client)
+		return "", "", ErrInvalidRefreshToken
+	}
+	if refreshToken.RotatedAt != nil {
+		s.revokeReusedFamily(refreshToken)
+		s.recordLogin(refreshToken.UserID, models.LoginKindRefresh, LoginFailureTokenReused, client)
+		return "", "", ErrRefreshTokenReused
+	}
 	if refreshToken.ExpiresAt.Before(time.Now()) {
-		s.logger.Warnf("Refresh token expired: %s", refreshTokenString[:10]+"...")
-		return "", fmt.Errorf("invalid refresh token")
+		s.logger.Warnf("Refresh token expired: %s", tokenPrefix(refreshTokenString))
+		s.recordLogin(refreshToken.UserID, models.LoginKindRefresh, LoginFailureTokenExpired, client)
+		return "", "", ErrInvalidRefreshToken
 	}
 
 	// Находим пользователя
 	user, err := s.userRepo.FindByID(refreshToken.UserID)
-	if user == nil {
+	if err != nil || user == nil {
 		s.logger.Warnf("User not found for refresh token: UserID=%d", refreshToken.UserID)
-		return "", fmt.Errorf("user not found")
+		return "", "", fmt.Errorf("user not found")
 	}
 
 	s.logger.Infof("Found user for refresh: ID=%d, Username=%s", user.ID, user.Username)
 
+	// Сессия устройства живет, пока включен клиент; после включения снова обновляется
+	if refreshToken.ClientID != "" {
+		if s.clientRepo == nil {
+			return "", "", fmt.Errorf("oauth client repository not configured")
+		}
+		oauthClient, err := s.clientRepo.FindByID(refreshToken.ClientID)
+		if err != nil {
+			return "", "", err
+		}
+		if oauthClient == nil || oauthClient.Disabled() {
+			s.logger.WithField("client_id", refreshToken.ClientID).Warn("Refresh token of disabled client presented")
+			s.recordLogin(user.ID, models.LoginKindRefresh, LoginFailureClientDisabled, client)
+			return "", "", ErrInvalidRefreshToken
+		}
+	}
+
+	if err := s.checkGuildMembership(user); err != nil {
+		if rerr := s.tokenRepo.RevokeFamily(refreshToken.FamilyID); rerr != nil {
+			s.logger.WithError(rerr).WithField("family_id", refreshToken.FamilyID).Error("Failed to revoke session of former guild member")
+		}
+		s.recordLogin(user.ID, models.LoginKindRefresh, LoginFailureGuildMembership, client)
+		return "", "", err
+	}
+
+	next, nextString, err := newRefreshToken(user.ID, refreshToken.FamilyID, refreshToken.ClientID, refreshToken.Scope, client)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.tokenRepo.Rotate(refreshToken, next); err != nil {
+		if errors.Is(err, database.ErrTokenAlreadyRotated) {
+			// Параллельный запрос успел обменять этот же токен
+			s.revokeReusedFamily(refreshToken)
+			s.recordLogin(user.ID, models.LoginKindRefresh, LoginFailureTokenReused, client)
+			return "", "", ErrRefreshTokenReused
+		}
+		return "", "", err
+	}
+
 	// Генерируем новый access token
+	accessToken, err := s.issueAccessToken(user, next)
+	if err != nil {
+		return "", "", err
+	}
+	s.audit.Record(AuditRefresh, UserActor(user.ID), UserActor(user.ID), nil, map[string]string{
+		"session_id": next.FamilyID,
+	}, client)
+	s.recordLogin(user.ID, models.LoginKindRefresh, "", client)
+	return accessToken, nextString, nil
+}
+
+func (s *AuthService) revokeReusedFamily(rt *models.RefreshToken) {
+	s.logger.WithFields(logrus.Fields{
+		"user_id":   rt.UserID,
+		"family_id": rt.FamilyID,
+		"token_id":  rt.ID,
+	}).Warn("Rotated refresh token was presented again, revoking token family (possible token theft)")
+
+	if err := s.tokenRepo.RevokeFamily(rt.FamilyID); err != nil {
+		s.logger.WithError(err).WithField("family_id", rt.FamilyID).Error("Failed to revoke refresh token family")
+		return
+	}
+	s.revokeSessionAccess(rt.FamilyID)
+}
+
+// issueAccessToken подписывает access token сессии: sid — ID семьи refresh-токенов,
+// scope и client_id берутся из refresh token
+func (s *AuthService) issueAccessToken(user *models.User, session *models.RefreshToken) (string, error) {
+	now := time.Now()
 	accessClaims := jwt.MapClaims{
-		"sub":  user.ID,
-		"role": user.Role,
-		"exp":  time.Now().Add(15 * time.Minute).Unix(),
-		"iat":  time.Now().Unix(),
+		"sub":   Subject(user.ID),
+		"role":  user.Role,
+		"sid":   session.FamilyID,
+		"scope": strings.Join(s.grantedScopes(user.Role, strings.Fields(session.Scope)), " "),
+		"jti":   uuid.New().String(),
+		"exp":   now.Add(accessTokenTTL).Unix(),
+		"iat":   now.Unix(),
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	return accessToken.SignedString([]byte(s.config.JWTSecret))
+	if session.ClientID != "" {
+		accessClaims["client_id"] = session.ClientID
+	}
+	return s.keyring.Sign(accessClaims)
+}
+
+// Subject — значение claim sub: по RFC 7519 это строка
+func Subject(userID uint) string {
+	return strconv.FormatUint(uint64(userID), 10)
 }
 
-func (s *AuthService) CreateOrUpdateUser(discordID, username, discriminator, avatar string) (*models.User, error) {
+// SubjectUserID достает ID пользователя из claim sub. Числовой sub остался
+// в access-токенах, выданных до перехода на строку, пока они не истекли
+func SubjectUserID(claims jwt.MapClaims) (uint, bool) {
+	switch sub := claims["sub"].(type) {
+	case string:
+		id, err := strconv.ParseUint(sub, 10, 32)
+		return uint(id), err == nil
+	case float64:
+		return uint(sub), true
+	}
+	return 0, false
+}
+
+// newRefreshToken возвращает запись для БД (в ней только хеш) и открытый токен для клиента
+func newRefreshToken(userID uint, familyID, clientID, scope string, client models.ClientInfo) (*models.RefreshToken, string, error) {
+	refreshTokenBytes := make([]byte, 32)
+	if _, err := rand.Read(refreshTokenBytes); err != nil {
+		return nil, "", err
+	}
+	plain := hex.EncodeToString(refreshTokenBytes)
+	now := time.Now()
+	return &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashSecret(plain),
+		FamilyID:  familyID,
+		ClientID:  clientID,
+		Scope:     scope,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		ExpiresAt: now.Add(refreshTokenTTL),
+		CreatedAt: now,
+	}, plain, nil
+}
+
+// tokenPrefix — безопасный для логов кусок токена
+func tokenPrefix(token string) string {
+	if len(token) <= 10 {
+		return "..."
+	}
+	return token[:10] + "..."
+}
+
+func (s *AuthService) CreateOrUpdateUser(identity *providers.Identity, client models.ClientInfo) (*models.User, error) {
 	if s.userRepo == nil {
 		return nil, fmt.Errorf("user repository not configured")
 	}
-	user, err := s.userRepo.UpsertByDiscordID(discordID, username, discriminator, avatar)
+	user, err := s.userRepo.UpsertByIdentity(identity.Provider, identity.Subject, identity.Username, identity.Discriminator, identity.Email, identity.AvatarURL)
 	if err != nil {
 		return nil, err
 	}
 
-	// Auto-promote to admin if discordID is in configured list
-	if len(s.config.AdminDiscordIDs) > 0 && user.Role != "admin" {
+	// Auto-promote to admin if discord ID is in configured list
+	if identity.Provider == "discord" && len(s.config.AdminDiscordIDs) > 0 && user.Role != AdminRole {
 		for _, adminID := range s.config.AdminDiscordIDs {
-			if adminID == discordID {
-				if err := s.userRepo.UpdateRole(user.ID, "admin"); err != nil {
-					s.logger.WithError(err).Warnf("failed to promote user %s to admin", discordID)
+			if adminID == identity.Subject {
+				if err := s.userRepo.UpdateRole(user.ID, AdminRole); err != nil {
+					s.logger.WithError(err).Warnf("failed to promote user %s to admin", identity.Subject)
 				} else {
-					user.Role = "admin"
+					s.audit.Record(AuditRoleAutoPromoted, AuditSystemActor, UserActor(user.ID),
+						map[string]string{"role": user.Role}, map[string]string{"role": AdminRole}, client)
+					user.Role = AdminRole
 				}
 				break
 			}
@@ -175,16 +374,28 @@ func (s *AuthService) GetAllUsers() []models.User {
 	return users
 }
 
-func (s *AuthService) UpdateUserRole(id uint, role string) error {
-	validRoles := map[string]bool{
-		"user":      true,
-		"moderator": true,
-		"admin":     true,
+// UpdateUserRole назначает роль; actorPermissions — права того, кто назначает:
+// нельзя выдать роль с правами, которых нет у себя, и нельзя менять роль тому, у кого прав больше
+func (s *AuthService) UpdateUserRole(actorPermissions []string, id uint, role string, client models.ClientInfo) error {
+	if s.roles == nil || !s.roles.Exists(role) {
+		return ErrRoleNotFound
+	}
+	user, err := s.findUser(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
 	}
-	if !validRoles[role] {
-		return fmt.Errorf("invalid role")
+	if err := s.checkCanManage(actorPermissions, user); err != nil {
+		return err
 	}
-	return s.userRepo.UpdateRole(id, role)
+	if err := checkGrantable(actorPermissions, s.roles.Permissions(role)); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateRole(id, role); err != nil {
+		return err
+	}
+	s.audit.Record(AuditRoleChanged, "", UserActor(id), map[string]string{"role": user.Role}, map[string]string{"role": role}, client)
+	return nil
 }
-
-
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUserBanned       = errors.New("user is banned")
+	ErrBanNotFound      = errors.New("user is not banned")
+	ErrInvalidBanParams = errors.New("invalid ban parameters")
+)
+
+// BannedError — ErrUserBanned вместе с самой блокировкой, чтобы показать причину и срок
+type BannedError struct {
+	Ban *models.UserBan
+}
+
+func (e *BannedError) Error() string { return BanMessage(e.Ban) }
+
+func (e *BannedError) Unwrap() error { return ErrUserBanned }
+
+// BanMessage — текст ошибки для заблокированного пользователя
+func BanMessage(ban *models.UserBan) string {
+	if ban.Kind == models.BanKindSuspension {
+		return "Account is suspended"
+	}
+	return "Account is banned"
+}
+
+// BanResponse — тело ответа заблокированному пользователю: что, почему и до какого времени.
+// Кто заблокировал, не сообщается.
+func BanResponse(ban *models.UserBan) map[string]interface{} {
+	return map[string]interface{}{
+		"error":      BanMessage(ban),
+		"kind":       ban.Kind,
+		"reason":     ban.Reason,
+		"expires_at": ban.ExpiresAt,
+	}
+}
+
+type BanRepository interface {
+	Create(b *models.UserBan) error
+	FindActive() ([]models.UserBan, error)
+	FindByUserID(userID uint) ([]models.UserBan, error)
+	Lift(userID, liftedBy uint) (bool, error)
+}
+
+// BanList — действующие блокировки в памяти, проверяются на каждый запрос.
+// Блокировка на другом инстансе вступает в силу не позже чем через интервал Run.
+type BanList struct {
+	repo   BanRepository
+	logger *logrus.Logger
+
+	mu   sync.RWMutex
+	bans map[uint]models.UserBan
+}
+
+func NewBanList(repo BanRepository, logger *logrus.Logger) *BanList {
+	return &BanList{
+		repo:   repo,
+		logger: logger,
+		bans:   map[uint]models.UserBan{},
+	}
+}
+
+// Reload перечитывает действующие блокировки из БД
+func (l *BanList) Reload() error {
+	active, err := l.repo.FindActive()
+	if err != nil {
+		return err
+	}
+	bans := make(map[uint]models.UserBan, len(active))
+	for _, b := range active {
+		bans[b.UserID] = b
+	}
+	l.mu.Lock()
+	l.bans = bans
+	l.mu.Unlock()
+	return nil
+}
+
+// Run периодически вызывает Reload, пока не закроют stop
+func (l *BanList) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.Reload(); err != nil {
+				l.logger.WithError(err).Error("Failed to reload user bans")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ActiveBan возвращает действующую блокировку пользователя или nil
+func (l *BanList) ActiveBan(userID uint) *models.UserBan {
+	l.mu.RLock()
+	b, ok := l.bans[userID]
+	l.mu.RUnlock()
+	if !ok || (b.ExpiresAt != nil && b.ExpiresAt.Before(time.Now())) {
+		return nil
+	}
+	return &b
+}
+
+func (l *BanList) set(b models.UserBan) {
+	l.mu.Lock()
+	l.bans[b.UserID] = b
+	l.mu.Unlock()
+}
+
+func (l *BanList) remove(userID uint) {
+	l.mu.Lock()
+	delete(l.bans, userID)
+	l.mu.Unlock()
+}
+
+func (s *AuthService) WithBans(bans *BanList) *AuthService {
+	s.bans = bans
+	return s
+}
+
+// CheckBan возвращает *BannedError, если пользователь заблокирован
+func (s *AuthService) CheckBan(userID uint) error {
+	if s.bans == nil {
+		return nil
+	}
+	if ban := s.bans.ActiveBan(userID); ban != nil {
+		return &BannedError{Ban: ban}
+	}
+	return nil
+}
+
+type BanParams struct {
+	Kind      string
+	Reason    string
+	ExpiresAt *time.Time
+}
+
+// BanUser блокирует пользователя и завершает все его сессии
+func (s *AuthService) BanUser(actorID uint, actorPermissions []string, userID uint, params BanParams) (*models.UserBan, error) {
+	if s.bans == nil {
+		return nil, fmt.Errorf("bans not configured")
+	}
+	if params.Kind != models.BanKindSuspension && params.Kind != models.BanKindBan {
+		return nil, fmt.Errorf("%w: kind must be %s or %s", ErrInvalidBanParams, models.BanKindSuspension, models.BanKindBan)
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" || len(params.Reason) > 500 {
+		return nil, fmt.Errorf("%w: reason must be 1-500 characters", ErrInvalidBanParams)
+	}
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidBanParams)
+	}
+	if params.Kind == models.BanKindSuspension && params.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: suspension requires expires_at", ErrInvalidBanParams)
+	}
+	if actorID == userID {
+		return nil, fmt.Errorf("%w: cannot ban yourself", ErrInvalidBanParams)
+	}
+
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkCanManage(actorPermissions, user); err != nil {
+		return nil, err
+	}
+
+	ban := &models.UserBan{
+		UserID:    userID,
+		Kind:      params.Kind,
+		Reason:    params.Reason,
+		BannedBy:  &actorID,
+		ExpiresAt: params.ExpiresAt,
+	}
+	if err := s.bans.repo.Create(ban); err != nil {
+		return nil, err
+	}
+	s.bans.set(*ban)
+
+	if err := s.RevokeAllSessions(userID); err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Error("Failed to revoke sessions of banned user")
+	}
+	s.logger.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"actor_id": actorID,
+		"kind":     ban.Kind,
+	}).Warn("User banned")
+	return ban, nil
+}
+
+// LiftBan снимает действующую блокировку
+func (s *AuthService) LiftBan(actorID uint, actorPermissions []string, userID uint) error {
+	if s.bans == nil {
+		return fmt.Errorf("bans not configured")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkCanManage(actorPermissions, user); err != nil {
+		return err
+	}
+	found, err := s.bans.repo.Lift(userID, actorID)
+	if err != nil {
+		return err
+	}
+	s.bans.remove(userID)
+	if !found {
+		return ErrBanNotFound
+	}
+	s.logger.WithFields(logrus.Fields{"user_id": userID, "actor_id": actorID}).Info("User ban lifted")
+	return nil
+}
+
+// ListBans возвращает действующие блокировки
+func (s *AuthService) ListBans() ([]models.UserBan, error) {
+	if s.bans == nil {
+		return nil, fmt.Errorf("bans not configured")
+	}
+	bans, err := s.bans.repo.FindActive()
+	if err != nil {
+		return nil, err
+	}
+	if bans == nil {
+		bans = []models.UserBan{}
+	}
+	return bans, nil
+}
+
+// GetUserBans возвращает историю блокировок пользователя
+func (s *AuthService) GetUserBans(userID uint) ([]models.UserBan, error) {
+	if s.bans == nil {
+		return nil, fmt.Errorf("bans not configured")
+	}
+	bans, err := s.bans.repo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if bans == nil {
+		bans = []models.UserBan{}
+	}
+	return bans, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+type memoryRoleRepo struct {
+	RoleRepository
+	roles []models.Role
+}
+
+func (r *memoryRoleRepo) FindAll() ([]models.Role, error)                  { return r.roles, nil }
+func (r *memoryRoleRepo) FindAllPermissions() ([]models.Permission, error) { return nil, nil }
+
+type memoryUserRepo struct {
+	UserRepository
+	users map[uint]*models.User
+}
+
+func (r *memoryUserRepo) FindByID(id uint) (*models.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, nil
+}
+
+// liftBanRepo — у каждого пользователя есть действующая блокировка
+type liftBanRepo struct {
+	BanRepository
+	lifted []uint
+}
+
+func (r *liftBanRepo) Lift(userID, liftedBy uint) (bool, error) {
+	r.lifted = append(r.lifted, userID)
+	return true, nil
+}
+
+func TestLiftBanRoleHierarchy(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	roles := NewRoleCache(&memoryRoleRepo{roles: []models.Role{
+		{Name: "admin", Permissions: []string{"users:read", "users:ban", "roles:manage"}},
+		{Name: "moderator", Permissions: []string{"users:read", "users:ban"}},
+		{Name: "user"},
+	}}, logger)
+	if err := roles.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUserRepo{users: map[uint]*models.User{
+		1: {ID: 1, Role: "admin"},
+		2: {ID: 2, Role: "moderator"},
+		3: {ID: 3, Role: "user"},
+	}}
+	moderator := roles.Permissions("moderator")
+
+	tests := []struct {
+		name    string
+		userID  uint
+		wantErr error
+	}{
+		{"lower role", 3, nil},
+		{"same role", 2, nil},
+		{"higher role", 1, ErrRoleEscalation},
+		{"unknown user", 9, ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &liftBanRepo{}
+			s := NewAuthService(&config.Config{}, logger).
+				WithRepositories(users, nil).
+				WithRoles(roles).
+				WithBans(NewBanList(repo, logger))
+			err := s.LiftBan(2, moderator, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LiftBan = %v, want %v", err, tt.wantErr)
+			}
+			if lifted := len(repo.lifted) > 0; lifted != (tt.wantErr == nil) {
+				t.Errorf("ban lifted = %v", lifted)
+			}
+		})
+	}
+}
+
+func TestBanUserRoleHierarchy(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	roles := NewRoleCache(&memoryRoleRepo{roles: []models.Role{
+		{Name: "admin", Permissions: []string{"users:read", "users:ban", "roles:manage"}},
+		{Name: "moderator", Permissions: []string{"users:read", "users:ban"}},
+	}}, logger)
+	if err := roles.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUserRepo{users: map[uint]*models.User{1: {ID: 1, Role: "admin"}}}
+	s := NewAuthService(&config.Config{}, logger).
+		WithRepositories(users, nil).
+		WithRoles(roles).
+		WithBans(NewBanList(&liftBanRepo{}, logger))
+	expires := time.Now().Add(time.Hour)
+	_, err := s.BanUser(2, roles.Permissions("moderator"), 1, BanParams{
+		Kind:      models.BanKindSuspension,
+		Reason:    "spam",
+		ExpiresAt: &expires,
+	})
+	if !errors.Is(err, ErrRoleEscalation) {
+		t.Fatalf("BanUser = %v, want ErrRoleEscalation", err)
+	}
+}
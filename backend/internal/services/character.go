@@ -14,6 +14,7 @@ import (
 type CharacterService struct {
 	characterRepo *database.CharacterRepo
 	logger        *logrus.Logger
+	audit         *AuditLog
 }
 
 func NewCharacterService(characterRepo *database.CharacterRepo, logger *logrus.Logger) *CharacterService {
@@ -23,6 +24,11 @@ func NewCharacterService(characterRepo *database.CharacterRepo, logger *logrus.L
 	}
 }
 
+func (s *CharacterService) WithAuditLog(audit *AuditLog) *CharacterService {
+	s.audit = audit
+	return s
+}
+
 type CreateCharacterRequest struct {
 	Name      string `json:"name" binding:"required"`
 	Level     int    `json:"level" binding:"required,min=1"`
@@ -87,7 +93,7 @@ type UpdateCharacterRequest struct {
 	} `json:"vip_status,omitempty"`
 }
 
-func (s *CharacterService) CreateCharacter(req *CreateCharacterRequest, userID uint) (*models.Character, error) {
+func (s *CharacterService) CreateCharacter(req *CreateCharacterRequest, userID uint, client models.ClientInfo) (*models.Character, error) {
 	// Generate unique character ID
 	characterID := uuid.New().String()
 
@@ -151,6 +157,7 @@ func (s *CharacterService) CreateCharacter(req *CreateCharacterRequest, userID u
 		"user_id":      userID,
 		"server_id":    req.ServerID,
 	}).Info("Character created successfully")
+	s.audit.Record(AuditCharacterCreated, UserActor(userID), characterTarget(character.ID), nil, character, client)
 
 	return character, nil
 }
@@ -193,7 +200,7 @@ func (s *CharacterService) GetUserCharacters(userID uint) ([]models.Character, e
 	return characters, nil
 }
 
-func (s *CharacterService) UpdateCharacter(id string, req *UpdateCharacterRequest, userID uint) (*models.Character, error) {
+func (s *CharacterService) UpdateCharacter(id string, req *UpdateCharacterRequest, userID uint, client models.ClientInfo) (*models.Character, error) {
 	// Get existing character
 	character, err := s.characterRepo.FindByID(id)
 	if err != nil {
@@ -204,6 +211,8 @@ func (s *CharacterService) UpdateCharacter(id string, req *UpdateCharacterReques
 	if character.UserID != userID {
 		return nil, fmt.Errorf("character not found")
 	}
+	// Статусы ниже заменяются новыми указателями, поэтому копии достаточно для журнала
+	before := *character
 
 	// Update fields if provided
 	if req.Name != nil {
@@ -270,11 +279,12 @@ func (s *CharacterService) UpdateCharacter(id string, req *UpdateCharacterReques
 		"character_id": character.ID,
 		"user_id":      userID,
 	}).Info("Character updated successfully")
+	s.audit.Record(AuditCharacterUpdated, UserActor(userID), characterTarget(character.ID), &before, character, client)
 
 	return character, nil
 }
 
-func (s *CharacterService) DeleteCharacter(id string, userID uint) error {
+func (s *CharacterService) DeleteCharacter(id string, userID uint, client models.ClientInfo) error {
 	// Check if character exists and user owns it
 	character, err := s.characterRepo.FindByID(id)
 	if err != nil {
@@ -294,6 +304,11 @@ func (s *CharacterService) DeleteCharacter(id string, userID uint) error {
 		"character_id": id,
 		"user_id":      userID,
 	}).Info("Character deleted successfully")
+	s.audit.Record(AuditCharacterDeleted, UserActor(userID), characterTarget(id), character, nil, client)
 
 	return nil
+}
+
+func characterTarget(id string) string {
+	return "character:" + id
 }
\ No newline at end of file
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ServiceScopes — scope для сервисных клиентов (client_credentials)
+var ServiceScopes = []string{ScopeCharactersRead, ScopeCharactersWrite}
+
+var ErrOAuthClientNotFound = errors.New("oauth client not found")
+
+// IssueClientCredentialsToken — grant_type=client_credentials: токен выдается самому
+// сервису, поэтому в нем нет sub, только client_id и scope
+func (s *AuthService) IssueClientCredentialsToken(client *models.OAuthClient, scope string) (*OIDCTokens, error) {
+	if client.Public() || !client.AllowsGrant("client_credentials") {
+		return nil, oauthError("unauthorized_client", "client is not allowed to use client_credentials")
+	}
+
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		for _, sc := range client.Scopes {
+			if containsString(ServiceScopes, sc) {
+				scopes = append(scopes, sc)
+			}
+		}
+	}
+	for _, sc := range scopes {
+		if !containsString(client.Scopes, sc) || !containsString(ServiceScopes, sc) {
+			return nil, oauthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", sc))
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, oauthError("invalid_scope", "client has no service scopes")
+	}
+
+	now := time.Now()
+	accessToken, err := s.keyring.Sign(jwt.MapClaims{
+		"iss":       s.Issuer(),
+		"client_id": client.ID,
+		"scope":     strings.Join(scopes, " "),
+		"jti":       uuid.New().String(),
+		"exp":       now.Add(accessTokenTTL).Unix(),
+		"iat":       now.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"client_id": client.ID,
+		"scope":     strings.Join(scopes, " "),
+	}).Info("Client credentials token issued")
+	return &OIDCTokens{
+		AccessToken: accessToken,
+		ExpiresIn:   int(accessTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// RotateClientSecret выдает новый секрет; прежний перестает работать сразу
+func (s *AuthService) RotateClientSecret(clientID string) (string, error) {
+	if s.clientRepo == nil {
+		return "", fmt.Errorf("oauth client repository not configured")
+	}
+	secret, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.clientRepo.UpdateSecret(clientID, hashSecret(secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrOAuthClientNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	s.logger.WithField("client_id", clientID).Info("OAuth client secret rotated")
+	return secret, nil
+}
+
+// DisabledClients — отключенные OAuth-клиенты в памяти: их токены AuthMiddleware
+// отклоняет на каждый запрос. Отключение на другом инстансе вступает в силу
+// не позже чем через интервал Run.
+type DisabledClients struct {
+	repo   OAuthClientRepository
+	logger *logrus.Logger
+
+	mu      sync.RWMutex
+	clients map[string]bool
+}
+
+func NewDisabledClients(repo OAuthClientRepository, logger *logrus.Logger) *DisabledClients {
+	return &DisabledClients{
+		repo:    repo,
+		logger:  logger,
+		clients: map[string]bool{},
+	}
+}
+
+// Reload перечитывает отключенных клиентов из БД
+func (d *DisabledClients) Reload() error {
+	all, err := d.repo.FindAll()
+	if err != nil {
+		return err
+	}
+	clients := map[string]bool{}
+	for _, c := range all {
+		if c.Disabled() {
+			clients[c.ID] = true
+		}
+	}
+	d.mu.Lock()
+	d.clients = clients
+	d.mu.Unlock()
+	return nil
+}
+
+// Run периодически вызывает Reload, пока не закроют stop
+func (d *DisabledClients) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Reload(); err != nil {
+				d.logger.WithError(err).Error("Failed to reload disabled OAuth clients")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ClientDisabled сообщает, отключен ли клиент
+func (d *DisabledClients) ClientDisabled(clientID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.clients[clientID]
+}
+
+func (d *DisabledClients) set(clientID string, disabled bool) {
+	d.mu.Lock()
+	if disabled {
+		d.clients[clientID] = true
+	} else {
+		delete(d.clients, clientID)
+	}
+	d.mu.Unlock()
+}
+
+func (s *AuthService) WithDisabledClients(clients *DisabledClients) *AuthService {
+	s.disabledClients = clients
+	return s
+}
+
+// SetClientDisabled отключает или включает клиента. У отключенного клиента не проходят
+// аутентификация и /authorize, а уже выданные ему токены перестают приниматься API
+// и /introspect; после включения еще не истекшие токены снова действуют.
+func (s *AuthService) SetClientDisabled(clientID string, disabled bool) error {
+	if s.clientRepo == nil {
+		return fmt.Errorf("oauth client repository not configured")
+	}
+	err := s.clientRepo.SetDisabled(clientID, disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOAuthClientNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if s.disabledClients != nil {
+		s.disabledClients.set(clientID, disabled)
+	}
+	s.logger.WithField("client_id", clientID).WithField("disabled", disabled).Info("OAuth client status changed")
+	return nil
+}
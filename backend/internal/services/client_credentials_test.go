@@ -0,0 +1,82 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+type memoryClientRepo struct {
+	OAuthClientRepository
+	clients []models.OAuthClient
+}
+
+func (r *memoryClientRepo) FindAll() ([]models.OAuthClient, error) { return r.clients, nil }
+
+func (r *memoryClientRepo) FindByID(id string) (*models.OAuthClient, error) {
+	for i := range r.clients {
+		if r.clients[i].ID == id {
+			c := r.clients[i]
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryClientRepo) SetDisabled(id string, disabled bool) error {
+	for i := range r.clients {
+		if r.clients[i].ID == id {
+			r.clients[i].DisabledAt = nil
+			if disabled {
+				now := time.Now()
+				r.clients[i].DisabledAt = &now
+			}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func TestDisabledClients(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	disabledAt := time.Now()
+	repo := &memoryClientRepo{clients: []models.OAuthClient{
+		{ID: "active"},
+		{ID: "disabled", DisabledAt: &disabledAt},
+	}}
+	clients := NewDisabledClients(repo, logger)
+	if err := clients.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if clients.ClientDisabled("active") || !clients.ClientDisabled("disabled") {
+		t.Fatalf("after Reload: active=%v disabled=%v", clients.ClientDisabled("active"), clients.ClientDisabled("disabled"))
+	}
+
+	// Изменение через сервис действует сразу, не дожидаясь Reload
+	s := NewAuthService(&config.Config{}, logger).
+		WithOAuthRepositories(repo, nil).
+		WithDisabledClients(clients)
+	if err := s.SetClientDisabled("active", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetClientDisabled("disabled", false); err != nil {
+		t.Fatal(err)
+	}
+	if !clients.ClientDisabled("active") || clients.ClientDisabled("disabled") {
+		t.Errorf("after SetClientDisabled: active=%v disabled=%v", clients.ClientDisabled("active"), clients.ClientDisabled("disabled"))
+	}
+	if err := s.SetClientDisabled("missing", true); !errors.Is(err, ErrOAuthClientNotFound) {
+		t.Errorf("SetClientDisabled(missing) = %v, want ErrOAuthClientNotFound", err)
+	}
+	if clients.ClientDisabled("missing") {
+		t.Error("missing client marked disabled")
+	}
+}
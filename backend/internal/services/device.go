@@ -0,0 +1,287 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"user-service/internal/database"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceCodeGrantType — grant_type для опроса /token устройством (RFC 8628)
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5
+	// Согласные без похожих друг на друга букв (RFC 8628, раздел 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	// Столько раз генерируем user_code заново, если он совпал с уже выданным
+	userCodeAttempts = 5
+)
+
+var ErrDeviceCodeNotFound = errors.New("device code not found or expired")
+
+type DeviceCodeRepository interface {
+	Create(d *models.DeviceCode) error
+	FindByUserCode(userCode string) (*models.DeviceCode, error)
+	Poll(deviceCodeHash string) (*models.DeviceCode, bool, error)
+	Resolve(userCode string, userID uint, status string) (bool, error)
+	Consume(id uint) (bool, error)
+	DeleteExpired() error
+}
+
+func (s *AuthService) WithDeviceCodeRepository(deviceCodeRepo DeviceCodeRepository) *AuthService {
+	s.deviceCodeRepo = deviceCodeRepo
+	return s
+}
+
+// DeviceAuthorization — ответ /device/code
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// StartDeviceAuthorization выдает устройству device_code и код для ввода пользователем;
+// scope ограничивает токены устройства так же, как при обычном входе. Без scope устройство
+// получает разрешенные клиенту DeviceScopes, но не account и admin:* — их нужно запросить явно.
+func (s *AuthService) StartDeviceAuthorization(client *models.OAuthClient, scope string) (*DeviceAuthorization, error) {
+	if s.deviceCodeRepo == nil {
+		return nil, fmt.Errorf("device code repository not configured")
+	}
+	if !client.AllowsGrant(DeviceCodeGrantType) {
+		return nil, oauthError("unauthorized_client", "client is not allowed to use the device authorization grant")
+	}
+	scopes, err := ParseScopes(scope)
+	if err != nil {
+		return nil, oauthError("invalid_scope", err.Error())
+	}
+	if len(scopes) == 0 {
+		for _, sc := range client.Scopes {
+			if containsString(DeviceScopes, sc) {
+				scopes = append(scopes, sc)
+			}
+		}
+	}
+	for _, sc := range scopes {
+		if !containsString(client.Scopes, sc) {
+			return nil, oauthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", sc))
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, oauthError("invalid_scope", "client has no scopes for devices")
+	}
+
+	deviceCode, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	// Истекшие коды удаляем до генерации: их user_code снова свободны
+	if err := s.deviceCodeRepo.DeleteExpired(); err != nil {
+		s.logger.WithError(err).Warn("failed to delete expired device codes")
+	}
+
+	d := &models.DeviceCode{
+		DeviceCodeHash: hashSecret(deviceCode),
+		ClientID:       client.ID,
+		Scope:          strings.Join(scopes, " "),
+		Status:         models.DeviceCodePending,
+		Interval:       devicePollInterval,
+		ExpiresAt:      time.Now().Add(deviceCodeTTL),
+	}
+	for attempt := 1; ; attempt++ {
+		if d.UserCode, err = randomUserCode(); err != nil {
+			return nil, err
+		}
+		err = s.deviceCodeRepo.Create(d)
+		if !errors.Is(err, database.ErrUserCodeTaken) || attempt == userCodeAttempts {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	display := FormatUserCode(d.UserCode)
+	verificationURI := strings.TrimRight(s.config.FrontendURL, "/") + "/device"
+	return &DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + display,
+		ExpiresIn:               int(deviceCodeTTL.Seconds()),
+		Interval:                d.Interval,
+	}, nil
+}
+
+// GetDeviceCode возвращает ожидающий подтверждения код для страницы /device
+func (s *AuthService) GetDeviceCode(userCode string) (*models.DeviceCode, *models.OAuthClient, error) {
+	if s.deviceCodeRepo == nil || s.clientRepo == nil {
+		return nil, nil, fmt.Errorf("device code repository not configured")
+	}
+	d, err := s.deviceCodeRepo.FindByUserCode(NormalizeUserCode(userCode))
+	if err != nil {
+		return nil, nil, err
+	}
+	if d == nil || d.Status != models.DeviceCodePending || d.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrDeviceCodeNotFound
+	}
+	client, err := s.clientRepo.FindByID(d.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil || client.Disabled() {
+		return nil, nil, ErrDeviceCodeNotFound
+	}
+	d.UserCode = FormatUserCode(d.UserCode)
+	return d, client, nil
+}
+
+// ApproveDeviceCode подтверждает вход устройства от имени пользователя
+func (s *AuthService) ApproveDeviceCode(userCode string, userID uint) error {
+	return s.resolveDeviceCode(userCode, userID, models.DeviceCodeApproved)
+}
+
+// DenyDeviceCode отклоняет вход устройства
+func (s *AuthService) DenyDeviceCode(userCode string, userID uint) error {
+	return s.resolveDeviceCode(userCode, userID, models.DeviceCodeDenied)
+}
+
+func (s *AuthService) resolveDeviceCode(userCode string, userID uint, status string) error {
+	if s.deviceCodeRepo == nil {
+		return fmt.Errorf("device code repository not configured")
+	}
+	found, err := s.deviceCodeRepo.Resolve(NormalizeUserCode(userCode), userID, status)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrDeviceCodeNotFound
+	}
+	s.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"status":  status,
+	}).Info("Device authorization resolved")
+	return nil
+}
+
+// ExchangeDeviceCode — опрос /token устройством. Пока пользователь не подтвердил вход,
+// возвращает authorization_pending; при опросе чаще интервала — slow_down.
+// После подтверждения выдает пару токенов новой сессии, привязанной к клиенту.
+func (s *AuthService) ExchangeDeviceCode(client *models.OAuthClient, deviceCode string, info models.ClientInfo) (*OIDCTokens, error) {
+	if s.deviceCodeRepo == nil {
+		return nil, fmt.Errorf("device code repository not configured")
+	}
+	if !client.AllowsGrant(DeviceCodeGrantType) {
+		return nil, oauthError("unauthorized_client", "client is not allowed to use the device authorization grant")
+	}
+	if deviceCode == "" {
+		return nil, oauthError("invalid_request", "device_code is required")
+	}
+
+	d, tooFast, err := s.deviceCodeRepo.Poll(hashSecret(deviceCode))
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "device code is invalid")
+	}
+	if d.ExpiresAt.Before(time.Now()) {
+		return nil, oauthError("expired_token", "device code has expired")
+	}
+
+	switch d.Status {
+	case models.DeviceCodePending:
+		if tooFast {
+			return nil, oauthError("slow_down", fmt.Sprintf("polling too frequently, use an interval of %d seconds", d.Interval))
+		}
+		return nil, oauthError("authorization_pending", "the user has not yet approved the device")
+	case models.DeviceCodeDenied:
+		return nil, oauthError("access_denied", "the user denied the device authorization")
+	case models.DeviceCodeApproved:
+	default:
+		return nil, oauthError("invalid_grant", "device code has already been used")
+	}
+
+	consumed, err := s.deviceCodeRepo.Consume(d.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, oauthError("invalid_grant", "device code has already been used")
+	}
+
+	user, err := s.findUser(d.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, oauthError("invalid_grant", "user no longer exists")
+	}
+	if err := s.CheckBan(user.ID); err != nil {
+		s.recordLogin(user.ID, models.LoginKindLogin, LoginFailureBanned, info)
+		return nil, oauthError("access_denied", err.Error())
+	}
+
+	// Пустой scope означал бы все scope роли, включая админские
+	scopes := strings.Fields(d.Scope)
+	if len(scopes) == 0 {
+		scopes = DeviceScopes
+	}
+	// Сессия привязана к клиенту: после его отключения токены устройства не принимаются
+	accessToken, refreshToken, err := s.generateTokens(user, client.ID, scopes, info)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"user_id":   user.ID,
+		"client_id": client.ID,
+	}).Info("Device signed in")
+	return &OIDCTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+func randomUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeUserCode приводит введенный пользователем код к виду из БД: без дефисов и пробелов, в верхнем регистре
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
+
+// FormatUserCode — код для показа пользователю: XXXX-XXXX
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"user-service/internal/config"
+	"user-service/internal/database"
+	"user-service/internal/keys"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// takenDeviceCodeRepo отвечает ErrUserCodeTaken на первые taken попыток создать код
+type takenDeviceCodeRepo struct {
+	DeviceCodeRepository
+	taken   int
+	created []string
+	scope   string
+}
+
+func (r *takenDeviceCodeRepo) Create(d *models.DeviceCode) error {
+	r.created = append(r.created, d.UserCode)
+	r.scope = d.Scope
+	if len(r.created) <= r.taken {
+		return database.ErrUserCodeTaken
+	}
+	return nil
+}
+
+func (r *takenDeviceCodeRepo) DeleteExpired() error { return nil }
+
+func TestStartDeviceAuthorization(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := &models.OAuthClient{
+		ID:         "cli",
+		GrantTypes: []string{DeviceCodeGrantType},
+		Scopes:     []string{ScopeProfile, ScopeCharactersRead, ScopeAccount, ScopeAdminUsers},
+	}
+	start := func(repo *takenDeviceCodeRepo, scope string) (*DeviceAuthorization, error) {
+		s := NewAuthService(&config.Config{FrontendURL: "http://localhost:3000"}, logger).WithDeviceCodeRepository(repo)
+		return s.StartDeviceAuthorization(client, scope)
+	}
+
+	t.Run("retries taken user code", func(t *testing.T) {
+		repo := &takenDeviceCodeRepo{taken: 2}
+		auth, err := start(repo, "")
+		if err != nil {
+			t.Fatalf("StartDeviceAuthorization: %v", err)
+		}
+		if len(repo.created) != 3 || auth.UserCode != FormatUserCode(repo.created[2]) {
+			t.Errorf("attempts = %v, user code = %s", repo.created, auth.UserCode)
+		}
+	})
+
+	t.Run("gives up after attempts", func(t *testing.T) {
+		repo := &takenDeviceCodeRepo{taken: userCodeAttempts}
+		if _, err := start(repo, ""); !errors.Is(err, database.ErrUserCodeTaken) {
+			t.Fatalf("got %v, want ErrUserCodeTaken", err)
+		}
+		if len(repo.created) != userCodeAttempts {
+			t.Errorf("attempts = %d, want %d", len(repo.created), userCodeAttempts)
+		}
+	})
+
+	t.Run("default scope excludes account and admin", func(t *testing.T) {
+		repo := &takenDeviceCodeRepo{}
+		if _, err := start(repo, ""); err != nil {
+			t.Fatal(err)
+		}
+		if repo.scope != "profile characters:read" {
+			t.Errorf("scope = %q, want %q", repo.scope, "profile characters:read")
+		}
+	})
+
+	tests := []struct {
+		name  string
+		scope string
+		ok    bool
+	}{
+		{"allowed user scope", "profile", true},
+		{"account requested explicitly", "profile account", true},
+		{"admin scope requested explicitly", "profile admin:users", true},
+		{"scope not allowed for client", "characters:write", false},
+		{"admin scope not allowed for client", "admin:system", false},
+		{"unknown scope", "openid", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := start(&takenDeviceCodeRepo{}, tt.scope)
+			if tt.ok && err != nil {
+				t.Fatalf("StartDeviceAuthorization: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected invalid_scope error")
+			}
+		})
+	}
+}
+
+func TestDeviceSessionBoundToClient(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	keyring := keys.NewKeyring(keys.NewHMACKey("test", []byte("test-secret-test-secret-test-secret")))
+	clients := &memoryClientRepo{clients: []models.OAuthClient{{ID: "cli", GrantTypes: []string{DeviceCodeGrantType}}}}
+	tokens := &memoryRefreshTokenRepo{}
+	user := &models.User{ID: 7, Role: "user"}
+	s := NewAuthService(&config.Config{}, logger).
+		WithKeyring(keyring).
+		WithRepositories(&memoryUserRepo{users: map[uint]*models.User{7: user}}, tokens).
+		WithOAuthRepositories(clients, nil)
+
+	accessToken, refreshToken, err := s.generateTokens(user, "cli", DeviceScopes, models.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := keyring.Parse(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["client_id"] != "cli" || claims["sid"] == nil {
+		t.Fatalf("client_id = %v, sid = %v", claims["client_id"], claims["sid"])
+	}
+	if claims["scope"] != "profile characters:read characters:write" {
+		t.Errorf("scope = %v", claims["scope"])
+	}
+
+	// Обновленный access token остается привязан к клиенту
+	accessToken, refreshToken, err = s.RefreshTokens(refreshToken, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if claims, err = keyring.Parse(accessToken); err != nil || claims["client_id"] != "cli" {
+		t.Fatalf("refreshed client_id = %v, %v", claims["client_id"], err)
+	}
+
+	if err := clients.SetDisabled("cli", true); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := s.IntrospectToken(refreshToken, ""); err != nil || result.Active {
+		t.Errorf("refresh token of disabled client: active = %v, %v", result.Active, err)
+	}
+	if _, _, err := s.RefreshTokens(refreshToken, models.ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("RefreshTokens with disabled client = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// После включения клиента сессия снова обновляется
+	if err := clients.SetDisabled("cli", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.RefreshTokens(refreshToken, models.ClientInfo{}); err != nil {
+		t.Errorf("RefreshTokens after enabling client: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/providers"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	discordSyncBatch = 100
+	// Пауза между пользователями, чтобы не упираться в rate limit Discord
+	discordSyncPause = 250 * time.Millisecond
+	// Access token обновляется заранее, если истекает раньше
+	grantRefreshMargin = 5 * time.Minute
+)
+
+type ProviderGrantRepository interface {
+	Save(g *models.ProviderGrant) error
+	ClaimDue(provider string, syncedBefore time.Time, limit int) ([]models.ProviderGrant, error)
+	MarkRevoked(identityID uint) error
+}
+
+// DiscordAccount — запросы к Discord от имени пользователя по сохраненным токенам
+type DiscordAccount interface {
+	Refresh(ctx context.Context, refreshToken string) (*providers.Token, error)
+	FetchIdentity(ctx context.Context, token *providers.Token) (*providers.Identity, error)
+}
+
+// WithProviderGrants включает хранение токенов Discord; без WithSecretCipher (не задан TOKEN_ENCRYPTION_KEY) они не сохраняются
+func (s *AuthService) WithProviderGrants(grantRepo ProviderGrantRepository, discord DiscordAccount) *AuthService {
+	s.grantRepo = grantRepo
+	s.discord = discord
+	return s
+}
+
+// StoreProviderGrant сохраняет токены Discord, полученные при входе или привязке учетной записи
+func (s *AuthService) StoreProviderGrant(identity *providers.Identity, token *providers.Token) error {
+	if s.grantRepo == nil || s.secretCipher == nil || identity.Provider != "discord" || token.RefreshToken == "" {
+		return nil
+	}
+	if s.identityRepo == nil {
+		return fmt.Errorf("identity repository not configured")
+	}
+	stored, err := s.identityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return ErrIdentityNotFound
+	}
+	return s.saveGrant(stored.ID, token)
+}
+
+// RunDiscordSync каждые interval обновляет очередную пачку пользователей, чей профиль
+// не синхронизировался дольше DISCORD_SYNC_INTERVAL
+func (s *AuthService) RunDiscordSync(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.SyncDiscordProfiles(context.Background()); err != nil {
+				s.logger.WithError(err).Error("Failed to sync Discord profiles")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// SyncDiscordProfiles обновляет имя, аватар и роли по серверу Discord для одной пачки пользователей.
+// Если Discord больше не принимает токены, учетная запись отмечается (grant_revoked_at), а токены удаляются.
+func (s *AuthService) SyncDiscordProfiles(ctx context.Context) (int, error) {
+	if s.grantRepo == nil || s.secretCipher == nil || s.discord == nil || s.config.DiscordSyncInterval <= 0 {
+		return 0, nil
+	}
+	if s.userRepo == nil {
+		return 0, fmt.Errorf("user repository not configured")
+	}
+
+	grants, err := s.grantRepo.ClaimDue("discord", time.Now().Add(-s.config.DiscordSyncInterval), discordSyncBatch)
+	if err != nil {
+		return 0, err
+	}
+	for i := range grants {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return i, ctx.Err()
+			case <-time.After(discordSyncPause):
+			}
+		}
+		g := &grants[i]
+		if err := s.syncDiscordGrant(ctx, g); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"user_id":     g.UserID,
+				"identity_id": g.IdentityID,
+			}).Warn("Failed to sync Discord profile")
+		}
+	}
+	return len(grants), nil
+}
+
+func (s *AuthService) syncDiscordGrant(ctx context.Context, g *models.ProviderGrant) error {
+	token, err := s.openGrant(g)
+	if err != nil {
+		return err
+	}
+	if time.Until(g.ExpiresAt) < grantRefreshMargin {
+		refreshed, err := s.discord.Refresh(ctx, token.RefreshToken)
+		if err != nil {
+			return s.grantFailed(g, err)
+		}
+		if refreshed.RefreshToken == "" {
+			refreshed.RefreshToken = token.RefreshToken
+		}
+		if err := s.saveGrant(g.IdentityID, refreshed); err != nil {
+			return err
+		}
+		token = refreshed
+	} else if s.secretCipher.NeedsRewrap(g.AccessToken) || s.secretCipher.NeedsRewrap(g.RefreshToken) {
+		// После смены TOKEN_ENCRYPTION_KEY перешифровываем текущим ключом
+		token.ExpiresIn = int(time.Until(g.ExpiresAt).Seconds())
+		if err := s.saveGrant(g.IdentityID, token); err != nil {
+			return err
+		}
+	}
+
+	identity, err := s.discord.FetchIdentity(ctx, token)
+	if err != nil {
+		return s.grantFailed(g, err)
+	}
+	if identity.Subject != g.Subject {
+		return fmt.Errorf("discord token of identity %d belongs to %s", g.IdentityID, identity.Subject)
+	}
+	user, err := s.userRepo.UpsertByIdentity(identity.Provider, identity.Subject, identity.Username, identity.Discriminator, identity.Email, identity.AvatarURL)
+	if err != nil {
+		return err
+	}
+
+	// Токены, выданные до включения DISCORD_GUILD_IDS, не видят серверов: тогда проверяет бот
+	guildToken := token
+	if !containsString(strings.Fields(token.Scope), "guilds.members.read") {
+		guildToken = nil
+	}
+	err = s.SyncGuildMembership(ctx, user, guildToken)
+	if errors.Is(err, ErrGuildMembershipRequired) {
+		// Ушел с сервера: /refresh его бы уже не пустил, завершаем сессии сразу
+		return s.RevokeAllSessions(user.ID)
+	}
+	return err
+}
+
+// grantFailed отмечает отзыв доступа, если ошибка означает именно его
+func (s *AuthService) grantFailed(g *models.ProviderGrant, err error) error {
+	if !providers.IsGrantRevoked(err) {
+		return err
+	}
+	if err := s.grantRepo.MarkRevoked(g.IdentityID); err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"user_id":     g.UserID,
+		"identity_id": g.IdentityID,
+	}).Info("Discord grant was revoked by the user")
+	return nil
+}
+
+func (s *AuthService) saveGrant(identityID uint, token *providers.Token) error {
+	access, err := s.secretCipher.Encrypt([]byte(token.AccessToken), grantAD(identityID, "access"))
+	if err != nil {
+		return err
+	}
+	refresh, err := s.secretCipher.Encrypt([]byte(token.RefreshToken), grantAD(identityID, "refresh"))
+	if err != nil {
+		return err
+	}
+	return s.grantRepo.Save(&models.ProviderGrant{
+		IdentityID:   identityID,
+		AccessToken:  access,
+		RefreshToken: refresh,
+		Scope:        token.Scope,
+		ExpiresAt:    time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	})
+}
+
+func (s *AuthService) openGrant(g *models.ProviderGrant) (*providers.Token, error) {
+	access, err := s.secretCipher.Decrypt(g.AccessToken, grantAD(g.IdentityID, "access"))
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.secretCipher.Decrypt(g.RefreshToken, grantAD(g.IdentityID, "refresh"))
+	if err != nil {
+		return nil, err
+	}
+	return &providers.Token{
+		AccessToken:  string(access),
+		RefreshToken: string(refresh),
+		TokenType:    "Bearer",
+		Scope:        g.Scope,
+	}, nil
+}
+
+// grantAD привязывает шифротекст к учетной записи и полю
+func grantAD(identityID uint, field string) []byte {
+	return []byte("provider_grants:" + strconv.FormatUint(uint64(identityID), 10) + ":" + field)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"user-service/internal/models"
+	"user-service/internal/providers"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrGuildMembershipRequired = errors.New("you must be a member of the required Discord server to log in")
+
+// GuildDirectory — членство пользователя на серверах Discord
+type GuildDirectory interface {
+	CanCheckWithoutUser() bool
+	Member(ctx context.Context, token *providers.Token, guildID string) (*providers.DiscordGuildMember, error)
+	MemberByID(ctx context.Context, guildID, discordUserID string) (*providers.DiscordGuildMember, error)
+}
+
+func (s *AuthService) WithGuildDirectory(guilds GuildDirectory) *AuthService {
+	s.guilds = guilds
+	return s
+}
+
+// SyncGuildMembership проверяет, что пользователь состоит хотя бы на одном из DISCORD_GUILD_IDS,
+// и выставляет роль по DISCORD_GUILD_ROLES. token — Discord-токен только что вошедшего
+// пользователя; без него проверка идет токеном бота, а если проверить нечем — вход запрещается.
+func (s *AuthService) SyncGuildMembership(ctx context.Context, user *models.User, token *providers.Token) error {
+	roles, err := s.guildRoles(ctx, user.DiscordID, token)
+	if err != nil {
+		return err
+	}
+	s.SyncGuildRole(user, roles)
+	return nil
+}
+
+// CheckIdentityGuildMembership — проверка членства при входе через провайдера до того, как
+// пользователь будет создан или обновлен: не состоящий на сервере не получает ни записи
+// в users, ни повышения по ADMIN_DISCORD_IDS. Возвращает роли на серверах для SyncGuildRole.
+// Для входа не через Discord берется Discord уже существующего пользователя с этой учетной записью.
+func (s *AuthService) CheckIdentityGuildMembership(ctx context.Context, identity *providers.Identity, token *providers.Token, client models.ClientInfo) ([]string, error) {
+	if len(s.config.DiscordGuildIDs) == 0 || s.guilds == nil {
+		return nil, nil
+	}
+	var user *models.User
+	if s.identityRepo != nil {
+		linked, err := s.identityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+		if err != nil {
+			return nil, err
+		}
+		if linked != nil {
+			if user, err = s.findUser(linked.UserID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	discordID := ""
+	if identity.Provider == "discord" {
+		discordID = identity.Subject
+	} else {
+		token = nil
+		if user != nil {
+			discordID = user.DiscordID
+		}
+	}
+	roles, err := s.guildRoles(ctx, discordID, token)
+	// Неудачный вход попадает в историю только у того, кто уже есть в users
+	if errors.Is(err, ErrGuildMembershipRequired) && user != nil {
+		s.recordLogin(user.ID, models.LoginKindLogin, LoginFailureGuildMembership, client)
+	}
+	return roles, err
+}
+
+// guildRoles возвращает роли пользователя на серверах DISCORD_GUILD_IDS или
+// ErrGuildMembershipRequired, если он не состоит ни на одном
+func (s *AuthService) guildRoles(ctx context.Context, discordID string, token *providers.Token) ([]string, error) {
+	if len(s.config.DiscordGuildIDs) == 0 || s.guilds == nil {
+		return nil, nil
+	}
+	if discordID == "" {
+		return nil, ErrGuildMembershipRequired
+	}
+	if token == nil && !s.guilds.CanCheckWithoutUser() {
+		s.logger.WithField("discord_id", discordID).Error("Cannot check Discord guild membership: DISCORD_BOT_TOKEN is not set")
+		return nil, ErrGuildMembershipRequired
+	}
+
+	var roles []string
+	member := false
+	for _, guildID := range s.config.DiscordGuildIDs {
+		var m *providers.DiscordGuildMember
+		var err error
+		if token != nil {
+			m, err = s.guilds.Member(ctx, token, guildID)
+		} else {
+			m, err = s.guilds.MemberByID(ctx, guildID, discordID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("check discord guild %s: %w", guildID, err)
+		}
+		if m != nil {
+			member = true
+			roles = append(roles, m.Roles...)
+		}
+	}
+	if !member {
+		s.logger.WithField("discord_id", discordID).Warn("User is not a member of the required Discord guilds")
+		return nil, ErrGuildMembershipRequired
+	}
+	return roles, nil
+}
+
+// checkGuildMembership — SyncGuildMembership без пользователя (refresh, вход по passkey).
+// Если Discord недоступен, вход не блокируется: иначе его сбой выкинул бы всех.
+func (s *AuthService) checkGuildMembership(user *models.User) error {
+	err := s.SyncGuildMembership(context.Background(), user, nil)
+	if err != nil && !errors.Is(err, ErrGuildMembershipRequired) {
+		s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to recheck Discord guild membership")
+		return nil
+	}
+	return err
+}
+
+// SyncGuildRole назначает роль по первой подходящей строке DISCORD_GUILD_ROLES. Роли, которых
+// нет в сопоставлении, назначаются вручную и не трогаются; роль из сопоставления без
+// соответствующей роли на сервере снимается до DefaultRole. ADMIN_DISCORD_IDS важнее.
+func (s *AuthService) SyncGuildRole(user *models.User, discordRoles []string) {
+	if len(s.config.DiscordGuildIDs) == 0 || s.guilds == nil || len(s.config.DiscordGuildRoles) == 0 || containsString(s.config.AdminDiscordIDs, user.DiscordID) {
+		return
+	}
+
+	desired := ""
+	managed := false
+	for _, mapping := range s.config.DiscordGuildRoles {
+		managed = managed || mapping.Role == user.Role
+		if desired == "" && containsString(discordRoles, mapping.DiscordRoleID) {
+			desired = mapping.Role
+		}
+	}
+	if desired == "" {
+		if !managed {
+			return
+		}
+		desired = DefaultRole
+	}
+	if desired == user.Role {
+		return
+	}
+	if s.roles != nil && !s.roles.Exists(desired) {
+		s.logger.WithField("role", desired).Error("DISCORD_GUILD_ROLES refers to an unknown role")
+		return
+	}
+
+	if err := s.userRepo.UpdateRole(user.ID, desired); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to sync role from Discord guild")
+		return
+	}
+	s.logger.WithFields(logrus.Fields{
+		"user_id": user.ID,
+		"from":    user.Role,
+		"to":      desired,
+	}).Info("Role synced from Discord guild")
+	s.audit.Record(AuditRoleSynced, AuditSystemActor, UserActor(user.ID),
+		map[string]string{"role": user.Role}, map[string]string{"role": desired}, models.ClientInfo{})
+	user.Role = desired
+}
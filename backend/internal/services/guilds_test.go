@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/models"
+	"user-service/internal/providers"
+
+	"github.com/sirupsen/logrus"
+)
+
+// memoryGuilds — участники сервера по Discord ID; проверка идет токеном бота
+type memoryGuilds struct {
+	members map[string][]string
+}
+
+func (g *memoryGuilds) CanCheckWithoutUser() bool { return true }
+
+func (g *memoryGuilds) Member(ctx context.Context, token *providers.Token, guildID string) (*providers.DiscordGuildMember, error) {
+	return g.MemberByID(ctx, guildID, token.AccessToken)
+}
+
+func (g *memoryGuilds) MemberByID(ctx context.Context, guildID, discordUserID string) (*providers.DiscordGuildMember, error) {
+	if roles, ok := g.members[discordUserID]; ok {
+		return &providers.DiscordGuildMember{Roles: roles}, nil
+	}
+	return nil, nil
+}
+
+type memoryIdentityRepo struct {
+	IdentityRepository
+	identities []models.UserIdentity
+}
+
+func (r *memoryIdentityRepo) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	for i := range r.identities {
+		if r.identities[i].Provider == provider && r.identities[i].Subject == subject {
+			return &r.identities[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// recordingLoginRepo запоминает записанные события истории входов
+type recordingLoginRepo struct {
+	LoginEventRepository
+	events []models.LoginEvent
+}
+
+func (r *recordingLoginRepo) Create(e *models.LoginEvent) error {
+	r.events = append(r.events, *e)
+	return nil
+}
+
+func (r *recordingLoginRepo) DeleteBefore(userID uint, before time.Time) error { return nil }
+
+func TestCheckIdentityGuildMembership(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	guilds := &memoryGuilds{members: map[string][]string{"member": {"role-1"}}}
+	users := &memoryUserRepo{users: map[uint]*models.User{
+		1: {ID: 1, DiscordID: "member"},
+		2: {ID: 2, DiscordID: "former"},
+	}}
+	identities := &memoryIdentityRepo{identities: []models.UserIdentity{
+		{UserID: 1, Provider: "github", Subject: "gh-member"},
+		{UserID: 2, Provider: "discord", Subject: "former"},
+	}}
+
+	tests := []struct {
+		name     string
+		identity providers.Identity
+		token    *providers.Token
+		roles    []string
+		wantErr  error
+		recorded []uint
+	}{
+		{"new discord member", providers.Identity{Provider: "discord", Subject: "member"}, &providers.Token{AccessToken: "member"}, []string{"role-1"}, nil, nil},
+		{"new discord outsider", providers.Identity{Provider: "discord", Subject: "stranger"}, &providers.Token{AccessToken: "stranger"}, nil, ErrGuildMembershipRequired, nil},
+		{"existing user left guild", providers.Identity{Provider: "discord", Subject: "former"}, &providers.Token{AccessToken: "former"}, nil, ErrGuildMembershipRequired, []uint{2}},
+		{"github login of member", providers.Identity{Provider: "github", Subject: "gh-member"}, nil, []string{"role-1"}, nil, nil},
+		{"new github user", providers.Identity{Provider: "github", Subject: "gh-new"}, nil, nil, ErrGuildMembershipRequired, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := &recordingLoginRepo{}
+			s := NewAuthService(&config.Config{DiscordGuildIDs: []string{"guild"}}, logger).
+				WithRepositories(users, nil).
+				WithIdentityRepository(identities).
+				WithGuildDirectory(guilds).
+				WithLoginHistory(history, nil)
+			roles, err := s.CheckIdentityGuildMembership(context.Background(), &tt.identity, tt.token, models.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(roles) != len(tt.roles) || (len(roles) > 0 && roles[0] != tt.roles[0]) {
+				t.Errorf("roles = %v, want %v", roles, tt.roles)
+			}
+			var recorded []uint
+			for _, e := range history.events {
+				recorded = append(recorded, e.UserID)
+			}
+			if len(recorded) != len(tt.recorded) || (len(recorded) > 0 && recorded[0] != tt.recorded[0]) {
+				t.Errorf("login failures recorded for %v, want %v", recorded, tt.recorded)
+			}
+		})
+	}
+
+	t.Run("guilds not configured", func(t *testing.T) {
+		s := NewAuthService(&config.Config{}, logger).WithGuildDirectory(guilds)
+		if _, err := s.CheckIdentityGuildMembership(context.Background(), &providers.Identity{Provider: "github", Subject: "x"}, nil, models.ClientInfo{}); err != nil {
+			t.Errorf("err = %v", err)
+		}
+	})
+}
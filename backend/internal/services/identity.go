@@ -0,0 +1,99 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"user-service/internal/database"
+	"user-service/internal/models"
+	"user-service/internal/providers"
+)
+
+var (
+	ErrIdentityTaken    = errors.New("identity is already linked to another user")
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrLastIdentity     = database.ErrLastIdentity
+)
+
+type IdentityRepository interface {
+	FindByUserID(userID uint) ([]models.UserIdentity, error)
+	FindByProviderSubject(provider, subject string) (*models.UserIdentity, error)
+	Create(identity *models.UserIdentity) error
+	UpdateProfile(identity *models.UserIdentity) error
+	Delete(userID, identityID uint) error
+}
+
+func (s *AuthService) WithIdentityRepository(identityRepo IdentityRepository) *AuthService {
+	s.identityRepo = identityRepo
+	return s
+}
+
+func (s *AuthService) GetUserIdentities(userID uint) ([]models.UserIdentity, error) {
+	if s.identityRepo == nil {
+		return nil, fmt.Errorf("identity repository not configured")
+	}
+	identities, err := s.identityRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if identities == nil {
+		identities = []models.UserIdentity{}
+	}
+	return identities, nil
+}
+
+// LinkIdentity привязывает учетную запись провайдера к уже существующему пользователю
+func (s *AuthService) LinkIdentity(userID uint, identity *providers.Identity) (*models.UserIdentity, error) {
+	if s.identityRepo == nil {
+		return nil, fmt.Errorf("identity repository not configured")
+	}
+
+	existing, err := s.identityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.UserID != userID {
+			s.logger.Warnf("User %d tried to link %s identity %s owned by user %d", userID, identity.Provider, identity.Subject, existing.UserID)
+			return nil, ErrIdentityTaken
+		}
+		existing.Username = identity.Username
+		existing.Email = identity.Email
+		existing.Avatar = identity.AvatarURL
+		if err := s.identityRepo.UpdateProfile(existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	linked := &models.UserIdentity{
+		UserID:   userID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Username: identity.Username,
+		Email:    identity.Email,
+		Avatar:   identity.AvatarURL,
+	}
+	if err := s.identityRepo.Create(linked); err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("Linked %s identity %s to user %d", identity.Provider, identity.Subject, userID)
+	return linked, nil
+}
+
+func (s *AuthService) UnlinkIdentity(userID, identityID uint) error {
+	if s.identityRepo == nil {
+		return fmt.Errorf("identity repository not configured")
+	}
+	err := s.identityRepo.Delete(userID, identityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrIdentityNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.logger.Infof("Unlinked identity %d from user %d", identityID, userID)
+	return nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const impersonationTTL = 10 * time.Minute
+
+// ImpersonationScopes — что можно делать от имени пользователя: смотреть профиль и
+// персонажей так, как их видит он. Управление аккаунтом и админка недоступны.
+var ImpersonationScopes = []string{ScopeProfile, ScopeCharactersRead, ScopeCharactersWrite}
+
+var (
+	ErrImpersonationNotFound = errors.New("impersonation not found")
+	ErrInvalidImpersonation  = errors.New("invalid impersonation request")
+)
+
+type ImpersonationRepository interface {
+	Create(imp *models.Impersonation) error
+	Find(id string) (*models.Impersonation, error)
+	FindRecent(limit int) ([]models.Impersonation, error)
+	End(id string) (bool, error)
+	AddRequest(impersonationID string, req *models.ImpersonationRequest) error
+	FindRequests(impersonationID string) ([]models.ImpersonationRequest, error)
+}
+
+func (s *AuthService) WithImpersonationRepository(impersonationRepo ImpersonationRepository) *AuthService {
+	s.impersonationRepo = impersonationRepo
+	return s
+}
+
+// Impersonate выдает администратору короткий access token от имени пользователя.
+// В токене claim act (RFC 8693) с ID администратора, refresh token не выдается.
+// Войти можно только под пользователем, у роли которого нет прав сверх прав администратора.
+func (s *AuthService) Impersonate(actorID uint, actorPermissions []string, userID uint, reason, ip string) (string, *models.Impersonation, error) {
+	if s.impersonationRepo == nil || s.revocations == nil {
+		return "", nil, fmt.Errorf("impersonation repository not configured")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > 500 {
+		return "", nil, fmt.Errorf("%w: reason must be 1-500 characters", ErrInvalidImpersonation)
+	}
+	if actorID == userID {
+		return "", nil, fmt.Errorf("%w: cannot impersonate yourself", ErrInvalidImpersonation)
+	}
+
+	user, err := s.findUser(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if user == nil {
+		return "", nil, ErrUserNotFound
+	}
+	if err := s.checkCanManage(actorPermissions, user); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	imp := &models.Impersonation{
+		ID:        uuid.New().String(),
+		ActorID:   actorID,
+		UserID:    user.ID,
+		Reason:    reason,
+		IP:        ip,
+		ExpiresAt: now.Add(impersonationTTL),
+	}
+	if err := s.impersonationRepo.Create(imp); err != nil {
+		return "", nil, err
+	}
+
+	scopes := []string{}
+	for _, sc := range s.ScopesForRole(user.Role) {
+		if containsString(ImpersonationScopes, sc) {
+			scopes = append(scopes, sc)
+		}
+	}
+	// jti совпадает с ID impersonation: завершение — это отзыв токена
+	token, err := s.keyring.Sign(jwt.MapClaims{
+		"sub":   Subject(user.ID),
+		"role":  user.Role,
+		"scope": strings.Join(scopes, " "),
+		"act":   map[string]interface{}{"sub": Subject(actorID)},
+		"jti":   imp.ID,
+		"exp":   imp.ExpiresAt.Unix(),
+		"iat":   now.Unix(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"actor_id":         actorID,
+		"user_id":          user.ID,
+		"impersonation_id": imp.ID,
+		"reason":           reason,
+	}).Warn("Admin started impersonating user")
+	return token, imp, nil
+}
+
+// EndImpersonation отзывает токен impersonation до истечения срока
+func (s *AuthService) EndImpersonation(id string) error {
+	if s.impersonationRepo == nil || s.revocations == nil {
+		return fmt.Errorf("impersonation repository not configured")
+	}
+	imp, err := s.impersonationRepo.Find(id)
+	if err != nil {
+		return err
+	}
+	if imp == nil {
+		return ErrImpersonationNotFound
+	}
+	if err := s.revocations.Revoke(imp.ID, imp.ExpiresAt); err != nil {
+		return err
+	}
+	if _, err := s.impersonationRepo.End(imp.ID); err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"actor_id":         imp.ActorID,
+		"user_id":          imp.UserID,
+		"impersonation_id": imp.ID,
+	}).Info("Impersonation ended")
+	return nil
+}
+
+// RecordImpersonatedRequest пишет в журнал запрос, сделанный от имени пользователя
+func (s *AuthService) RecordImpersonatedRequest(impersonationID, method, path string, status int, ip string) error {
+	if s.impersonationRepo == nil {
+		return fmt.Errorf("impersonation repository not configured")
+	}
+	return s.impersonationRepo.AddRequest(impersonationID, &models.ImpersonationRequest{
+		Method: method,
+		Path:   path,
+		Status: status,
+		IP:     ip,
+	})
+}
+
+func (s *AuthService) ListImpersonations() ([]models.Impersonation, error) {
+	if s.impersonationRepo == nil {
+		return nil, fmt.Errorf("impersonation repository not configured")
+	}
+	result, err := s.impersonationRepo.FindRecent(200)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = []models.Impersonation{}
+	}
+	return result, nil
+}
+
+func (s *AuthService) GetImpersonationRequests(id string) ([]models.ImpersonationRequest, error) {
+	if s.impersonationRepo == nil {
+		return nil, fmt.Errorf("impersonation repository not configured")
+	}
+	imp, err := s.impersonationRepo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if imp == nil {
+		return nil, ErrImpersonationNotFound
+	}
+	requests, err := s.impersonationRepo.FindRequests(id)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []models.ImpersonationRequest{}
+	}
+	return requests, nil
+}
+
+// Impersonator — кто вошел от имени пользователя, для флага в /me
+func (s *AuthService) Impersonator(actorID uint) (*models.Impersonator, error) {
+	actor, err := s.findUser(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil {
+		return &models.Impersonator{ID: actorID}, nil
+	}
+	return &models.Impersonator{ID: actor.ID, Username: actor.Username}, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+)
+
+// IntrospectToken проверяет токен по текущему состоянию БД (RFC 7662): отозванная
+// сессия, удаленный или заблокированный пользователь делают токен неактивным, роль берется из БД.
+func (s *AuthService) IntrospectToken(token, tokenTypeHint string) (*models.TokenIntrospection, error) {
+	if s.tokenRepo == nil || s.userRepo == nil || s.clientRepo == nil {
+		return nil, fmt.Errorf("token repository not configured")
+	}
+	if token == "" {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+
+	// Access token — JWT, refresh token — случайная hex-строка; подсказку
+	// token_type_hint можно не учитывать (RFC 7662, раздел 2.1)
+	if strings.Count(token, ".") == 2 {
+		return s.introspectAccessToken(token)
+	}
+	return s.introspectRefreshToken(token)
+}
+
+func (s *AuthService) introspectAccessToken(token string) (*models.TokenIntrospection, error) {
+	claims, err := s.keyring.Parse(token)
+	if err != nil {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+	// ID token — не access token
+	if _, isIDToken := claims["aud"]; isIDToken {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+
+	if jti, _ := claims["jti"].(string); jti != "" && s.revocations != nil && s.revocations.IsRevoked(jti) {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+
+	result := &models.TokenIntrospection{Active: true, TokenType: "access_token"}
+	result.Scope, _ = claims["scope"].(string)
+
+	if clientID, ok := claims["client_id"].(string); ok {
+		client, err := s.clientRepo.FindByID(clientID)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil || client.Disabled() {
+			return &models.TokenIntrospection{Active: false}, nil
+		}
+		result.ClientID = clientID
+	}
+
+	// У токена client_credentials нет пользователя
+	if sub, ok := SubjectUserID(claims); ok {
+		user, err := s.findUser(sub)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || s.CheckBan(user.ID) != nil {
+			return &models.TokenIntrospection{Active: false}, nil
+		}
+		result.Sub = Subject(user.ID)
+		// Сторонним приложениям роль не выдается, как и в самом токене; у сессии устройства она есть
+		if _, isSession := claims["sid"]; result.ClientID == "" || isSession {
+			result.Role = user.Role
+		}
+	} else if result.ClientID == "" {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+
+	if sessionID, ok := claims["sid"].(string); ok {
+		active, err := s.tokenRepo.SessionActive(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return &models.TokenIntrospection{Active: false}, nil
+		}
+		result.SessionID = sessionID
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.Exp = exp.Unix()
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		result.Iat = iat.Unix()
+	}
+	result.Iss, _ = claims["iss"].(string)
+	result.Act, _ = claims["act"].(map[string]interface{})
+	return result, nil
+}
+
+func (s *AuthService) introspectRefreshToken(token string) (*models.TokenIntrospection, error) {
+	rt, err := s.tokenRepo.FindByHash(hashSecret(token))
+	if err != nil || rt == nil {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+	if rt.RevokedAt != nil || rt.RotatedAt != nil || rt.ExpiresAt.Before(time.Now()) {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+	user, err := s.findUser(rt.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || s.CheckBan(user.ID) != nil {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+	if rt.ClientID != "" {
+		client, err := s.clientRepo.FindByID(rt.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil || client.Disabled() {
+			return &models.TokenIntrospection{Active: false}, nil
+		}
+	}
+	return &models.TokenIntrospection{
+		Active:    true,
+		TokenType: "refresh_token",
+		Sub:       Subject(user.ID),
+		Role:      user.Role,
+		ClientID:  rt.ClientID,
+		// Те scope, которые получит access token при обмене этого refresh token
+		Scope:     strings.Join(s.grantedScopes(user.Role, strings.Fields(rt.Scope)), " "),
+		SessionID: rt.FamilyID,
+		Exp:       rt.ExpiresAt.Unix(),
+		Iat:       rt.CreatedAt.Unix(),
+	}, nil
+}
+
+// findUser возвращает nil, если пользователя больше нет
+func (s *AuthService) findUser(id uint) (*models.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/keys"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// memoryRefreshTokenRepo — сохраненные refresh token; все сессии активны
+type memoryRefreshTokenRepo struct {
+	RefreshTokenRepository
+	tokens []*models.RefreshToken
+}
+
+func (r *memoryRefreshTokenRepo) Save(rt *models.RefreshToken) error {
+	rt.CreatedAt = time.Now()
+	r.tokens = append(r.tokens, rt)
+	return nil
+}
+
+func (r *memoryRefreshTokenRepo) FindByHash(tokenHash string) (*models.RefreshToken, error) {
+	for _, rt := range r.tokens {
+		if rt.TokenHash == tokenHash {
+			return rt, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRefreshTokenRepo) Rotate(old, next *models.RefreshToken) error {
+	now := time.Now()
+	old.RotatedAt = &now
+	r.tokens = append(r.tokens, next)
+	return nil
+}
+
+func (r *memoryRefreshTokenRepo) SessionActive(familyID string) (bool, error) { return true, nil }
+
+func TestIntrospectFirstPartyToken(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	tokens := &memoryRefreshTokenRepo{}
+	users := &memoryUserRepo{users: map[uint]*models.User{7: {ID: 7, Role: "user"}}}
+	s := NewAuthService(&config.Config{}, logger).
+		WithKeyring(keys.NewKeyring(keys.NewHMACKey("test", []byte("test-secret-test-secret-test-secret")))).
+		WithRepositories(users, tokens).
+		WithOAuthRepositories(&memoryClientRepo{}, nil)
+
+	accessToken, refreshToken, err := s.GenerateTokens(users.users[7], []string{ScopeProfile, ScopeCharactersRead}, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("GenerateTokens: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType string
+	}{
+		{"access token", accessToken, "access_token"},
+		{"refresh token", refreshToken, "refresh_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.IntrospectToken(tt.token, "")
+			if err != nil {
+				t.Fatalf("IntrospectToken: %v", err)
+			}
+			if !result.Active || result.TokenType != tt.tokenType {
+				t.Fatalf("active = %v, token_type = %q", result.Active, result.TokenType)
+			}
+			if result.Sub != "7" || result.Role != "user" || result.ClientID != "" {
+				t.Errorf("sub = %q, role = %q, client_id = %q", result.Sub, result.Role, result.ClientID)
+			}
+			if result.Scope != "profile characters:read" {
+				t.Errorf("scope = %q, want %q", result.Scope, "profile characters:read")
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/keys"
+	"user-service/internal/models"
+	"user-service/internal/secrets"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrSigningKeyNotFound = errors.New("signing key not found or has wrong status")
+
+type SigningKeyRepository interface {
+	FindAll() ([]models.SigningKey, error)
+	Create(k *models.SigningKey) error
+	UpdatePrivateKey(kid, privateKey string) error
+	Promote(kid string, retireAfter time.Time) error
+	Retire(kid string) error
+	RetireExpired() ([]string, error)
+	AddEvent(e *models.SigningKeyEvent) error
+	FindEvents(limit int) ([]models.SigningKeyEvent, error)
+}
+
+// KeyRotationService управляет ключами подписи в БД: новый ключ сначала публикуется
+// (pending), затем становится активным, а прежний принимается еще grace period.
+type KeyRotationService struct {
+	repo      SigningKeyRepository
+	keyring   *keys.Keyring
+	bootstrap *keys.Key
+	grace     time.Duration
+	cipher    *secrets.Cipher
+	logger    *logrus.Logger
+}
+
+// NewKeyRotationService — keyring может быть nil (например, в CLI), тогда ключи
+// только меняются в БД, а серверы подхватят их при следующей перезагрузке.
+// Ключ из конфигурации подписывает токены, пока в БД нет активного ключа.
+func NewKeyRotationService(repo SigningKeyRepository, keyring *keys.Keyring, grace time.Duration, logger *logrus.Logger) *KeyRotationService {
+	s := &KeyRotationService{
+		repo:    repo,
+		keyring: keyring,
+		grace:   grace,
+		logger:  logger,
+	}
+	if keyring != nil {
+		s.bootstrap = keyring.SigningKey()
+	}
+	return s
+}
+
+// WithCipher включает шифрование приватных ключей в БД (TOKEN_ENCRYPTION_KEY). Ключи,
+// сохраненные открытыми или прежним мастер-ключом, перешифровываются при следующем Reload.
+func (s *KeyRotationService) WithCipher(c *secrets.Cipher) *KeyRotationService {
+	s.cipher = c
+	return s
+}
+
+func (s *KeyRotationService) ListKeys() ([]models.SigningKey, error) {
+	list, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []models.SigningKey{}
+	}
+	return list, nil
+}
+
+func (s *KeyRotationService) ListEvents(limit int) ([]models.SigningKeyEvent, error) {
+	events, err := s.repo.FindEvents(limit)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []models.SigningKeyEvent{}
+	}
+	return events, nil
+}
+
+// GenerateKey создает ключ в статусе pending: он сразу попадает в JWKS, но еще не подписывает
+func (s *KeyRotationService) GenerateKey(algorithm, actor string) (*models.SigningKey, error) {
+	key, err := keys.Generate(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	material, err := key.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.sealPrivateKey(key.ID, material)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &models.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: sealed,
+		Status:     models.SigningKeyPending,
+	}
+	if err := s.repo.Create(stored); err != nil {
+		return nil, err
+	}
+
+	s.logEvent(stored.ID, "generated", actor, "algorithm="+algorithm)
+	s.reloadAfterChange()
+	return stored, nil
+}
+
+// PromoteKey делает ключ текущим ключом подписи
+func (s *KeyRotationService) PromoteKey(kid, actor string) error {
+	retireAfter := time.Now().Add(s.grace)
+	if err := s.repo.Promote(kid, retireAfter); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSigningKeyNotFound
+		}
+		return err
+	}
+
+	s.logEvent(kid, "promoted", actor, "previous key retires after "+retireAfter.UTC().Format(time.RFC3339))
+	s.reloadAfterChange()
+	return nil
+}
+
+// RetireKey сразу перестает принимать токены, подписанные ключом
+func (s *KeyRotationService) RetireKey(kid, actor string) error {
+	if err := s.repo.Retire(kid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSigningKeyNotFound
+		}
+		return err
+	}
+
+	s.logEvent(kid, "retired", actor, "")
+	s.reloadAfterChange()
+	return nil
+}
+
+// Reload выводит из использования ключи с истекшим grace period и пересобирает keyring
+func (s *KeyRotationService) Reload() error {
+	if s.keyring == nil {
+		return fmt.Errorf("keyring not configured")
+	}
+
+	retired, err := s.repo.RetireExpired()
+	if err != nil {
+		return err
+	}
+	for _, kid := range retired {
+		s.logEvent(kid, "retired", "system", "grace period ended")
+	}
+
+	stored, err := s.repo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	var signing *keys.Key
+	var verification []*keys.Key
+	// Первая ротация считается и по выведенным ключам: activated_at у них сохраняется,
+	// иначе после их вывода ключ из конфигурации снова начал бы приниматься
+	var firstActivation *time.Time
+	for _, sk := range stored {
+		if sk.ActivatedAt != nil && (firstActivation == nil || sk.ActivatedAt.Before(*firstActivation)) {
+			firstActivation = sk.ActivatedAt
+		}
+		material, err := s.privateKey(sk)
+		if err != nil {
+			s.logger.WithError(err).WithField("kid", sk.ID).Error("Failed to decrypt signing key")
+			continue
+		}
+		s.rewrapPrivateKey(sk, material)
+		if sk.Status == models.SigningKeyRetired {
+			continue
+		}
+		key, err := keys.Unmarshal(sk.ID, sk.Algorithm, material)
+		if err != nil {
+			s.logger.WithError(err).WithField("kid", sk.ID).Error("Failed to load signing key")
+			continue
+		}
+		if sk.Status == models.SigningKeyActive {
+			signing = key
+		} else {
+			verification = append(verification, key)
+		}
+	}
+
+	switch {
+	case signing == nil:
+		signing = s.bootstrap
+	case firstActivation != nil && time.Since(*firstActivation) < s.grace:
+		// Токены, подписанные ключом из конфигурации до первой ротации, еще действуют
+		verification = append(verification, s.bootstrap)
+	}
+
+	s.keyring.Replace(signing, verification)
+	return nil
+}
+
+// Run периодически перезагружает ключи, чтобы все инстансы увидели ротацию
+func (s *KeyRotationService) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				s.logger.WithError(err).Error("Failed to reload signing keys")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *KeyRotationService) reloadAfterChange() {
+	if s.keyring == nil {
+		return
+	}
+	if err := s.Reload(); err != nil {
+		s.logger.WithError(err).Error("Failed to reload signing keys")
+	}
+}
+
+func (s *KeyRotationService) sealPrivateKey(kid, material string) (string, error) {
+	if s.cipher == nil {
+		return material, nil
+	}
+	return s.cipher.EncryptString(material, signingKeyAD(kid))
+}
+
+// privateKey расшифровывает ключ; сохраненный до включения TOKEN_ENCRYPTION_KEY хранится открыто
+func (s *KeyRotationService) privateKey(sk models.SigningKey) (string, error) {
+	if !secrets.IsEncrypted(sk.PrivateKey) {
+		return sk.PrivateKey, nil
+	}
+	if s.cipher == nil {
+		return "", fmt.Errorf("signing key is encrypted, but TOKEN_ENCRYPTION_KEY is not set")
+	}
+	return s.cipher.DecryptString(sk.PrivateKey, signingKeyAD(sk.ID))
+}
+
+// rewrapPrivateKey шифрует открытый ключ или ключ, зашифрованный прежним мастер-ключом
+func (s *KeyRotationService) rewrapPrivateKey(sk models.SigningKey, material string) {
+	if s.cipher == nil || !s.cipher.NeedsRewrapString(sk.PrivateKey) {
+		return
+	}
+	sealed, err := s.sealPrivateKey(sk.ID, material)
+	if err == nil {
+		err = s.repo.UpdatePrivateKey(sk.ID, sealed)
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("kid", sk.ID).Warn("Failed to encrypt signing key")
+	}
+}
+
+func signingKeyAD(kid string) string {
+	return "signing_keys:" + kid
+}
+
+func (s *KeyRotationService) logEvent(kid, event, actor, details string) {
+	s.logger.WithFields(logrus.Fields{
+		"kid":     kid,
+		"event":   event,
+		"actor":   actor,
+		"details": details,
+	}).Info("Signing key rotation event")
+
+	if err := s.repo.AddEvent(&models.SigningKeyEvent{KeyID: kid, Event: event, Actor: actor, Details: details}); err != nil {
+		s.logger.WithError(err).WithField("kid", kid).Error("Failed to store signing key event")
+	}
+}
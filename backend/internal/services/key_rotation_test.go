@@ -0,0 +1,174 @@
+package services
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/keys"
+	"user-service/internal/models"
+	"user-service/internal/secrets"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// memorySigningKeyRepo — ключи в памяти; продвижение ключей в этих тестах не нужно
+type memorySigningKeyRepo struct {
+	SigningKeyRepository
+	keys []models.SigningKey
+}
+
+func (r *memorySigningKeyRepo) Create(k *models.SigningKey) error {
+	k.CreatedAt = time.Now()
+	r.keys = append(r.keys, *k)
+	return nil
+}
+
+func (r *memorySigningKeyRepo) UpdatePrivateKey(kid, privateKey string) error {
+	for i := range r.keys {
+		if r.keys[i].ID == kid {
+			r.keys[i].PrivateKey = privateKey
+		}
+	}
+	return nil
+}
+
+func (r *memorySigningKeyRepo) AddEvent(e *models.SigningKeyEvent) error { return nil }
+
+func (r *memorySigningKeyRepo) FindAll() ([]models.SigningKey, error) { return r.keys, nil }
+func (r *memorySigningKeyRepo) RetireExpired() ([]string, error)      { return nil, nil }
+
+func storedKey(t *testing.T, status string, activatedAgo time.Duration) models.SigningKey {
+	t.Helper()
+	key, err := keys.Generate(keys.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	material, err := key.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	activatedAt := time.Now().Add(-activatedAgo)
+	return models.SigningKey{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  material,
+		Status:      status,
+		ActivatedAt: &activatedAt,
+	}
+}
+
+func TestReloadBootstrapKeyGrace(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name     string
+		stored   func(t *testing.T) []models.SigningKey
+		accepted bool
+	}{
+		{"no keys in database", func(t *testing.T) []models.SigningKey { return nil }, true},
+		{"first rotation within grace", func(t *testing.T) []models.SigningKey {
+			return []models.SigningKey{storedKey(t, models.SigningKeyActive, 10*time.Minute)}
+		}, true},
+		{"first rotation after grace", func(t *testing.T) []models.SigningKey {
+			return []models.SigningKey{storedKey(t, models.SigningKeyActive, 2*time.Hour)}
+		}, false},
+		// Первый ключ уже выведен, текущий активирован недавно: ключ из конфигурации не возвращается
+		{"first rotated key retired", func(t *testing.T) []models.SigningKey {
+			return []models.SigningKey{
+				storedKey(t, models.SigningKeyRetired, 3*time.Hour),
+				storedKey(t, models.SigningKeyActive, 10*time.Minute),
+			}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bootstrap := keys.NewHMACKey("bootstrap", []byte("bootstrap-secret-bootstrap-secret"))
+			keyring := keys.NewKeyring(bootstrap)
+			token, err := keyring.Sign(jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Hour).Unix()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			repo := &memorySigningKeyRepo{keys: tt.stored(t)}
+			if err := NewKeyRotationService(repo, keyring, time.Hour, logger).Reload(); err != nil {
+				t.Fatalf("Reload: %v", err)
+			}
+			_, err = keyring.Parse(token)
+			if tt.accepted && err != nil {
+				t.Fatalf("bootstrap token rejected: %v", err)
+			}
+			if !tt.accepted && err == nil {
+				t.Fatal("bootstrap token accepted after grace period")
+			}
+		})
+	}
+}
+
+func TestSigningKeysEncryptedAtRest(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	oldCipher, err := secrets.NewCipher(bytes32(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := secrets.NewCipher(bytes32(2), bytes32(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ключ, сохраненный до включения шифрования, и ключ под прежним мастер-ключом
+	plain := storedKey(t, models.SigningKeyActive, time.Minute)
+	retiring := storedKey(t, models.SigningKeyRetiring, 2*time.Hour)
+	material := retiring.PrivateKey
+	if retiring.PrivateKey, err = oldCipher.EncryptString(material, signingKeyAD(retiring.ID)); err != nil {
+		t.Fatal(err)
+	}
+	repo := &memorySigningKeyRepo{keys: []models.SigningKey{plain, retiring}}
+
+	keyring := keys.NewKeyring(keys.NewHMACKey("bootstrap", []byte("bootstrap-secret-bootstrap-secret")))
+	svc := NewKeyRotationService(repo, keyring, time.Hour, logger).WithCipher(cipher)
+	if err := svc.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if keyring.SigningKey().ID != plain.ID {
+		t.Errorf("signing key = %s, want %s", keyring.SigningKey().ID, plain.ID)
+	}
+
+	generated, err := svc.GenerateKey(keys.AlgES256, "test")
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	for _, sk := range repo.keys {
+		if !secrets.IsEncrypted(sk.PrivateKey) || cipher.NeedsRewrapString(sk.PrivateKey) {
+			t.Errorf("key %s is not encrypted with the current master key", sk.ID)
+		}
+		// Шифротекст привязан к kid: под чужим kid не расшифровывается
+		if _, err := cipher.DecryptString(sk.PrivateKey, signingKeyAD(sk.ID+"x")); err == nil {
+			t.Errorf("key %s decrypted with another kid", sk.ID)
+		}
+	}
+	if _, err := keyring.Keyfunc(&jwt.Token{Header: map[string]interface{}{"kid": generated.ID}, Method: jwt.SigningMethodES256}); err != nil {
+		t.Errorf("generated key not loaded: %v", err)
+	}
+
+	// Без мастер-ключа зашифрованные ключи не загружаются, и подписывает ключ из конфигурации
+	bootstrap := keys.NewHMACKey("bootstrap", []byte("bootstrap-secret-bootstrap-secret"))
+	noCipher := keys.NewKeyring(bootstrap)
+	if err := NewKeyRotationService(repo, noCipher, time.Hour, logger).Reload(); err != nil {
+		t.Fatalf("Reload without cipher: %v", err)
+	}
+	if noCipher.SigningKey().ID != bootstrap.ID {
+		t.Errorf("signing key = %s, want bootstrap", noCipher.SigningKey().ID)
+	}
+}
+
+func bytes32(b byte) []byte {
+	key := make([]byte, secrets.KeySize)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// История входов хранится столько, дальше удаляется при следующей записи в историю пользователя
+	loginHistoryRetention = 90 * 24 * time.Hour
+	loginHistoryLimit     = 100
+	loginAlertsLimit      = 50
+	loginWebhookTimeout   = 10 * time.Second
+)
+
+// LoginAlertWebhookEvent — событие исходящего webhook о входе с нового устройства или из новой сети
+const LoginAlertWebhookEvent = "login.new_device"
+
+// Причины неудачного входа или обновления сессии в истории
+const (
+	LoginFailureBanned          = "banned"
+	LoginFailureGuildMembership = "guild_membership_required"
+	LoginFailureInvalidMFACode  = "invalid_mfa_code"
+	LoginFailureMFALocked       = "mfa_locked"
+	LoginFailureInvalidPasskey  = "invalid_passkey"
+	LoginFailureTokenRevoked    = "token_revoked"
+	LoginFailureTokenReused     = "token_reused"
+	LoginFailureTokenExpired    = "token_expired"
+	LoginFailureClientDisabled  = "client_disabled"
+)
+
+type LoginEventRepository interface {
+	Create(e *models.LoginEvent) error
+	Seen(userID uint, fingerprint, ipRange string) (known, device, network bool, err error)
+	FindByUserID(userID uint, limit int) ([]models.LoginEvent, error)
+	DeleteBefore(userID uint, before time.Time) error
+	CreateAlert(a *models.LoginAlert) error
+	FindAlerts(userID uint, limit int) ([]models.LoginAlert, error)
+	MarkAlertsRead(userID uint) error
+}
+
+// WebhookSender отправляет событие во внешний сервис
+type WebhookSender interface {
+	Send(ctx context.Context, event string, data any) error
+}
+
+// WithLoginHistory включает историю входов; webhook (LOGIN_ALERT_WEBHOOK_URL) может быть nil
+func (s *AuthService) WithLoginHistory(loginRepo LoginEventRepository, webhook WebhookSender) *AuthService {
+	s.loginRepo = loginRepo
+	s.loginWebhook = webhook
+	return s
+}
+
+// RecordLoginFailure записывает в историю вход, который не состоялся уже после того, как
+// пользователь стал известен (например, он заблокирован или не состоит на сервере Discord)
+func (s *AuthService) RecordLoginFailure(userID uint, reason string, client models.ClientInfo) {
+	s.recordLogin(userID, models.LoginKindLogin, reason, client)
+}
+
+// recordLogin пишет вход или обновление сессии в историю; failure пуст, если все прошло успешно.
+// Ошибка записи только логируется: из-за нее вход не должен сорваться
+func (s *AuthService) recordLogin(userID uint, kind, failure string, client models.ClientInfo) {
+	if s.loginRepo == nil {
+		return
+	}
+	event := &models.LoginEvent{
+		UserID:        userID,
+		Kind:          kind,
+		Success:       failure == "",
+		FailureReason: failure,
+		IP:            client.IP,
+		IPRange:       IPRange(client.IP),
+		UserAgent:     client.UserAgent,
+		Device:        DescribeDevice(client.UserAgent),
+		Fingerprint:   DeviceFingerprint(client.UserAgent),
+	}
+	log := s.logger.WithFields(logrus.Fields{"user_id": userID, "kind": kind})
+
+	var alert *models.LoginAlert
+	if event.Success && kind == models.LoginKindLogin {
+		// Сравниваем с прошлыми входами до того, как запишем этот
+		known, deviceSeen, networkSeen, err := s.loginRepo.Seen(userID, event.Fingerprint, event.IPRange)
+		if err != nil {
+			log.WithError(err).Error("Failed to check login history")
+		} else if known && (!deviceSeen || (event.IPRange != "" && !networkSeen)) {
+			alert = &models.LoginAlert{
+				UserID:     userID,
+				NewDevice:  !deviceSeen,
+				NewIPRange: event.IPRange != "" && !networkSeen,
+				IP:         event.IP,
+				UserAgent:  event.UserAgent,
+				Device:     event.Device,
+			}
+		}
+	}
+
+	if err := s.loginRepo.Create(event); err != nil {
+		log.WithError(err).Error("Failed to record login event")
+		return
+	}
+	// Чистим при каждой записи: пользователь, который только обновляет сессию
+	// (или которого перебирают неудачными входами), иначе копил бы историю бессрочно
+	if err := s.loginRepo.DeleteBefore(userID, time.Now().Add(-loginHistoryRetention)); err != nil {
+		log.WithError(err).Warn("Failed to delete old login history")
+	}
+	if alert != nil {
+		s.raiseLoginAlert(alert)
+	}
+}
+
+func (s *AuthService) raiseLoginAlert(alert *models.LoginAlert) {
+	if err := s.loginRepo.CreateAlert(alert); err != nil {
+		s.logger.WithError(err).WithField("user_id", alert.UserID).Error("Failed to create login alert")
+		return
+	}
+	s.logger.WithFields(logrus.Fields{
+		"user_id":      alert.UserID,
+		"new_device":   alert.NewDevice,
+		"new_ip_range": alert.NewIPRange,
+	}).Info("Login from a new device or network")
+
+	if s.loginWebhook == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), loginWebhookTimeout)
+		defer cancel()
+		if err := s.loginWebhook.Send(ctx, LoginAlertWebhookEvent, alert); err != nil {
+			s.logger.WithError(err).WithField("alert_id", alert.ID).Warn("Failed to send login alert webhook")
+		}
+	}()
+}
+
+// GetLoginHistory — последние входы и обновления сессий пользователя, новые первыми
+func (s *AuthService) GetLoginHistory(userID uint) ([]models.LoginEvent, error) {
+	if s.loginRepo == nil {
+		return nil, fmt.Errorf("login history repository not configured")
+	}
+	events, err := s.loginRepo.FindByUserID(userID, loginHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []models.LoginEvent{}
+	}
+	return events, nil
+}
+
+func (s *AuthService) GetLoginAlerts(userID uint) ([]models.LoginAlert, error) {
+	if s.loginRepo == nil {
+		return nil, fmt.Errorf("login history repository not configured")
+	}
+	alerts, err := s.loginRepo.FindAlerts(userID, loginAlertsLimit)
+	if err != nil {
+		return nil, err
+	}
+	if alerts == nil {
+		alerts = []models.LoginAlert{}
+	}
+	return alerts, nil
+}
+
+func (s *AuthService) MarkLoginAlertsRead(userID uint) error {
+	if s.loginRepo == nil {
+		return fmt.Errorf("login history repository not configured")
+	}
+	return s.loginRepo.MarkAlertsRead(userID)
+}
+
+// IPRange — сеть адреса: /24 для IPv4 и /48 для IPv6; пусто, если адрес не разобрать
+func IPRange(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.String()
+}
+
+var versionPattern = regexp.MustCompile(`\d+([._]\d+)*`)
+
+// DeviceFingerprint — грубый отпечаток устройства: хеш User-Agent без номеров версий,
+// чтобы обновление браузера не выглядело как новое устройство
+func DeviceFingerprint(userAgent string) string {
+	normalized := versionPattern.ReplaceAllString(strings.ToLower(strings.TrimSpace(userAgent)), "")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
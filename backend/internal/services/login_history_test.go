@@ -0,0 +1,59 @@
+package services
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pruningLoginRepo запоминает, для каких событий чистилась история
+type pruningLoginRepo struct {
+	LoginEventRepository
+	created int
+	pruned  []time.Time
+}
+
+func (r *pruningLoginRepo) Create(e *models.LoginEvent) error { r.created++; return nil }
+
+func (r *pruningLoginRepo) Seen(userID uint, fingerprint, ipRange string) (bool, bool, bool, error) {
+	return true, true, true, nil
+}
+
+func (r *pruningLoginRepo) DeleteBefore(userID uint, before time.Time) error {
+	r.pruned = append(r.pruned, before)
+	return nil
+}
+
+func TestRecordLoginPrunesHistory(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name    string
+		kind    string
+		failure string
+	}{
+		{"login", models.LoginKindLogin, ""},
+		{"refresh", models.LoginKindRefresh, ""},
+		{"failed login", models.LoginKindLogin, LoginFailureBanned},
+		{"failed refresh", models.LoginKindRefresh, LoginFailureTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &pruningLoginRepo{}
+			s := NewAuthService(&config.Config{}, logger).WithLoginHistory(repo, nil)
+			s.recordLogin(1, tt.kind, tt.failure, models.ClientInfo{IP: "10.0.0.1", UserAgent: "test"})
+			if repo.created != 1 || len(repo.pruned) != 1 {
+				t.Fatalf("created = %d, pruned = %d; want 1, 1", repo.created, len(repo.pruned))
+			}
+			if cutoff := time.Now().Add(-loginHistoryRetention); repo.pruned[0].After(cutoff) {
+				t.Errorf("pruned before %v, want at most %v", repo.pruned[0], cutoff)
+			}
+		})
+	}
+}
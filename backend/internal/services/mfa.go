@@ -0,0 +1,491 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/secrets"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mfaChallengeTTL = 5 * time.Minute
+	// После mfaMaxAttempts неверных кодов подряд ввод блокируется на mfaLockSeconds
+	mfaMaxAttempts  = 5
+	mfaLockSeconds  = 300
+	backupCodeCount = 10
+)
+
+var (
+	ErrMFAChallengeInvalid = errors.New("mfa challenge not found or expired")
+	ErrInvalidMFACode      = errors.New("invalid mfa code")
+	ErrMFALocked           = errors.New("too many invalid mfa codes, try again later")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolling     = errors.New("two-factor enrollment was not started")
+	ErrMFARequiredByRole   = errors.New("two-factor authentication is required for your role")
+)
+
+type MFARepository interface {
+	Find(userID uint) (*models.UserMFA, error)
+	SavePending(userID uint, secret string) (bool, error)
+	UpdateSecret(userID uint, secret string) error
+	Enable(userID uint, backupCodeHashes []string) error
+	ReplaceBackupCodes(userID uint, backupCodeHashes []string) error
+	Delete(userID uint) error
+	UseStep(userID uint, step int64) (bool, error)
+	UseBackupCode(userID uint, codeHash string) (bool, error)
+	CountBackupCodes(userID uint) (int, error)
+	RecordFailure(userID uint, maxAttempts, lockSeconds int) error
+	SaveChallenge(ch *models.MFAChallenge) error
+	FindChallenge(idHash string) (*models.MFAChallenge, error)
+	ConsumeChallenge(idHash string) (bool, error)
+	DeleteExpiredChallenges() error
+}
+
+func (s *AuthService) WithMFARepository(mfaRepo MFARepository) *AuthService {
+	s.mfaRepo = mfaRepo
+	return s
+}
+
+// MFAEnrollment — данные для подключения приложения-аутентификатора
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type MFAStatus struct {
+	Enabled              bool `json:"enabled"`
+	Required             bool `json:"required"`
+	BackupCodesRemaining int  `json:"backup_codes_remaining"`
+}
+
+// MFAChallengeStart — вход остановлен до ввода второго фактора; Enroll — второй фактор
+// обязателен для роли, но еще не подключен
+type MFAChallengeStart struct {
+	Token  string
+	Enroll bool
+}
+
+// MFALogin — токены, выданные после второго фактора
+type MFALogin struct {
+	AccessToken  string
+	RefreshToken string
+	ReturnTo     string
+	// Только если второй фактор подключен в этом же входе
+	BackupCodes []string
+}
+
+// BeginMFAChallenge вызывается после входа через провайдера. nil — второй фактор не нужен,
+// можно сразу выдавать токены.
+func (s *AuthService) BeginMFAChallenge(user *models.User, scope, returnTo string) (*MFAChallengeStart, error) {
+	if s.mfaRepo == nil {
+		return nil, nil
+	}
+	m, err := s.mfaRepo.Find(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := m != nil && m.EnabledAt != nil
+	if !enabled && !s.mfaRequired(user.Role) {
+		return nil, nil
+	}
+
+	token, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	ch := &models.MFAChallenge{
+		IDHash:    hashSecret(token),
+		UserID:    user.ID,
+		Scope:     scope,
+		ReturnTo:  returnTo,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.mfaRepo.SaveChallenge(ch); err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.DeleteExpiredChallenges(); err != nil {
+		s.logger.WithError(err).Warn("failed to delete expired mfa challenges")
+	}
+	return &MFAChallengeStart{Token: token, Enroll: !enabled}, nil
+}
+
+// EnrollWithChallenge начинает подключение второго фактора прямо во время входа
+func (s *AuthService) EnrollWithChallenge(token string) (*MFAEnrollment, error) {
+	ch, err := s.findMFAChallenge(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.findUser(ch.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return s.beginMFAEnrollment(user)
+}
+
+// CompleteMFAChallenge проверяет код и выдает токены. Если второй фактор подключался
+// в этом входе, код подтверждает подключение, а в ответе будут резервные коды.
+func (s *AuthService) CompleteMFAChallenge(token, code string, client models.ClientInfo) (*MFALogin, error) {
+	ch, err := s.findMFAChallenge(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.findUser(ch.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+	m, err := s.mfaRepo.Find(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMFANotEnrolling
+	}
+
+	var backupCodes []string
+	if m.EnabledAt != nil {
+		err = s.verifyMFACode(m, code, true)
+	} else {
+		backupCodes, err = s.enableMFA(m, code)
+	}
+	switch {
+	case errors.Is(err, ErrInvalidMFACode):
+		s.recordLogin(user.ID, models.LoginKindLogin, LoginFailureInvalidMFACode, client)
+	case errors.Is(err, ErrMFALocked):
+		s.recordLogin(user.ID, models.LoginKindLogin, LoginFailureMFALocked, client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	consumed, err := s.mfaRepo.ConsumeChallenge(ch.IDHash)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	accessToken, refreshToken, err := s.GenerateTokens(user, strings.Fields(ch.Scope), client)
+	if err != nil {
+		return nil, err
+	}
+	return &MFALogin{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ReturnTo:     ch.ReturnTo,
+		BackupCodes:  backupCodes,
+	}, nil
+}
+
+func (s *AuthService) GetMFAStatus(userID uint, role string) (*MFAStatus, error) {
+	if s.mfaRepo == nil {
+		return nil, fmt.Errorf("mfa repository not configured")
+	}
+	status := &MFAStatus{Required: s.mfaRequired(role)}
+	m, err := s.mfaRepo.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || m.EnabledAt == nil {
+		return status, nil
+	}
+	status.Enabled = true
+	status.BackupCodesRemaining, err = s.mfaRepo.CountBackupCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// BeginMFAEnrollment выдает новый секрет; второй фактор включится после ConfirmMFAEnrollment
+func (s *AuthService) BeginMFAEnrollment(userID uint) (*MFAEnrollment, error) {
+	if s.mfaRepo == nil {
+		return nil, fmt.Errorf("mfa repository not configured")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return s.beginMFAEnrollment(user)
+}
+
+func (s *AuthService) beginMFAEnrollment(user *models.User) (*MFAEnrollment, error) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.sealTOTPSecret(user.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := s.mfaRepo.SavePending(user.ID, stored)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return &MFAEnrollment{
+		Secret: secret,
+		URI:    totpURI(s.config.MFAIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment включает второй фактор по первому коду из приложения и возвращает резервные коды
+func (s *AuthService) ConfirmMFAEnrollment(userID uint, code string) ([]string, error) {
+	if s.mfaRepo == nil {
+		return nil, fmt.Errorf("mfa repository not configured")
+	}
+	m, err := s.mfaRepo.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMFANotEnrolling
+	}
+	if m.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return s.enableMFA(m, code)
+}
+
+func (s *AuthService) enableMFA(m *models.UserMFA, code string) ([]string, error) {
+	if err := s.verifyMFACode(m, code, false); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.Enable(m.UserID, hashes); err != nil {
+		return nil, err
+	}
+	s.logger.WithField("user_id", m.UserID).Info("Two-factor authentication enabled")
+	return codes, nil
+}
+
+// DisableMFA отключает второй фактор по действующему коду; для ролей с обязательным 2FA нельзя
+func (s *AuthService) DisableMFA(userID uint, role, code string) error {
+	if s.mfaRepo == nil {
+		return fmt.Errorf("mfa repository not configured")
+	}
+	if s.mfaRequired(role) {
+		return ErrMFARequiredByRole
+	}
+	m, err := s.enabledMFA(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyMFACode(m, code, true); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.Delete(userID); err != nil {
+		return err
+	}
+	s.logger.WithField("user_id", userID).Info("Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateBackupCodes заменяет все резервные коды новыми
+func (s *AuthService) RegenerateBackupCodes(userID uint, code string) ([]string, error) {
+	if s.mfaRepo == nil {
+		return nil, fmt.Errorf("mfa repository not configured")
+	}
+	m, err := s.enabledMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyMFACode(m, code, false); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceBackupCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetMFA — восстановление доступа администратором, когда пользователь потерял
+// и приложение, и резервные коды. Если роль требует 2FA, при следующем входе
+// пользователь подключит его заново.
+func (s *AuthService) ResetMFA(actorPermissions []string, userID uint) error {
+	if s.mfaRepo == nil {
+		return fmt.Errorf("mfa repository not configured")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkCanManage(actorPermissions, user); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.Delete(userID); err != nil {
+		return err
+	}
+	s.logger.WithField("user_id", userID).Warn("Two-factor authentication reset by admin")
+	return nil
+}
+
+// SetRoleRequireMFA делает второй фактор обязательным для роли (действует со следующего входа)
+func (s *AuthService) SetRoleRequireMFA(name string, required bool) error {
+	if s.roles == nil {
+		return fmt.Errorf("roles not configured")
+	}
+	found, err := s.roles.repo.SetRequireMFA(name, required)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrRoleNotFound
+	}
+	s.reloadRoles()
+	return nil
+}
+
+func (s *AuthService) mfaRequired(role string) bool {
+	return s.roles != nil && s.roles.RequiresMFA(role)
+}
+
+func (s *AuthService) enabledMFA(userID uint) (*models.UserMFA, error) {
+	m, err := s.mfaRepo.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || m.EnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+	return m, nil
+}
+
+func (s *AuthService) findMFAChallenge(token string) (*models.MFAChallenge, error) {
+	if s.mfaRepo == nil {
+		return nil, fmt.Errorf("mfa repository not configured")
+	}
+	ch, err := s.mfaRepo.FindChallenge(hashSecret(token))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ExpiresAt.Before(time.Now()) {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return ch, nil
+}
+
+// verifyMFACode принимает TOTP-код (каждый не больше одного раза) или, если allowBackup,
+// неиспользованный резервный код
+func (s *AuthService) verifyMFACode(m *models.UserMFA, code string, allowBackup bool) error {
+	if m.LockedUntil != nil && m.LockedUntil.After(time.Now()) {
+		return ErrMFALocked
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	secret, err := s.totpSecret(m)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := verifyTOTP(secret, code, time.Now()); ok {
+		used, err := s.mfaRepo.UseStep(m.UserID, step)
+		if err != nil {
+			return err
+		}
+		if used {
+			s.rewrapTOTPSecret(m, secret)
+			return nil
+		}
+	} else if allowBackup && len(normalizeBackupCode(code)) == 8 {
+		used, err := s.mfaRepo.UseBackupCode(m.UserID, hashSecret(normalizeBackupCode(code)))
+		if err != nil {
+			return err
+		}
+		if used {
+			s.logger.WithField("user_id", m.UserID).Warn("MFA backup code used")
+			return nil
+		}
+	}
+
+	if err := s.mfaRepo.RecordFailure(m.UserID, mfaMaxAttempts, mfaLockSeconds); err != nil {
+		s.logger.WithError(err).WithField("user_id", m.UserID).Error("Failed to record invalid mfa code")
+	}
+	s.logger.WithFields(logrus.Fields{"user_id": m.UserID}).Warn("Invalid MFA code")
+	return ErrInvalidMFACode
+}
+
+// totpSecret расшифровывает секрет; записанный до включения TOKEN_ENCRYPTION_KEY хранится открыто
+func (s *AuthService) totpSecret(m *models.UserMFA) (string, error) {
+	if !secrets.IsEncrypted(m.Secret) {
+		return m.Secret, nil
+	}
+	if s.secretCipher == nil {
+		return "", fmt.Errorf("totp secret is encrypted, but TOKEN_ENCRYPTION_KEY is not set")
+	}
+	return s.secretCipher.DecryptString(m.Secret, mfaSecretAD(m.UserID))
+}
+
+func (s *AuthService) sealTOTPSecret(userID uint, secret string) (string, error) {
+	if s.secretCipher == nil {
+		return secret, nil
+	}
+	return s.secretCipher.EncryptString(secret, mfaSecretAD(userID))
+}
+
+// rewrapTOTPSecret шифрует текущим ключом секрет, записанный открыто или прежним ключом
+func (s *AuthService) rewrapTOTPSecret(m *models.UserMFA, secret string) {
+	if s.secretCipher == nil || !s.secretCipher.NeedsRewrapString(m.Secret) {
+		return
+	}
+	stored, err := s.sealTOTPSecret(m.UserID, secret)
+	if err == nil {
+		err = s.mfaRepo.UpdateSecret(m.UserID, stored)
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", m.UserID).Warn("Failed to re-encrypt TOTP secret")
+	}
+}
+
+func mfaSecretAD(userID uint) string {
+	return "user_mfa:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// newBackupCodes возвращает коды для показа пользователю (xxxx-xxxx) и их хеши для БД
+func newBackupCodes() ([]string, []string, error) {
+	codes := make([]string, 0, backupCodeCount)
+	hashes := make([]string, 0, backupCodeCount)
+	for i := 0; i < backupCodeCount; i++ {
+		b := make([]byte, 4)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hashSecret(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeBackupCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
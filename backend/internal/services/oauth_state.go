@@ -0,0 +1,134 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+var ErrInvalidState = errors.New("invalid or expired oauth state")
+
+type OAuthStateRepository interface {
+	Save(st *models.OAuthState) error
+	Consume(state string) (*models.OAuthState, error)
+	DeleteExpired() error
+}
+
+func (s *AuthService) WithStateRepository(stateRepo OAuthStateRepository) *AuthService {
+	s.stateRepo = stateRepo
+	return s
+}
+
+// BeginLogin создает state и PKCE code_verifier для нового OAuth-входа через provider;
+// scope ограничивает токены, которые будут выданы после входа
+func (s *AuthService) BeginLogin(provider, returnTo, scope string) (*models.OAuthState, error) {
+	scopes, err := ParseScopes(scope)
+	if err != nil {
+		return nil, err
+	}
+	return s.beginOAuth(provider, returnTo, 0, strings.Join(scopes, " "))
+}
+
+// BeginLink начинает OAuth-поток, по завершении которого учетная запись провайдера привяжется к userID
+func (s *AuthService) BeginLink(provider string, userID uint, returnTo string) (*models.OAuthState, error) {
+	return s.beginOAuth(provider, returnTo, userID, "")
+}
+
+func (s *AuthService) beginOAuth(provider, returnTo string, linkUserID uint, scope string) (*models.OAuthState, error) {
+	if s.stateRepo == nil {
+		return nil, fmt.Errorf("state repository not configured")
+	}
+
+	state, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLToken(48)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	st := &models.OAuthState{
+		State:        state,
+		Provider:     provider,
+		CodeVerifier: verifier,
+		ReturnTo:     SanitizeReturnTo(returnTo),
+		LinkUserID:   linkUserID,
+		Scope:        scope,
+		ExpiresAt:    now.Add(oauthStateTTL),
+		CreatedAt:    now,
+	}
+	if err := s.stateRepo.Save(st); err != nil {
+		return nil, err
+	}
+
+	// Заодно подчищаем брошенные входы
+	if err := s.stateRepo.DeleteExpired(); err != nil {
+		s.logger.WithError(err).Warn("failed to delete expired oauth states")
+	}
+
+	return st, nil
+}
+
+// ConsumeLoginState проверяет state из callback; каждый state можно использовать только один раз
+func (s *AuthService) ConsumeLoginState(state string) (*models.OAuthState, error) {
+	if s.stateRepo == nil {
+		return nil, fmt.Errorf("state repository not configured")
+	}
+	if state == "" {
+		return nil, ErrInvalidState
+	}
+
+	st, err := s.stateRepo.Consume(state)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		s.logger.Warn("OAuth state not found or already used")
+		return nil, ErrInvalidState
+	}
+	if st.ExpiresAt.Before(time.Now()) {
+		s.logger.Warn("OAuth state expired")
+		return nil, ErrInvalidState
+	}
+	return st, nil
+}
+
+// CodeChallengeS256 вычисляет PKCE code_challenge для code_verifier
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// SanitizeReturnTo пропускает только относительные пути фронтенда, чтобы не было open redirect
+func SanitizeReturnTo(returnTo string) string {
+	if returnTo == "" || !strings.HasPrefix(returnTo, "/") {
+		return ""
+	}
+	if strings.HasPrefix(returnTo, "//") || strings.ContainsAny(returnTo, "\\\r\n") {
+		return ""
+	}
+	u, err := url.Parse(returnTo)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return ""
+	}
+	return returnTo
+}
+
+func randomURLToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,594 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	authorizationRequestTTL = 10 * time.Minute
+	authorizationCodeTTL    = time.Minute
+	idTokenTTL              = time.Hour
+)
+
+// SupportedOIDCScopes — scope, которые мы умеем выдавать клиентам OIDC
+var SupportedOIDCScopes = []string{"openid", "profile", "email"}
+
+var ErrAuthorizationRequestNotFound = errors.New("authorization request not found or expired")
+
+// OAuthError — ошибка протокола OAuth 2.0 (RFC 6749, раздел 5.2), отдается клиенту как есть
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+type OAuthClientRepository interface {
+	FindByID(id string) (*models.OAuthClient, error)
+	FindAll() ([]models.OAuthClient, error)
+	Create(c *models.OAuthClient) error
+	UpdateSecret(id, secretHash string) error
+	SetDisabled(id string, disabled bool) error
+}
+
+type AuthorizationRepository interface {
+	SaveRequest(req *models.AuthorizationRequest) error
+	FindRequest(id string) (*models.AuthorizationRequest, error)
+	ConsumeRequest(id string) (*models.AuthorizationRequest, error)
+	SaveCode(code *models.AuthorizationCode) error
+	ConsumeCode(codeHash string) (*models.AuthorizationCode, error)
+	DeleteExpired() error
+}
+
+func (s *AuthService) WithOAuthRepositories(clientRepo OAuthClientRepository, authorizationRepo AuthorizationRepository) *AuthService {
+	s.clientRepo = clientRepo
+	s.authorizationRepo = authorizationRepo
+	return s
+}
+
+// AuthorizeParams — параметры запроса /authorize
+type AuthorizeParams struct {
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// OIDCTokens — ответ /token для клиента OIDC
+type OIDCTokens struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	ExpiresIn    int
+	Scope        string
+}
+
+// Issuer — идентификатор нашего сервиса как OIDC-провайдера
+func (s *AuthService) Issuer() string {
+	return strings.TrimRight(s.config.PublicURL, "/")
+}
+
+// OAuthClientParams — параметры регистрации клиента
+type OAuthClientParams struct {
+	Name         string
+	GrantTypes   []string
+	RedirectURIs []string
+	Scopes       []string
+	Public       bool
+}
+
+// CreateOAuthClient регистрирует приложение; секрет возвращается один раз и хранится только в виде хеша
+func (s *AuthService) CreateOAuthClient(params OAuthClientParams) (*models.OAuthClient, string, error) {
+	if s.clientRepo == nil {
+		return nil, "", fmt.Errorf("oauth client repository not configured")
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("client name is required")
+	}
+
+	grantTypes := params.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{"authorization_code"}
+	}
+	var allowedScopes, defaultScopes []string
+	for _, grantType := range grantTypes {
+		switch grantType {
+		case "authorization_code":
+			if len(params.RedirectURIs) == 0 {
+				return nil, "", fmt.Errorf("at least one redirect URI is required")
+			}
+			allowedScopes = append(allowedScopes, SupportedOIDCScopes...)
+			defaultScopes = append(defaultScopes, SupportedOIDCScopes...)
+		case "client_credentials":
+			if params.Public {
+				return nil, "", fmt.Errorf("client_credentials requires a confidential client")
+			}
+			allowedScopes = append(allowedScopes, ServiceScopes...)
+			defaultScopes = append(defaultScopes, ServiceScopes...)
+		case DeviceCodeGrantType:
+			// Устройство получает сессию пользователя; account и admin:* только если их добавили клиенту явно
+			allowedScopes = append(allowedScopes, UserScopes...)
+			allowedScopes = append(allowedScopes, AdminScopes...)
+			defaultScopes = append(defaultScopes, DeviceScopes...)
+		default:
+			return nil, "", fmt.Errorf("unsupported grant type %q", grantType)
+		}
+	}
+	for _, uri := range params.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, "", err
+		}
+	}
+	scopes := params.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+	for _, scope := range scopes {
+		if !containsString(allowedScopes, scope) {
+			return nil, "", fmt.Errorf("unsupported scope %q", scope)
+		}
+	}
+
+	clientID, err := randomURLToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	client := &models.OAuthClient{
+		ID:           clientID,
+		Name:         name,
+		GrantTypes:   grantTypes,
+		RedirectURIs: params.RedirectURIs,
+		Scopes:       scopes,
+	}
+
+	var secret string
+	if !params.Public {
+		secret, err = randomURLToken(32)
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashSecret(secret)
+	}
+
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, "", err
+	}
+	s.logger.WithField("client_id", client.ID).Infof("OAuth client %q registered", client.Name)
+	return client, secret, nil
+}
+
+func (s *AuthService) ListOAuthClients() ([]models.OAuthClient, error) {
+	if s.clientRepo == nil {
+		return nil, fmt.Errorf("oauth client repository not configured")
+	}
+	clients, err := s.clientRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	if clients == nil {
+		clients = []models.OAuthClient{}
+	}
+	return clients, nil
+}
+
+// AuthenticateClient проверяет client_id и client_secret; публичные клиенты секрета не имеют
+func (s *AuthService) AuthenticateClient(clientID, secret string) (*models.OAuthClient, error) {
+	if s.clientRepo == nil {
+		return nil, fmt.Errorf("oauth client repository not configured")
+	}
+	if clientID == "" {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	client, err := s.clientRepo.FindByID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || client.Disabled() {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	if client.Public() {
+		if secret != "" {
+			return nil, oauthError("invalid_client", "client authentication failed")
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(client.SecretHash)) != 1 {
+		s.logger.WithField("client_id", clientID).Warn("OAuth client presented wrong secret")
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	return client, nil
+}
+
+// ResolveRedirect находит клиента и проверяет redirect_uri. Пока он не проверен,
+// ошибки показываются пользователю, а не отправляются редиректом.
+func (s *AuthService) ResolveRedirect(clientID, redirectURI string) (*models.OAuthClient, string, error) {
+	if s.clientRepo == nil {
+		return nil, "", fmt.Errorf("oauth client repository not configured")
+	}
+	if clientID == "" {
+		return nil, "", oauthError("invalid_request", "client_id is required")
+	}
+	client, err := s.clientRepo.FindByID(clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if client == nil || client.Disabled() {
+		return nil, "", oauthError("invalid_client", "unknown client_id")
+	}
+	if !client.AllowsGrant("authorization_code") {
+		return nil, "", oauthError("unauthorized_client", "client is not allowed to use the authorization code flow")
+	}
+	if redirectURI == "" {
+		// Без redirect_uri допускается, только если он у клиента единственный
+		if len(client.RedirectURIs) != 1 {
+			return nil, "", oauthError("invalid_request", "redirect_uri is required")
+		}
+		return client, client.RedirectURIs[0], nil
+	}
+	if !containsString(client.RedirectURIs, redirectURI) {
+		return nil, "", oauthError("invalid_request", "redirect_uri is not registered for this client")
+	}
+	return client, redirectURI, nil
+}
+
+// BeginAuthorization сохраняет запрос /authorize до подтверждения пользователем
+func (s *AuthService) BeginAuthorization(client *models.OAuthClient, redirectURI string, params AuthorizeParams) (*models.AuthorizationRequest, error) {
+	if s.authorizationRepo == nil {
+		return nil, fmt.Errorf("authorization repository not configured")
+	}
+	if params.ResponseType != "code" {
+		return nil, oauthError("unsupported_response_type", "only response_type=code is supported")
+	}
+
+	scopes := strings.Fields(params.Scope)
+	if len(scopes) == 0 {
+		for _, scope := range client.Scopes {
+			if containsString(SupportedOIDCScopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	for _, scope := range scopes {
+		if !containsString(client.Scopes, scope) || !containsString(SupportedOIDCScopes, scope) {
+			return nil, oauthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", scope))
+		}
+	}
+
+	if params.CodeChallenge == "" {
+		if client.Public() {
+			return nil, oauthError("invalid_request", "code_challenge is required for public clients")
+		}
+	} else if params.CodeChallengeMethod != "S256" {
+		return nil, oauthError("invalid_request", "only code_challenge_method=S256 is supported")
+	}
+
+	id, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	req := &models.AuthorizationRequest{
+		ID:            id,
+		ClientID:      client.ID,
+		RedirectURI:   redirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         params.State,
+		Nonce:         params.Nonce,
+		CodeChallenge: params.CodeChallenge,
+		ExpiresAt:     now.Add(authorizationRequestTTL),
+		CreatedAt:     now,
+	}
+	if err := s.authorizationRepo.SaveRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizationRepo.DeleteExpired(); err != nil {
+		s.logger.WithError(err).Warn("failed to delete expired authorization requests")
+	}
+	return req, nil
+}
+
+// GetAuthorizationRequest возвращает запрос и клиента для страницы подтверждения
+func (s *AuthService) GetAuthorizationRequest(id string) (*models.AuthorizationRequest, *models.OAuthClient, error) {
+	if s.authorizationRepo == nil || s.clientRepo == nil {
+		return nil, nil, fmt.Errorf("authorization repository not configured")
+	}
+	req, err := s.authorizationRepo.FindRequest(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req == nil || req.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrAuthorizationRequestNotFound
+	}
+	client, err := s.clientRepo.FindByID(req.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		return nil, nil, ErrAuthorizationRequestNotFound
+	}
+	return req, client, nil
+}
+
+// ApproveAuthorization выдает authorization code и возвращает URL, куда отправить пользователя
+func (s *AuthService) ApproveAuthorization(id string, userID uint) (string, error) {
+	req, err := s.consumeAuthorizationRequest(id)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = s.authorizationRepo.SaveCode(&models.AuthorizationCode{
+		CodeHash:      hashSecret(code),
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(authorizationCodeTTL),
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"client_id": req.ClientID,
+		"user_id":   userID,
+	}).Info("OAuth authorization approved")
+
+	q := url.Values{}
+	q.Set("code", code)
+	return s.authorizationRedirect(req, q), nil
+}
+
+// DenyAuthorization отклоняет запрос; клиент получит error=access_denied
+func (s *AuthService) DenyAuthorization(id string) (string, error) {
+	req, err := s.consumeAuthorizationRequest(id)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("error", "access_denied")
+	return s.authorizationRedirect(req, q), nil
+}
+
+// AuthorizationErrorRedirect строит редирект с ошибкой на уже проверенный redirect_uri
+func (s *AuthService) AuthorizationErrorRedirect(redirectURI, state string, oauthErr *OAuthError) string {
+	q := url.Values{}
+	q.Set("error", oauthErr.Code)
+	q.Set("error_description", oauthErr.Description)
+	return s.authorizationRedirect(&models.AuthorizationRequest{RedirectURI: redirectURI, State: state}, q)
+}
+
+func (s *AuthService) consumeAuthorizationRequest(id string) (*models.AuthorizationRequest, error) {
+	if s.authorizationRepo == nil {
+		return nil, fmt.Errorf("authorization repository not configured")
+	}
+	req, err := s.authorizationRepo.ConsumeRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || req.ExpiresAt.Before(time.Now()) {
+		return nil, ErrAuthorizationRequestNotFound
+	}
+	return req, nil
+}
+
+func (s *AuthService) authorizationRedirect(req *models.AuthorizationRequest, q url.Values) string {
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	// RFC 9207: клиент может убедиться, что ответ пришел от нас
+	q.Set("iss", s.Issuer())
+
+	sep := "?"
+	if strings.Contains(req.RedirectURI, "?") {
+		sep = "&"
+	}
+	return req.RedirectURI + sep + q.Encode()
+}
+
+// ExchangeAuthorizationCode обменивает code на access token и ID token (grant_type=authorization_code)
+func (s *AuthService) ExchangeAuthorizationCode(client *models.OAuthClient, code, redirectURI, codeVerifier string) (*OIDCTokens, error) {
+	if s.authorizationRepo == nil || s.userRepo == nil {
+		return nil, fmt.Errorf("authorization repository not configured")
+	}
+	if code == "" {
+		return nil, oauthError("invalid_request", "code is required")
+	}
+
+	authCode, err := s.authorizationRepo.ConsumeCode(hashSecret(code))
+	if err != nil {
+		return nil, err
+	}
+	if authCode == nil {
+		return nil, oauthError("invalid_grant", "authorization code is invalid")
+	}
+	if authCode.UsedAt != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"client_id": authCode.ClientID,
+			"user_id":   authCode.UserID,
+		}).Warn("Authorization code was presented again (possible code interception)")
+		return nil, oauthError("invalid_grant", "authorization code has already been used")
+	}
+	if authCode.ExpiresAt.Before(time.Now()) {
+		return nil, oauthError("invalid_grant", "authorization code has expired")
+	}
+	if authCode.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "authorization code was issued to another client")
+	}
+	if authCode.RedirectURI != redirectURI {
+		return nil, oauthError("invalid_grant", "redirect_uri does not match")
+	}
+
+	if authCode.CodeChallenge != "" {
+		if codeVerifier == "" || subtle.ConstantTimeCompare([]byte(CodeChallengeS256(codeVerifier)), []byte(authCode.CodeChallenge)) != 1 {
+			return nil, oauthError("invalid_grant", "code_verifier does not match code_challenge")
+		}
+	} else if codeVerifier != "" {
+		return nil, oauthError("invalid_grant", "code_verifier was sent without code_challenge")
+	}
+
+	user, err := s.findUser(authCode.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, oauthError("invalid_grant", "user no longer exists")
+	}
+	if err := s.CheckBan(user.ID); err != nil {
+		return nil, oauthError("access_denied", err.Error())
+	}
+
+	now := time.Now()
+	accessToken, err := s.keyring.Sign(jwt.MapClaims{
+		"iss":       s.Issuer(),
+		"sub":       Subject(user.ID),
+		"client_id": client.ID,
+		"scope":     authCode.Scope,
+		"jti":       uuid.New().String(),
+		"exp":       now.Add(accessTokenTTL).Unix(),
+		"iat":       now.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &OIDCTokens{
+		AccessToken: accessToken,
+		ExpiresIn:   int(accessTokenTTL.Seconds()),
+		Scope:       authCode.Scope,
+	}
+
+	scopes := strings.Fields(authCode.Scope)
+	if containsString(scopes, "openid") {
+		claims := jwt.MapClaims{
+			"iss":       s.Issuer(),
+			"sub":       Subject(user.ID),
+			"aud":       client.ID,
+			"exp":       now.Add(idTokenTTL).Unix(),
+			"iat":       now.Unix(),
+			"auth_time": authCode.AuthTime.Unix(),
+		}
+		if authCode.Nonce != "" {
+			claims["nonce"] = authCode.Nonce
+		}
+		for k, v := range s.userClaims(user, scopes) {
+			claims[k] = v
+		}
+		tokens.IDToken, err = s.keyring.Sign(claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"client_id": client.ID,
+		"user_id":   user.ID,
+	}).Info("OAuth authorization code exchanged")
+	return tokens, nil
+}
+
+// UserInfo возвращает claims пользователя для /userinfo. scopes == nil — наш собственный
+// токен (фронтенд), ему доступно все.
+func (s *AuthService) UserInfo(userID uint, scopes []string) (map[string]interface{}, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if scopes == nil {
+		scopes = SupportedOIDCScopes
+	}
+	claims := s.userClaims(user, scopes)
+	claims["sub"] = Subject(user.ID)
+	return claims, nil
+}
+
+func (s *AuthService) userClaims(user *models.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if containsString(scopes, "profile") {
+		claims["preferred_username"] = user.Username
+		claims["name"] = user.Username
+		if user.Avatar != "" {
+			claims["picture"] = user.Avatar
+		}
+	}
+	if containsString(scopes, "email") && s.identityRepo != nil {
+		identities, err := s.identityRepo.FindByUserID(user.ID)
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", user.ID).Warn("failed to load identities for email claim")
+		}
+		for _, identity := range identities {
+			if identity.Email != "" {
+				claims["email"] = identity.Email
+				break
+			}
+		}
+	}
+	return claims
+}
+
+// validateRedirectURI требует абсолютный URL без фрагмента; http разрешен только для localhost
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return fmt.Errorf("invalid redirect URI %q", uri)
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if host := u.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("redirect URI %q must use https", uri)
+		}
+	default:
+		return fmt.Errorf("invalid redirect URI %q", uri)
+	}
+	return nil
+}
+
+// hashSecret — хеш для секретов с высокой энтропией (client_secret, authorization code)
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/webauthn"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	passkeyChallengeTTL = 5 * time.Minute
+	maxPasskeysPerUser  = 20
+)
+
+var (
+	ErrInvalidPasskey          = errors.New("invalid passkey")
+	ErrPasskeyChallengeInvalid = errors.New("passkey challenge not found or expired")
+	ErrPasskeyNotFound         = errors.New("passkey not found")
+	ErrPasskeyExists           = errors.New("passkey is already registered")
+	ErrTooManyPasskeys         = errors.New("too many passkeys")
+	ErrInvalidPasskeyParams    = errors.New("invalid passkey parameters")
+)
+
+type PasskeyRepository interface {
+	Create(p *models.Passkey) error
+	FindByUserID(userID uint) ([]models.Passkey, error)
+	FindByCredentialID(credentialID string) (*models.Passkey, error)
+	UpdateSignCount(id uint, signCount uint32) (bool, error)
+	Delete(userID, id uint) (bool, error)
+	SaveChallenge(ch *models.WebAuthnChallenge) error
+	ConsumeChallenge(challengeHash, purpose string) (*models.WebAuthnChallenge, error)
+	DeleteExpiredChallenges() error
+}
+
+func (s *AuthService) WithPasskeyRepository(passkeyRepo PasskeyRepository) *AuthService {
+	s.passkeyRepo = passkeyRepo
+	return s
+}
+
+// PasskeyCredential — ответ navigator.credentials.create()/get() в JSON-представлении
+// (PublicKeyCredential.toJSON()), все двоичные поля в base64url
+type PasskeyCredential struct {
+	ID       string `json:"id" binding:"required"`
+	Type     string `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AttestationObject string `json:"attestationObject"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response" binding:"required"`
+}
+
+type PasskeyDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// PasskeyCreationOptions — publicKey для navigator.credentials.create()
+type PasskeyCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []PasskeyAlgorithm  `json:"pubKeyCredParams"`
+	Timeout                int64               `json:"timeout"`
+	ExcludeCredentials     []PasskeyDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+type PasskeyAlgorithm struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// PasskeyRequestOptions — publicKey для navigator.credentials.get(); allowCredentials пуст,
+// браузер сам предложит passkey этого сайта
+type PasskeyRequestOptions struct {
+	Challenge        string              `json:"challenge"`
+	RPID             string              `json:"rpId"`
+	Timeout          int64               `json:"timeout"`
+	UserVerification string              `json:"userVerification"`
+	AllowCredentials []PasskeyDescriptor `json:"allowCredentials"`
+}
+
+// BeginPasskeyRegistration начинает добавление passkey к аккаунту вошедшего пользователя
+func (s *AuthService) BeginPasskeyRegistration(userID uint) (*PasskeyCreationOptions, error) {
+	if s.passkeyRepo == nil {
+		return nil, fmt.Errorf("passkey repository not configured")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	existing, err := s.passkeyRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxPasskeysPerUser {
+		return nil, ErrTooManyPasskeys
+	}
+
+	challenge, err := s.savePasskeyChallenge(&userID, models.WebAuthnRegistration, "")
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &PasskeyCreationOptions{
+		Challenge:          challenge,
+		Timeout:            passkeyChallengeTTL.Milliseconds(),
+		ExcludeCredentials: make([]PasskeyDescriptor, 0, len(existing)),
+		Attestation:        "none",
+	}
+	opts.RP.ID = s.config.WebAuthnRPID
+	opts.RP.Name = s.config.WebAuthnRPName
+	opts.User.ID = webauthn.EncodeID(passkeyUserHandle(user.ID))
+	opts.User.Name = user.Username
+	opts.User.DisplayName = user.Username
+	for _, alg := range webauthn.SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, PasskeyAlgorithm{Type: "public-key", Alg: alg})
+	}
+	for _, p := range existing {
+		opts.ExcludeCredentials = append(opts.ExcludeCredentials, PasskeyDescriptor{Type: "public-key", ID: p.CredentialID})
+	}
+	// Ключ должен храниться на аутентификаторе (discoverable), иначе вход без Discord не найдет аккаунт
+	opts.AuthenticatorSelection.ResidentKey = "required"
+	opts.AuthenticatorSelection.UserVerification = "required"
+	return opts, nil
+}
+
+// FinishPasskeyRegistration проверяет ответ аутентификатора и сохраняет passkey
+func (s *AuthService) FinishPasskeyRegistration(userID uint, name string, cred *PasskeyCredential) (*models.Passkey, error) {
+	if s.passkeyRepo == nil {
+		return nil, fmt.Errorf("passkey repository not configured")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidPasskeyParams)
+	}
+
+	clientDataJSON, err := decodePasskeyField(cred.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	attestationObject, err := decodePasskeyField(cred.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	ch, challenge, err := s.consumePasskeyChallenge(clientDataJSON, models.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ch.UserID == nil || *ch.UserID != userID {
+		return nil, ErrPasskeyChallengeInvalid
+	}
+
+	credential, err := s.relyingParty().VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Warn("Passkey registration rejected")
+		return nil, ErrInvalidPasskey
+	}
+
+	credentialID := webauthn.EncodeID(credential.ID)
+	existing, err := s.passkeyRepo.FindByCredentialID(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrPasskeyExists
+	}
+
+	passkey := &models.Passkey{
+		UserID:       userID,
+		CredentialID: credentialID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		AAGUID:       formatAAGUID(credential.AAGUID),
+		Name:         name,
+	}
+	if err := s.passkeyRepo.Create(passkey); err != nil {
+		return nil, err
+	}
+	s.logger.WithFields(logrus.Fields{"user_id": userID, "passkey_id": passkey.ID}).Info("Passkey registered")
+	return passkey, nil
+}
+
+// BeginPasskeyLogin начинает вход по passkey; scope ограничивает выданные токены, как в /login
+func (s *AuthService) BeginPasskeyLogin(scope string) (*PasskeyRequestOptions, error) {
+	if s.passkeyRepo == nil {
+		return nil, fmt.Errorf("passkey repository not configured")
+	}
+	scopes, err := ParseScopes(scope)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.savePasskeyChallenge(nil, models.WebAuthnLogin, strings.Join(scopes, " "))
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyRequestOptions{
+		Challenge:        challenge,
+		RPID:             s.config.WebAuthnRPID,
+		Timeout:          passkeyChallengeTTL.Milliseconds(),
+		UserVerification: "required",
+		AllowCredentials: []PasskeyDescriptor{},
+	}, nil
+}
+
+// FinishPasskeyLogin проверяет подпись passkey и выдает токены так же, как вход через провайдера.
+// Passkey с проверкой пользователя (PIN, биометрия) сам по себе двухфакторный, TOTP не спрашиваем.
+func (s *AuthService) FinishPasskeyLogin(cred *PasskeyCredential, client models.ClientInfo) (string, string, error) {
+	if s.passkeyRepo == nil {
+		return "", "", fmt.Errorf("passkey repository not configured")
+	}
+	clientDataJSON, err := decodePasskeyField(cred.Response.ClientDataJSON)
+	if err != nil {
+		return "", "", err
+	}
+	authData, err := decodePasskeyField(cred.Response.AuthenticatorData)
+	if err != nil {
+		return "", "", err
+	}
+	signature, err := decodePasskeyField(cred.Response.Signature)
+	if err != nil {
+		return "", "", err
+	}
+	ch, challenge, err := s.consumePasskeyChallenge(clientDataJSON, models.WebAuthnLogin)
+	if err != nil {
+		return "", "", err
+	}
+
+	passkey, err := s.passkeyRepo.FindByCredentialID(cred.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if passkey == nil {
+		return "", "", ErrInvalidPasskey
+	}
+	if cred.Response.UserHandle != "" && cred.Response.UserHandle != webauthn.EncodeID(passkeyUserHandle(passkey.UserID)) {
+		return "", "", ErrInvalidPasskey
+	}
+
+	signCount, err := s.relyingParty().VerifyAssertion(challenge, passkey.PublicKey, passkey.SignCount, clientDataJSON, authData, signature)
+	if err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{"user_id": passkey.UserID, "passkey_id": passkey.ID}).Warn("Passkey login rejected")
+		s.recordLogin(passkey.UserID, models.LoginKindLogin, LoginFailureInvalidPasskey, client)
+		return "", "", ErrInvalidPasskey
+	}
+	updated, err := s.passkeyRepo.UpdateSignCount(passkey.ID, signCount)
+	if err != nil {
+		return "", "", err
+	}
+	if !updated {
+		s.logger.WithFields(logrus.Fields{"user_id": passkey.UserID, "passkey_id": passkey.ID}).Warn("Passkey sign counter went backwards, possible cloned key")
+		s.recordLogin(passkey.UserID, models.LoginKindLogin, LoginFailureInvalidPasskey, client)
+		return "", "", ErrInvalidPasskey
+	}
+
+	user, err := s.findUser(passkey.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	if user == nil {
+		return "", "", ErrInvalidPasskey
+	}
+	if err := s.checkGuildMembership(user); err != nil {
+		s.recordLogin(user.ID, models.LoginKindLogin, LoginFailureGuildMembership, client)
+		return "", "", err
+	}
+	return s.GenerateTokens(user, strings.Fields(ch.Scope), client)
+}
+
+func (s *AuthService) GetPasskeys(userID uint) ([]models.Passkey, error) {
+	if s.passkeyRepo == nil {
+		return nil, fmt.Errorf("passkey repository not configured")
+	}
+	passkeys, err := s.passkeyRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if passkeys == nil {
+		passkeys = []models.Passkey{}
+	}
+	return passkeys, nil
+}
+
+// DeletePasskey удаляет passkey; последний способ входа удалить нельзя (ErrLastIdentity)
+func (s *AuthService) DeletePasskey(userID, passkeyID uint) error {
+	if s.passkeyRepo == nil {
+		return fmt.Errorf("passkey repository not configured")
+	}
+	found, err := s.passkeyRepo.Delete(userID, passkeyID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrPasskeyNotFound
+	}
+	s.logger.WithFields(logrus.Fields{"user_id": userID, "passkey_id": passkeyID}).Info("Passkey deleted")
+	return nil
+}
+
+func (s *AuthService) relyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{
+		ID:      s.config.WebAuthnRPID,
+		Name:    s.config.WebAuthnRPName,
+		Origins: s.config.WebAuthnOrigins,
+	}
+}
+
+func (s *AuthService) savePasskeyChallenge(userID *uint, purpose, scope string) (string, error) {
+	challenge, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.passkeyRepo.SaveChallenge(&models.WebAuthnChallenge{
+		ChallengeHash: hashSecret(challenge),
+		UserID:        userID,
+		Purpose:       purpose,
+		Scope:         scope,
+		ExpiresAt:     time.Now().Add(passkeyChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.passkeyRepo.DeleteExpiredChallenges(); err != nil {
+		s.logger.WithError(err).Warn("failed to delete expired webauthn challenges")
+	}
+	return challenge, nil
+}
+
+// consumePasskeyChallenge находит церемонию по challenge из clientDataJSON; challenge одноразовый
+func (s *AuthService) consumePasskeyChallenge(clientDataJSON []byte, purpose string) (*models.WebAuthnChallenge, string, error) {
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return nil, "", ErrInvalidPasskey
+	}
+	ch, err := s.passkeyRepo.ConsumeChallenge(hashSecret(challenge), purpose)
+	if err != nil {
+		return nil, "", err
+	}
+	if ch == nil || ch.ExpiresAt.Before(time.Now()) {
+		return nil, "", ErrPasskeyChallengeInvalid
+	}
+	return ch, challenge, nil
+}
+
+func decodePasskeyField(value string) ([]byte, error) {
+	b, err := webauthn.DecodeID(strings.TrimRight(value, "="))
+	if err != nil || len(b) == 0 {
+		return nil, ErrInvalidPasskey
+	}
+	return b, nil
+}
+
+// passkeyUserHandle — user.id в WebAuthn; не содержит ничего, кроме нашего ID
+func passkeyUserHandle(userID uint) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(userID))
+	return b
+}
+
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Права, которые проверяют маршруты (RequirePermission); список в БД задается миграциями
+const (
+	PermissionUsersRead        = "users.read"
+	PermissionUsersManage      = "users.manage"
+	PermissionSessionsRevoke   = "sessions.revoke"
+	PermissionUsersImpersonate = "users.impersonate"
+	PermissionUsersBan         = "users.ban"
+	PermissionRolesManage      = "roles.manage"
+	PermissionKeysManage       = "keys.manage"
+	PermissionClientsManage    = "clients.manage"
+	PermissionAuditRead        = "audit.read"
+)
+
+// AdminRole — встроенная роль со всеми правами; ее права не редактируются,
+// чтобы нельзя было случайно закрыть себе доступ к админке
+const AdminRole = "admin"
+
+// DefaultRole — роль нового пользователя
+const DefaultRole = "user"
+
+// permissionScopes — scope, который получает токен пользователя с этим правом
+var permissionScopes = map[string]string{
+	PermissionUsersRead:        ScopeAdminUsers,
+	PermissionUsersManage:      ScopeAdminUsers,
+	PermissionSessionsRevoke:   ScopeAdminUsers,
+	PermissionUsersImpersonate: ScopeAdminUsers,
+	PermissionUsersBan:         ScopeAdminUsers,
+	PermissionRolesManage:      ScopeAdminSystem,
+	PermissionKeysManage:       ScopeAdminSystem,
+	PermissionClientsManage:    ScopeAdminSystem,
+	PermissionAuditRead:        ScopeAdminSystem,
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("role already exists")
+	ErrRoleProtected     = errors.New("role cannot be changed")
+	ErrRoleInUse         = errors.New("role is assigned to users")
+	ErrInvalidRoleParams = errors.New("invalid role parameters")
+	ErrRoleEscalation    = errors.New("cannot grant permissions you do not have")
+	ErrUserNotFound      = errors.New("user not found")
+)
+
+type RoleRepository interface {
+	FindAll() ([]models.Role, error)
+	FindAllPermissions() ([]models.Permission, error)
+	Create(role *models.Role) error
+	Update(role *models.Role) (bool, error)
+	Delete(name string) (bool, error)
+	CountUsers(name string) (int, error)
+	SetRequireMFA(name string, required bool) (bool, error)
+}
+
+// RoleCache — роли и их права в памяти; проверка прав на каждый запрос не ходит в БД.
+// Изменения на другом инстансе подхватываются не позже чем через интервал Run.
+type RoleCache struct {
+	repo   RoleRepository
+	logger *logrus.Logger
+
+	mu          sync.RWMutex
+	roles       map[string]models.Role
+	permissions []models.Permission
+}
+
+func NewRoleCache(repo RoleRepository, logger *logrus.Logger) *RoleCache {
+	return &RoleCache{
+		repo:   repo,
+		logger: logger,
+		roles:  map[string]models.Role{},
+	}
+}
+
+// Reload перечитывает роли и права из БД
+func (r *RoleCache) Reload() error {
+	roles, err := r.repo.FindAll()
+	if err != nil {
+		return err
+	}
+	permissions, err := r.repo.FindAllPermissions()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]models.Role, len(roles))
+	for _, role := range roles {
+		byName[role.Name] = role
+	}
+	r.mu.Lock()
+	r.roles = byName
+	r.permissions = permissions
+	r.mu.Unlock()
+	return nil
+}
+
+// Run периодически вызывает Reload, пока не закроют stop
+func (r *RoleCache) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				r.logger.WithError(err).Error("Failed to reload roles")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (r *RoleCache) Exists(role string) bool {
+	_, ok := r.find(role)
+	return ok
+}
+
+func (r *RoleCache) find(name string) (models.Role, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	role, ok := r.roles[name]
+	return role, ok
+}
+
+// Permissions — права роли; у неизвестной роли прав нет
+func (r *RoleCache) Permissions(role string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string{}, r.roles[role].Permissions...)
+}
+
+// RequiresMFA — обязателен ли второй фактор для роли
+func (r *RoleCache) RequiresMFA(role string) bool {
+	found, _ := r.find(role)
+	return found.RequireMFA
+}
+
+func (r *RoleCache) permissionExists(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AuthService) WithRoles(roles *RoleCache) *AuthService {
+	s.roles = roles
+	return s
+}
+
+// RolePermissions — права роли пользователя для фронтенда и проверок в сервисе
+func (s *AuthService) RolePermissions(role string) []string {
+	if s.roles == nil {
+		return []string{}
+	}
+	return s.roles.Permissions(role)
+}
+
+func (s *AuthService) ListRoles() ([]models.Role, error) {
+	if s.roles == nil {
+		return nil, fmt.Errorf("roles not configured")
+	}
+	roles, err := s.roles.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		roles = []models.Role{}
+	}
+	return roles, nil
+}
+
+func (s *AuthService) ListPermissions() ([]models.Permission, error) {
+	if s.roles == nil {
+		return nil, fmt.Errorf("roles not configured")
+	}
+	permissions, err := s.roles.repo.FindAllPermissions()
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		permissions = []models.Permission{}
+	}
+	return permissions, nil
+}
+
+// RoleParams — параметры создания и изменения роли
+type RoleParams struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// CreateRole создает роль; actorPermissions — права того, кто ее создает:
+// выдать роли право, которого нет у себя, нельзя
+func (s *AuthService) CreateRole(actorPermissions []string, params RoleParams) (*models.Role, error) {
+	if s.roles == nil {
+		return nil, fmt.Errorf("roles not configured")
+	}
+	if !roleNamePattern.MatchString(params.Name) {
+		return nil, fmt.Errorf("%w: name must match %s", ErrInvalidRoleParams, roleNamePattern.String())
+	}
+	if s.roles.Exists(params.Name) {
+		return nil, ErrRoleExists
+	}
+	permissions, err := s.checkRolePermissions(actorPermissions, params.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        params.Name,
+		Description: strings.TrimSpace(params.Description),
+		Permissions: permissions,
+	}
+	if err := s.roles.repo.Create(role); err != nil {
+		return nil, err
+	}
+	s.reloadRoles()
+	return role, nil
+}
+
+// UpdateRole заменяет описание и права роли
+func (s *AuthService) UpdateRole(actorPermissions []string, params RoleParams) (*models.Role, error) {
+	if s.roles == nil {
+		return nil, fmt.Errorf("roles not configured")
+	}
+	if params.Name == AdminRole {
+		return nil, ErrRoleProtected
+	}
+	if !s.roles.Exists(params.Name) {
+		return nil, ErrRoleNotFound
+	}
+	// Нельзя и отобрать у роли право, которого нет у себя
+	if _, err := s.checkRolePermissions(actorPermissions, s.roles.Permissions(params.Name)); err != nil {
+		return nil, err
+	}
+	permissions, err := s.checkRolePermissions(actorPermissions, params.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        params.Name,
+		Description: strings.TrimSpace(params.Description),
+		Permissions: permissions,
+	}
+	found, err := s.roles.repo.Update(role)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrRoleNotFound
+	}
+	s.reloadRoles()
+	return role, nil
+}
+
+// DeleteRole удаляет пользовательскую роль, которая никому не назначена
+func (s *AuthService) DeleteRole(name string) error {
+	if s.roles == nil {
+		return fmt.Errorf("roles not configured")
+	}
+	role, exists := s.roles.find(name)
+	if !exists {
+		return ErrRoleNotFound
+	}
+	if role.Builtin {
+		return ErrRoleProtected
+	}
+	users, err := s.roles.repo.CountUsers(name)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse
+	}
+
+	deleted, err := s.roles.repo.Delete(name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		// Роль успели назначить между проверкой и удалением
+		return ErrRoleInUse
+	}
+	s.reloadRoles()
+	return nil
+}
+
+// checkCanManage — действие над пользователем (смена роли, блокировка, сброс 2FA,
+// завершение сессий, impersonation) разрешено, только если все права его роли есть у actor:
+// иначе кастомная роль получила бы власть над теми, у кого прав больше
+func (s *AuthService) checkCanManage(actorPermissions []string, target *models.User) error {
+	return checkGrantable(actorPermissions, s.RolePermissions(target.Role))
+}
+
+// checkGrantable — у actor есть все permissions
+func checkGrantable(actorPermissions, permissions []string) error {
+	for _, p := range permissions {
+		if !containsString(actorPermissions, p) {
+			return fmt.Errorf("%w: %s", ErrRoleEscalation, p)
+		}
+	}
+	return nil
+}
+
+// checkRolePermissions проверяет, что права существуют и все есть у actor
+func (s *AuthService) checkRolePermissions(actorPermissions, permissions []string) ([]string, error) {
+	result := []string{}
+	for _, p := range permissions {
+		if !s.roles.permissionExists(p) {
+			return nil, fmt.Errorf("%w: unknown permission %q", ErrInvalidRoleParams, p)
+		}
+		if !containsString(result, p) {
+			result = append(result, p)
+		}
+	}
+	if err := checkGrantable(actorPermissions, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *AuthService) reloadRoles() {
+	if err := s.roles.Reload(); err != nil {
+		s.logger.WithError(err).Error("Failed to reload roles")
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RevokedTokenRepository interface {
+	Add(jti string, expiresAt time.Time) error
+	FindActive() (map[string]time.Time, error)
+	DeleteExpired() error
+}
+
+// RevocationList — denylist отозванных access token. Проверка идет по копии в памяти,
+// которая периодически перечитывается из БД, так что отзыв на другом инстансе
+// вступает в силу не позже чем через интервал Run.
+type RevocationList struct {
+	repo   RevokedTokenRepository
+	logger *logrus.Logger
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+func NewRevocationList(repo RevokedTokenRepository, logger *logrus.Logger) *RevocationList {
+	return &RevocationList{
+		repo:    repo,
+		logger:  logger,
+		revoked: map[string]time.Time{},
+	}
+}
+
+// Revoke сохраняет jti до истечения токена
+func (l *RevocationList) Revoke(jti string, expiresAt time.Time) error {
+	if err := l.repo.Add(jti, expiresAt); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.revoked[jti] = expiresAt
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *RevocationList) IsRevoked(jti string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, revoked := l.revoked[jti]
+	return revoked
+}
+
+// RevokeSession отзывает все access token сессии (claim sid). Запись нужна, пока не
+// истечет последний выданный в сессии access token; новых после отзыва семьи не будет.
+func (l *RevocationList) RevokeSession(sessionID string) error {
+	return l.Revoke(sessionRevocationKey(sessionID), time.Now().Add(accessTokenTTL))
+}
+
+func (l *RevocationList) IsSessionRevoked(sessionID string) bool {
+	return l.IsRevoked(sessionRevocationKey(sessionID))
+}
+
+// sessionRevocationKey — запись сессии в том же denylist, что и jti; префикс не пересекается с UUID
+func sessionRevocationKey(sessionID string) string {
+	return "sid:" + sessionID
+}
+
+// Reload перечитывает denylist из БД и удаляет истекшие записи
+func (l *RevocationList) Reload() error {
+	if err := l.repo.DeleteExpired(); err != nil {
+		l.logger.WithError(err).Warn("failed to delete expired revoked tokens")
+	}
+	revoked, err := l.repo.FindActive()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.revoked = revoked
+	l.mu.Unlock()
+	return nil
+}
+
+// Run периодически вызывает Reload, пока не закроют stop
+func (l *RevocationList) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.Reload(); err != nil {
+				l.logger.WithError(err).Error("Failed to reload revoked tokens")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *AuthService) WithRevocationList(revocations *RevocationList) *AuthService {
+	s.revocations = revocations
+	return s
+}
+
+// revokeSessionAccess — после отзыва семьи refresh-токенов перестают приниматься и уже
+// выданные access token этих сессий. Ошибка только логируется: сессии уже завершены
+func (s *AuthService) revokeSessionAccess(sessionIDs ...string) {
+	if s.revocations == nil {
+		return
+	}
+	for _, sessionID := range sessionIDs {
+		if err := s.revocations.RevokeSession(sessionID); err != nil {
+			s.logger.WithError(err).WithField("session_id", sessionID).Error("Failed to revoke session access tokens")
+		}
+	}
+}
+
+// RevokeToken — RFC 7009. client == nil, если запрос пришел от нашего фронтенда без
+// учетных данных клиента: тогда отзываются только выданные фронтенду токены.
+// Неизвестный, истекший или чужой токен молча игнорируется (раздел 2.2).
+func (s *AuthService) RevokeToken(token string, client *models.OAuthClient) error {
+	if s.revocations == nil {
+		return fmt.Errorf("revocation list not configured")
+	}
+	if token == "" {
+		return nil
+	}
+
+	if strings.Count(token, ".") != 2 {
+		// Refresh token есть только у нашего фронтенда; отзываем всю сессию
+		if client != nil {
+			return nil
+		}
+		return s.Logout(token)
+	}
+
+	claims, err := s.keyring.Parse(token)
+	if err != nil {
+		return nil
+	}
+	if _, isIDToken := claims["aud"]; isIDToken {
+		return nil
+	}
+	clientID, _ := claims["client_id"].(string)
+	if (client == nil && clientID != "") || (client != nil && clientID != client.ID) {
+		s.logger.WithField("client_id", clientID).Warn("Attempt to revoke a token issued to another client")
+		return nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || exp == nil {
+		// Токены без jti выпущены до появления отзыва и скоро истекут сами
+		return nil
+	}
+	if err := s.revocations.Revoke(jti, exp.Time); err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"sub":       claims["sub"],
+		"client_id": clientID,
+	}).Info("Access token revoked")
+	return nil
+}
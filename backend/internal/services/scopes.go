@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Scope access token'ов: ограничивают токен подмножеством того, что разрешает роль
+const (
+	ScopeProfile         = "profile"
+	ScopeCharactersRead  = "characters:read"
+	ScopeCharactersWrite = "characters:write"
+	// ScopeAccount — сессии, привязка учетных записей, API-ключи, подтверждение входа в приложения
+	ScopeAccount = "account"
+	// ScopeAdminUsers — управление пользователями в админке
+	ScopeAdminUsers = "admin:users"
+	// ScopeAdminSystem — ключи подписи и OAuth-клиенты
+	ScopeAdminSystem = "admin:system"
+)
+
+// UserScopes — scope, доступные любому пользователю
+var UserScopes = []string{ScopeProfile, ScopeCharactersRead, ScopeCharactersWrite, ScopeAccount}
+
+// DeviceScopes — scope устройства (device flow) по умолчанию: без account устройство
+// не создает API-ключи, не подтверждает вход в приложения и не завершает сессии
+var DeviceScopes = []string{ScopeProfile, ScopeCharactersRead, ScopeCharactersWrite}
+
+// AdminScopes — scope админки; роль получает их по своим правам (permissionScopes)
+var AdminScopes = []string{ScopeAdminUsers, ScopeAdminSystem}
+
+var ErrInvalidScope = errors.New("invalid scope")
+
+// ScopesForRole — все scope, которые может получить пользователь с этой ролью
+func (s *AuthService) ScopesForRole(role string) []string {
+	scopes := append([]string{}, UserScopes...)
+	for _, permission := range s.RolePermissions(role) {
+		if scope, ok := permissionScopes[permission]; ok && !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// ParseScopes разбирает scope из запроса входа; пустая строка — scope не ограничены
+func ParseScopes(scope string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	for _, sc := range scopes {
+		if !containsString(UserScopes, sc) && !containsString(AdminScopes, sc) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, sc)
+		}
+	}
+	return scopes, nil
+}
+
+// grantedScopes — scope, которые попадут в токен: запрошенные, но не больше, чем
+// разрешает роль. Роль проверяется при каждой выдаче, поэтому после понижения
+// роли админские scope пропадут при ближайшем обновлении токена.
+func (s *AuthService) grantedScopes(role string, requested []string) []string {
+	allowed := s.ScopesForRole(role)
+	if len(requested) == 0 {
+		return allowed
+	}
+	granted := []string{}
+	for _, sc := range requested {
+		if containsString(allowed, sc) && !containsString(granted, sc) {
+			granted = append(granted, sc)
+		}
+	}
+	return granted
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"user-service/internal/models"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Logout завершает сессию, к которой относится refresh token
+func (s *AuthService) Logout(refreshTokenString string) error {
+	if s.tokenRepo == nil {
+		return fmt.Errorf("token repository not configured")
+	}
+	rt, err := s.tokenRepo.FindByHash(hashSecret(refreshTokenString))
+	if err != nil || rt == nil {
+		// Неизвестный токен — сессии и так нет
+		return nil
+	}
+	if err := s.tokenRepo.RevokeFamily(rt.FamilyID); err != nil {
+		return err
+	}
+	s.revokeSessionAccess(rt.FamilyID)
+	s.logger.WithField("user_id", rt.UserID).Info("User logged out")
+	return nil
+}
+
+// GetSessions возвращает активные сессии пользователя; currentSessionID помечается как текущая
+func (s *AuthService) GetSessions(userID uint, currentSessionID string) ([]models.Session, error) {
+	if s.tokenRepo == nil {
+		return nil, fmt.Errorf("token repository not configured")
+	}
+	sessions, err := s.tokenRepo.FindSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	for i := range sessions {
+		sessions[i].Device = DescribeDevice(sessions[i].UserAgent)
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(userID uint, sessionID string) error {
+	if s.tokenRepo == nil {
+		return fmt.Errorf("token repository not configured")
+	}
+	found, err := s.tokenRepo.RevokeUserFamily(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	s.revokeSessionAccess(sessionID)
+	s.logger.WithField("user_id", userID).WithField("session_id", sessionID).Info("Session revoked")
+	return nil
+}
+
+// RevokeAllSessions завершает все сессии пользователя ("выйти везде")
+func (s *AuthService) RevokeAllSessions(userID uint) error {
+	if s.tokenRepo == nil {
+		return fmt.Errorf("token repository not configured")
+	}
+	sessions, err := s.tokenRepo.FindSessions(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.tokenRepo.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		s.revokeSessionAccess(session.ID)
+	}
+	s.logger.WithField("user_id", userID).Info("All sessions revoked")
+	return nil
+}
+
+// RevokeUserSessions завершает сессии другого пользователя (админ)
+func (s *AuthService) RevokeUserSessions(actorPermissions []string, userID uint) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkCanManage(actorPermissions, user); err != nil {
+		return err
+	}
+	return s.RevokeAllSessions(userID)
+}
+
+// DescribeDevice грубо определяет браузер и ОС по User-Agent, например "Chrome on Windows"
+func DescribeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "yabrowser"):
+		browser = "Yandex Browser"
+	case strings.Contains(ua, "firefox"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl"), strings.Contains(ua, "go-http-client"), strings.Contains(ua, "python"):
+		browser = "API client"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
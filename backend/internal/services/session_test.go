@@ -0,0 +1,103 @@
+package services
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/keys"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+type memoryRevokedTokenRepo struct {
+	RevokedTokenRepository
+}
+
+func (r *memoryRevokedTokenRepo) Add(jti string, expiresAt time.Time) error { return nil }
+
+// sessionTokenRepo — memoryRefreshTokenRepo с отзывом семей
+type sessionTokenRepo struct {
+	memoryRefreshTokenRepo
+}
+
+func (r *sessionTokenRepo) RevokeFamily(familyID string) error {
+	now := time.Now()
+	for _, rt := range r.tokens {
+		if rt.FamilyID == familyID {
+			rt.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *sessionTokenRepo) RevokeUserFamily(userID uint, familyID string) (bool, error) {
+	return true, r.RevokeFamily(familyID)
+}
+
+func (r *sessionTokenRepo) FindSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	for _, rt := range r.tokens {
+		if rt.UserID == userID && rt.RevokedAt == nil {
+			sessions = append(sessions, models.Session{ID: rt.FamilyID})
+		}
+	}
+	return sessions, nil
+}
+
+func (r *sessionTokenRepo) RevokeAllForUser(userID uint) error {
+	sessions, _ := r.FindSessions(userID)
+	for _, session := range sessions {
+		r.RevokeFamily(session.ID)
+	}
+	return nil
+}
+
+func TestRevokedSessionRejectsAccessTokens(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	keyring := keys.NewKeyring(keys.NewHMACKey("test", []byte("test-secret-test-secret-test-secret")))
+	user := &models.User{ID: 7, Role: "user"}
+
+	tests := []struct {
+		name   string
+		revoke func(s *AuthService, refreshToken, sessionID string) error
+	}{
+		{"logout", func(s *AuthService, refreshToken, sessionID string) error { return s.Logout(refreshToken) }},
+		{"revoke session", func(s *AuthService, refreshToken, sessionID string) error { return s.RevokeSession(user.ID, sessionID) }},
+		{"revoke all sessions", func(s *AuthService, refreshToken, sessionID string) error { return s.RevokeAllSessions(user.ID) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revocations := NewRevocationList(&memoryRevokedTokenRepo{}, logger)
+			s := NewAuthService(&config.Config{}, logger).
+				WithKeyring(keyring).
+				WithRepositories(&memoryUserRepo{users: map[uint]*models.User{7: user}}, &sessionTokenRepo{}).
+				WithRevocationList(revocations)
+			accessToken, refreshToken, err := s.GenerateTokens(user, nil, models.ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := keyring.Parse(accessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionID, _ := claims["sid"].(string)
+			if revocations.IsSessionRevoked(sessionID) {
+				t.Fatal("session revoked before logout")
+			}
+
+			if err := tt.revoke(s, refreshToken, sessionID); err != nil {
+				t.Fatal(err)
+			}
+			if !revocations.IsSessionRevoked(sessionID) {
+				t.Error("access tokens of revoked session still accepted")
+			}
+			if revocations.IsRevoked(sessionID) {
+				t.Error("session ID collides with jti in denylist")
+			}
+		})
+	}
+}
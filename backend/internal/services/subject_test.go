@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSubjectUserID(t *testing.T) {
+	tests := []struct {
+		name   string
+		sub    interface{}
+		wantID uint
+		wantOK bool
+	}{
+		{"string", Subject(42), 42, true},
+		{"legacy number", float64(42), 42, true},
+		{"missing", nil, 0, false},
+		{"not a number", "client:abc", 0, false},
+		{"negative", "-1", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{}
+			if tt.sub != nil {
+				claims["sub"] = tt.sub
+			}
+			id, ok := SubjectUserID(claims)
+			if id != tt.wantID || ok != tt.wantOK {
+				t.Errorf("SubjectUserID(%v) = %d, %v; want %d, %v", tt.sub, id, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
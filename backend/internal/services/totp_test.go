@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Секрет из RFC 6238, приложение B: ASCII "12345678901234567890" в base32
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Векторы SHA1 из RFC 6238, приложение B (8 цифр, шаг 30 секунд)
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(rfcTOTPSecret, tt.unix/totpPeriod, 8)
+		if err != nil {
+			t.Fatalf("totpCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("totpCode(%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestTOTPCodeRFC4226(t *testing.T) {
+	// HOTP-векторы из RFC 4226, приложение D: шесть цифр, счетчик вместо шага
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for step, code := range want {
+		got, err := totpCode(rfcTOTPSecret, int64(step), 6)
+		if err != nil {
+			t.Fatalf("totpCode(%d): %v", step, err)
+		}
+		if got != code {
+			t.Errorf("totpCode(%d) = %s, want %s", step, got, code)
+		}
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := totpCode("not base32!", 1, totpDigits); err == nil {
+		t.Fatal("expected error for invalid secret")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+	code := func(step int64) string {
+		c, err := totpCode(rfcTOTPSecret, step, totpDigits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(current), current, true},
+		{"previous step", code(current - 1), current - 1, true},
+		{"next step", code(current + 1), current + 1, true},
+		{"outside skew", code(current - 2), 0, false},
+		{"wrong length", code(current)[:5], 0, false},
+		{"eight digits", "89005924", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := verifyTOTP(rfcTOTPSecret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("verifyTOTP = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+// stepMFARepo хранит last_used_step так же, как MFARepo.UseStep
+type stepMFARepo struct {
+	MFARepository
+	lastStep int64
+	failures int
+}
+
+func (r *stepMFARepo) UseStep(userID uint, step int64) (bool, error) {
+	if step <= r.lastStep {
+		return false, nil
+	}
+	r.lastStep = step
+	return true, nil
+}
+
+func (r *stepMFARepo) RecordFailure(userID uint, maxAttempts, lockSeconds int) error {
+	r.failures++
+	return nil
+}
+
+func TestVerifyMFACodeRejectsReplay(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	repo := &stepMFARepo{}
+	s := NewAuthService(&config.Config{}, logger).WithMFARepository(repo)
+	m := &models.UserMFA{UserID: 1, Secret: rfcTOTPSecret}
+
+	current := time.Now().Unix() / totpPeriod
+	code, err := totpCode(rfcTOTPSecret, current, totpDigits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := totpCode(rfcTOTPSecret, current-1, totpDigits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.verifyMFACode(m, code, false); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := s.verifyMFACode(m, code, false); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed code: got %v, want ErrInvalidMFACode", err)
+	}
+	// Код прошлого шага еще в окне, но после более нового шага принят быть не должен
+	if err := s.verifyMFACode(m, previous, false); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("older step: got %v, want ErrInvalidMFACode", err)
+	}
+	if repo.failures != 2 {
+		t.Errorf("failures = %d, want 2", repo.failures)
+	}
+}
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"fmt"
 	"sync"
 
 	"user-service/internal/models"
@@ -30,16 +29,3 @@ func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 func (s *UserService) GetAllUsers() ([]models.User, error) {
     return s.userRepo.FindAll()
 }
-
-func (s *UserService) UpdateUserRole(id uint, role string) error {
-    validRoles := map[string]bool{
-        "user":      true,
-        "moderator": true,
-        "admin":     true,
-    }
-    if !validRoles[role] {
-        return fmt.Errorf("invalid role")
-    }
-    return s.userRepo.UpdateRole(id, role)
-}
-
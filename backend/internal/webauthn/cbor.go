@@ -0,0 +1,122 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Минимальный декодер CBOR (RFC 8949): ровно то, что встречается в attestationObject
+// и COSE-ключах — целые, строки, массивы, map и простые значения. Теги и float не нужны.
+
+var errCBOR = errors.New("malformed cbor")
+
+const maxCBORDepth = 16
+
+// decodeCBOR разбирает одно значение и возвращает его и число прочитанных байт.
+// Map превращается в map[interface{}]interface{} с ключами int64 или string.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, int, error) {
+	if depth > maxCBORDepth {
+		return nil, 0, fmt.Errorf("%w: nesting too deep", errCBOR)
+	}
+	if len(data) == 0 {
+		return nil, 0, fmt.Errorf("%w: unexpected end of data", errCBOR)
+	}
+	major := data[0] >> 5
+	arg, n, err := cborArgument(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, 0, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, 0, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(data)-n) {
+			return nil, 0, fmt.Errorf("%w: string exceeds data", errCBOR)
+		}
+		b := data[n : n+int(arg)]
+		if major == 3 {
+			return string(b), n + int(arg), nil
+		}
+		return append([]byte(nil), b...), n + int(arg), nil
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, 0, fmt.Errorf("%w: array exceeds data", errCBOR)
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, m, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			n += m
+		}
+		return items, n, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, 0, fmt.Errorf("%w: map exceeds data", errCBOR)
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, k, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += k
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, fmt.Errorf("%w: unsupported map key", errCBOR)
+			}
+			value, v, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += v
+			m[key] = value
+		}
+		return m, n, nil
+	case 7:
+		switch data[0] & 0x1f {
+		case 20:
+			return false, 1, nil
+		case 21:
+			return true, 1, nil
+		case 22, 23:
+			return nil, 1, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("%w: unsupported item 0x%02x", errCBOR, data[0])
+}
+
+// cborArgument читает аргумент заголовка (длину или значение); неопределенная длина не поддерживается
+func cborArgument(data []byte) (uint64, int, error) {
+	info := data[0] & 0x1f
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info == 24 && len(data) >= 2:
+		return uint64(data[1]), 2, nil
+	case info == 25 && len(data) >= 3:
+		return uint64(binary.BigEndian.Uint16(data[1:3])), 3, nil
+	case info == 26 && len(data) >= 5:
+		return uint64(binary.BigEndian.Uint32(data[1:5])), 5, nil
+	case info == 27 && len(data) >= 9:
+		return binary.BigEndian.Uint64(data[1:9]), 9, nil
+	}
+	return 0, 0, fmt.Errorf("%w: bad header 0x%02x", errCBOR, data[0])
+}
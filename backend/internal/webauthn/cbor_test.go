@@ -0,0 +1,196 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// cborEncode — кодировщик CBOR для тестов: ровно те типы, что понимает decodeCBOR
+func cborEncode(v interface{}) []byte {
+	var buf bytes.Buffer
+	cborWrite(&buf, v)
+	return buf.Bytes()
+}
+
+func cborHeader(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= 0xff:
+		buf.Write([]byte{major<<5 | 24, byte(arg)})
+	case arg <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+func cborWrite(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case int:
+		cborWrite(buf, int64(v))
+	case int64:
+		if v >= 0 {
+			cborHeader(buf, 0, uint64(v))
+		} else {
+			cborHeader(buf, 1, uint64(-1-v))
+		}
+	case []byte:
+		cborHeader(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		cborHeader(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		cborHeader(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			cborWrite(buf, item)
+		}
+	case map[interface{}]interface{}:
+		// Канонический порядок ключей не важен для декодера, но делает вывод детерминированным
+		keys := make([][]byte, 0, len(v))
+		values := make(map[string][]byte, len(v))
+		for k, item := range v {
+			key := cborEncode(k)
+			keys = append(keys, key)
+			values[string(key)] = cborEncode(item)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		cborHeader(buf, 5, uint64(len(v)))
+		for _, key := range keys {
+			buf.Write(key)
+			buf.Write(values[string(key)])
+		}
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case nil:
+		buf.WriteByte(0xf6)
+	default:
+		panic("cborEncode: unsupported type")
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want interface{}
+	}{
+		{"small uint", []byte{0x17}, int64(23)},
+		{"uint8", []byte{0x18, 0x18}, int64(24)},
+		{"uint16", []byte{0x19, 0x01, 0x00}, int64(256)},
+		{"uint32", []byte{0x1a, 0x00, 0x01, 0x00, 0x00}, int64(65536)},
+		{"uint64", []byte{0x1b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, int64(1<<63 - 1)},
+		{"negative", []byte{0x26}, int64(-7)},
+		{"negative uint16", []byte{0x39, 0x01, 0x00}, int64(-257)},
+		{"bytes", []byte{0x43, 0x01, 0x02, 0x03}, []byte{1, 2, 3}},
+		{"empty bytes", []byte{0x40}, []byte(nil)},
+		{"text", []byte{0x64, 'n', 'o', 'n', 'e'}, "none"},
+		{"array", []byte{0x82, 0x01, 0x20}, []interface{}{int64(1), int64(-1)}},
+		{"map", []byte{0xa2, 0x01, 0x02, 0x63, 'f', 'm', 't', 0xf5}, map[interface{}]interface{}{int64(1): int64(2), "fmt": true}},
+		{"false", []byte{0xf4}, false},
+		{"true", []byte{0xf5}, true},
+		{"null", []byte{0xf6}, nil},
+		{"undefined", []byte{0xf7}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n, err := decodeCBOR(tt.in)
+			if err != nil {
+				t.Fatalf("decodeCBOR: %v", err)
+			}
+			if n != len(tt.in) {
+				t.Errorf("read %d bytes, want %d", n, len(tt.in))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORTrailingData(t *testing.T) {
+	got, n, err := decodeCBOR([]byte{0x01, 0xff, 0xff})
+	if err != nil || got != int64(1) || n != 1 {
+		t.Fatalf("got (%v, %d, %v), want (1, 1, nil)", got, n, err)
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, maxCBORDepth+2)
+	deep = append(deep, 0x01)
+
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"empty", nil},
+		// Обрезанные данные
+		{"truncated uint8", []byte{0x18}},
+		{"truncated uint16", []byte{0x19, 0x01}},
+		{"truncated uint32", []byte{0x1a, 0x00, 0x01}},
+		{"truncated uint64", []byte{0x1b, 0x00, 0x00, 0x00, 0x00}},
+		{"truncated bytes", []byte{0x43, 0x01, 0x02}},
+		{"truncated text", []byte{0x65, 'h', 'e'}},
+		{"truncated array", []byte{0x83, 0x01, 0x02}},
+		{"truncated map key", []byte{0xa1}},
+		{"truncated map value", []byte{0xa1, 0x01}},
+		// Длины и значения больше данных или типов
+		{"oversized bytes", []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}},
+		{"oversized text", []byte{0x7a, 0x7f, 0xff, 0xff, 0xff, 'a'}},
+		{"oversized array", []byte{0x9b, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01}},
+		{"oversized map", []byte{0xba, 0xff, 0xff, 0xff, 0xff, 0x01, 0x02}},
+		{"uint overflow", []byte{0x1b, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"negative overflow", []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		// Неподдерживаемое
+		{"reserved info", []byte{0x1c}},
+		{"indefinite bytes", []byte{0x5f, 0x41, 0x00, 0xff}},
+		{"indefinite array", []byte{0x9f, 0x01, 0xff}},
+		{"indefinite map", []byte{0xbf, 0x01, 0x02, 0xff}},
+		{"tag", []byte{0xc0, 0x60}},
+		{"float", []byte{0xf9, 0x3c, 0x00}},
+		{"array map key", []byte{0xa1, 0x80, 0x01}},
+		{"nesting too deep", deep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.in); !errors.Is(err, errCBOR) {
+				t.Fatalf("got %v, want errCBOR", err)
+			}
+		})
+	}
+}
+
+func TestCBOREncodeRoundTrip(t *testing.T) {
+	want := map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": bytes.Repeat([]byte{0xab}, 300),
+		int64(-3):  int64(-70000),
+		int64(1):   []interface{}{int64(1 << 40), false, nil},
+	}
+	raw := cborEncode(want)
+	got, n, err := decodeCBOR(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(raw) {
+		t.Errorf("read %d bytes, want %d", n, len(raw))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// Алгоритмы COSE (RFC 9053), которые мы предлагаем в pubKeyCredParams
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms — в порядке предпочтения
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// Параметры COSE_Key
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// publicKey — открытый ключ passkey, разобранный из COSE_Key
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+func parseCOSEKey(raw []byte) (*publicKey, error) {
+	value, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: public key is not a map", ErrInvalidCredential)
+	}
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case alg == AlgES256 && kty == ktyEC2:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: bad EC2 key", ErrInvalidCredential)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("%w: EC2 point is not on curve", ErrInvalidCredential)
+		}
+		return &publicKey{alg: alg, key: pub}, nil
+	case alg == AlgEdDSA && kty == ktyOKP:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: bad OKP key", ErrInvalidCredential)
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+	case alg == AlgRS256 && kty == ktyRSA:
+		n, _ := m[int64(coseN)].([]byte)
+		e, _ := m[int64(coseE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: bad RSA key", ErrInvalidCredential)
+		}
+		exp := new(big.Int).SetBytes(e)
+		return &publicKey{alg: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}}, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported algorithm %d", ErrInvalidCredential, alg)
+}
+
+// verify проверяет подпись assertion над authenticatorData || SHA-256(clientDataJSON)
+func (k *publicKey) verify(signed, sig []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch pub := k.key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(pub, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, signed, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
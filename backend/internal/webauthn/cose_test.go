@@ -0,0 +1,182 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func coseEC2Key(pub *ecdsa.PublicKey) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		int64(coseKty): int64(ktyEC2),
+		int64(coseAlg): int64(AlgES256),
+		int64(coseCrv): int64(crvP256),
+		int64(coseX):   pub.X.FillBytes(make([]byte, 32)),
+		int64(coseY):   pub.Y.FillBytes(make([]byte, 32)),
+	}
+}
+
+func coseRSAKey(pub *rsa.PublicKey) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		int64(coseKty): int64(ktyRSA),
+		int64(coseAlg): int64(AlgRS256),
+		int64(coseN):   pub.N.Bytes(),
+		int64(coseE):   big.NewInt(int64(pub.E)).Bytes(),
+	}
+}
+
+func coseOKPKey(pub ed25519.PublicKey) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		int64(coseKty): int64(ktyOKP),
+		int64(coseAlg): int64(AlgEdDSA),
+		int64(coseCrv): int64(crvEd25519),
+		int64(coseX):   []byte(pub),
+	}
+}
+
+// withKey — копия COSE-ключа с замененным (или удаленным, если value == nil) параметром
+func withKey(key map[interface{}]interface{}, label int64, value interface{}) map[interface{}]interface{} {
+	out := make(map[interface{}]interface{}, len(key))
+	for k, v := range key {
+		out[k] = v
+	}
+	if value == nil {
+		delete(out, label)
+	} else {
+		out[label] = value
+	}
+	return out
+}
+
+func TestParseCOSEKeyEC2(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseCOSEKey(cborEncode(coseEC2Key(&priv.PublicKey)))
+	if err != nil {
+		t.Fatalf("parseCOSEKey: %v", err)
+	}
+	if key.alg != AlgES256 {
+		t.Errorf("alg = %d, want %d", key.alg, AlgES256)
+	}
+
+	signed := []byte("authenticator data || client data hash")
+	digest := sha256.Sum256(signed)
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.verify(signed, sig) {
+		t.Error("valid signature rejected")
+	}
+	if key.verify([]byte("other data"), sig) {
+		t.Error("signature over other data accepted")
+	}
+}
+
+func TestParseCOSEKeyRSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseCOSEKey(cborEncode(coseRSAKey(&priv.PublicKey)))
+	if err != nil {
+		t.Fatalf("parseCOSEKey: %v", err)
+	}
+	if pub := key.key.(*rsa.PublicKey); pub.E != 65537 || pub.N.Cmp(priv.N) != 0 {
+		t.Errorf("parsed key does not match: e=%d", pub.E)
+	}
+
+	signed := []byte("authenticator data || client data hash")
+	digest := sha256.Sum256(signed)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.verify(signed, sig) {
+		t.Error("valid signature rejected")
+	}
+	sig[0] ^= 0xff
+	if key.verify(signed, sig) {
+		t.Error("corrupted signature accepted")
+	}
+}
+
+func TestParseCOSEKeyOKP(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseCOSEKey(cborEncode(coseOKPKey(pub)))
+	if err != nil {
+		t.Fatalf("parseCOSEKey: %v", err)
+	}
+	signed := []byte("authenticator data || client data hash")
+	if !key.verify(signed, ed25519.Sign(priv, signed)) {
+		t.Error("valid signature rejected")
+	}
+}
+
+func TestParseCOSEKeyInvalid(t *testing.T) {
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec := coseEC2Key(&ecPriv.PublicKey)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortRSA := coseRSAKey(&rsaPriv.PublicKey)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	okp := coseOKPKey(edPub)
+
+	offCurve := ecPriv.PublicKey.Y.FillBytes(make([]byte, 32))
+	offCurve[31] ^= 0x01
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"not a map", cborEncode([]interface{}{int64(1)})},
+		{"ec2 point off curve", cborEncode(withKey(ec, coseY, offCurve))},
+		{"ec2 wrong curve", cborEncode(withKey(ec, coseCrv, int64(2)))},
+		{"ec2 short x", cborEncode(withKey(ec, coseX, make([]byte, 31)))},
+		{"ec2 missing y", cborEncode(withKey(ec, coseY, nil))},
+		{"ec2 p-384 coordinates", cborEncode(withKey(withKey(ec, coseX, p384.X.Bytes()), coseY, p384.Y.Bytes()))},
+		{"ec2 with rsa kty", cborEncode(withKey(ec, coseKty, int64(ktyRSA)))},
+		{"rsa modulus too short", cborEncode(shortRSA)},
+		{"rsa empty exponent", cborEncode(withKey(withKey(shortRSA, coseN, make([]byte, 256)), coseE, []byte{}))},
+		{"rsa exponent too long", cborEncode(withKey(withKey(shortRSA, coseN, make([]byte, 256)), coseE, make([]byte, 5)))},
+		{"okp wrong curve", cborEncode(withKey(okp, coseCrv, int64(4)))},
+		{"okp short key", cborEncode(withKey(okp, coseX, make([]byte, 31)))},
+		{"unsupported alg", cborEncode(withKey(ec, coseAlg, int64(-35)))},
+		{"missing alg", cborEncode(withKey(ec, coseAlg, nil))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCOSEKey(tt.raw); !errors.Is(err, ErrInvalidCredential) {
+				t.Fatalf("got %v, want ErrInvalidCredential", err)
+			}
+		})
+	}
+
+	if _, err := parseCOSEKey([]byte{0xa1}); !errors.Is(err, errCBOR) {
+		t.Errorf("truncated key: got %v, want errCBOR", err)
+	}
+}
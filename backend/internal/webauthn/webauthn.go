@@ -0,0 +1,187 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCredential = errors.New("invalid webauthn credential")
+
+// Флаги authenticatorData
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// RelyingParty — наш сайт с точки зрения браузера: RP ID (домен) и допустимые origin.
+// Аттестация не проверяется (запрашиваем attestation: "none"): доверяем ключу,
+// который пользователь добавил из уже вошедшей сессии.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// Credential — новый ключ, прошедший проверку регистрации
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key как есть
+	SignCount uint32
+	AAGUID    []byte
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash   []byte
+	flags      byte
+	signCount  uint32
+	credential *Credential
+}
+
+// Challenge достает challenge из clientDataJSON, чтобы найти сохраненную церемонию
+func Challenge(clientDataJSON []byte) (string, error) {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil || cd.Challenge == "" {
+		return "", fmt.Errorf("%w: bad clientDataJSON", ErrInvalidCredential)
+	}
+	return cd.Challenge, nil
+}
+
+// VerifyRegistration проверяет ответ navigator.credentials.create()
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	value, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	att, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: bad attestationObject", ErrInvalidCredential)
+	}
+	rawAuthData, _ := att["authData"].([]byte)
+
+	data, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if data.credential == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrInvalidCredential)
+	}
+	if _, err := parseCOSEKey(data.credential.PublicKey); err != nil {
+		return nil, err
+	}
+	data.credential.SignCount = data.signCount
+	return data.credential, nil
+}
+
+// VerifyAssertion проверяет ответ navigator.credentials.get() ключом publicKey и возвращает новый счетчик подписей.
+// Счетчик, не выросший относительно storedCount, означает клон ключа (если аутентификатор его ведет).
+func (rp *RelyingParty) VerifyAssertion(challenge string, publicKey []byte, storedCount uint32, clientDataJSON, rawAuthData, signature []byte) (uint32, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	data, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	key, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if !key.verify(signed, signature) {
+		return 0, fmt.Errorf("%w: bad signature", ErrInvalidCredential)
+	}
+
+	if (data.signCount != 0 || storedCount != 0) && data.signCount <= storedCount {
+		return 0, fmt.Errorf("%w: sign counter did not increase", ErrInvalidCredential)
+	}
+	return data.signCount, nil
+}
+
+func (rp *RelyingParty) checkClientData(raw []byte, typ, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: bad clientDataJSON", ErrInvalidCredential)
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("%w: unexpected type %q", ErrInvalidCredential, cd.Type)
+	}
+	if cd.Challenge != challenge {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidCredential)
+	}
+	for _, origin := range rp.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unexpected origin %q", ErrInvalidCredential, cd.Origin)
+}
+
+// parseAuthenticatorData разбирает authenticatorData (WebAuthn §6.1); вход требует проверки пользователя (PIN, биометрия)
+func (rp *RelyingParty) parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidCredential)
+	}
+	data := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data.rpIDHash, rpIDHash[:]) {
+		return nil, fmt.Errorf("%w: rp id mismatch", ErrInvalidCredential)
+	}
+	if data.flags&flagUserPresent == 0 || data.flags&flagUserVerified == 0 {
+		return nil, fmt.Errorf("%w: user was not verified", ErrInvalidCredential)
+	}
+	if data.flags&flagAttested == 0 {
+		return data, nil
+	}
+
+	// aaguid (16) | длина id (2) | id | COSE_Key
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidCredential)
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	if idLen == 0 || idLen > 1023 || len(rest) < 18+idLen {
+		return nil, fmt.Errorf("%w: bad credential id", ErrInvalidCredential)
+	}
+	keyBytes := rest[18+idLen:]
+	_, keyLen, err := decodeCBOR(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	data.credential = &Credential{
+		AAGUID:    append([]byte(nil), rest[:16]...),
+		ID:        append([]byte(nil), rest[18:18+idLen]...),
+		PublicKey: append([]byte(nil), keyBytes[:keyLen]...),
+	}
+	return data, nil
+}
+
+// EncodeID / DecodeID — base64url без паддинга, как в JSON-представлении WebAuthn
+func EncodeID(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeID(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
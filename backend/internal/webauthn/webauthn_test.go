@@ -0,0 +1,361 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Записанная церемония ES256 с attestation "none": регистрация и вход с этим ключом.
+// RP ID localhost, origin http://localhost:3000.
+type fixture struct {
+	RPID         string `json:"rp_id"`
+	Origin       string `json:"origin"`
+	CredentialID string `json:"credential_id"`
+	Registration struct {
+		Challenge         string `json:"challenge"`
+		ClientDataJSON    string `json:"client_data_json"`
+		AttestationObject string `json:"attestation_object"`
+	} `json:"registration"`
+	Assertion struct {
+		Challenge         string `json:"challenge"`
+		ClientDataJSON    string `json:"client_data_json"`
+		AuthenticatorData string `json:"authenticator_data"`
+		Signature         string `json:"signature"`
+	} `json:"assertion"`
+}
+
+func loadFixture(t *testing.T) (*fixture, *RelyingParty) {
+	t.Helper()
+	raw, err := os.ReadFile("testdata/es256_none.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f fixture
+	if err := json.Unmarshal(raw, &f); err != nil {
+		t.Fatal(err)
+	}
+	return &f, &RelyingParty{ID: f.RPID, Name: "Test", Origins: []string{f.Origin}}
+}
+
+func decodeID(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := DecodeID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFixtureCeremony(t *testing.T) {
+	f, rp := loadFixture(t)
+
+	regClientData := decodeID(t, f.Registration.ClientDataJSON)
+	challenge, err := Challenge(regClientData)
+	if err != nil || challenge != f.Registration.Challenge {
+		t.Fatalf("Challenge = (%q, %v), want %q", challenge, err, f.Registration.Challenge)
+	}
+	cred, err := rp.VerifyRegistration(f.Registration.Challenge, regClientData, decodeID(t, f.Registration.AttestationObject))
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if EncodeID(cred.ID) != f.CredentialID {
+		t.Errorf("credential id = %s, want %s", EncodeID(cred.ID), f.CredentialID)
+	}
+	if cred.SignCount != 0 || !bytes.Equal(cred.AAGUID, make([]byte, 16)) {
+		t.Errorf("sign count = %d, aaguid = %x", cred.SignCount, cred.AAGUID)
+	}
+
+	count, err := rp.VerifyAssertion(
+		f.Assertion.Challenge,
+		cred.PublicKey,
+		cred.SignCount,
+		decodeID(t, f.Assertion.ClientDataJSON),
+		decodeID(t, f.Assertion.AuthenticatorData),
+		decodeID(t, f.Assertion.Signature),
+	)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("sign count = %d, want 1", count)
+	}
+}
+
+func TestFixtureRejected(t *testing.T) {
+	f, rp := loadFixture(t)
+	regClientData := decodeID(t, f.Registration.ClientDataJSON)
+	attestation := decodeID(t, f.Registration.AttestationObject)
+	cred, err := rp.VerifyRegistration(f.Registration.Challenge, regClientData, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getClientData := decodeID(t, f.Assertion.ClientDataJSON)
+	authData := decodeID(t, f.Assertion.AuthenticatorData)
+	sig := decodeID(t, f.Assertion.Signature)
+
+	otherRP := &RelyingParty{ID: "evil.example", Origins: rp.Origins}
+	otherOrigin := &RelyingParty{ID: rp.ID, Origins: []string{"https://evil.example"}}
+	tampered := append([]byte(nil), sig...)
+	tampered[len(tampered)-1] ^= 0x01
+
+	tests := []struct {
+		name   string
+		verify func() error
+	}{
+		{"registration rp id mismatch", func() error {
+			_, err := otherRP.VerifyRegistration(f.Registration.Challenge, regClientData, attestation)
+			return err
+		}},
+		{"registration challenge mismatch", func() error {
+			_, err := rp.VerifyRegistration(f.Assertion.Challenge, regClientData, attestation)
+			return err
+		}},
+		{"registration origin mismatch", func() error {
+			_, err := otherOrigin.VerifyRegistration(f.Registration.Challenge, regClientData, attestation)
+			return err
+		}},
+		{"registration with get client data", func() error {
+			_, err := rp.VerifyRegistration(f.Assertion.Challenge, getClientData, attestation)
+			return err
+		}},
+		{"truncated attestation object", func() error {
+			_, err := rp.VerifyRegistration(f.Registration.Challenge, regClientData, attestation[:len(attestation)/2])
+			return err
+		}},
+		{"assertion rp id mismatch", func() error {
+			_, err := otherRP.VerifyAssertion(f.Assertion.Challenge, cred.PublicKey, 0, getClientData, authData, sig)
+			return err
+		}},
+		{"assertion challenge mismatch", func() error {
+			_, err := rp.VerifyAssertion(f.Registration.Challenge, cred.PublicKey, 0, getClientData, authData, sig)
+			return err
+		}},
+		{"assertion with create client data", func() error {
+			_, err := rp.VerifyAssertion(f.Registration.Challenge, cred.PublicKey, 0, regClientData, authData, sig)
+			return err
+		}},
+		{"assertion tampered signature", func() error {
+			_, err := rp.VerifyAssertion(f.Assertion.Challenge, cred.PublicKey, 0, getClientData, authData, tampered)
+			return err
+		}},
+		{"assertion replayed counter", func() error {
+			_, err := rp.VerifyAssertion(f.Assertion.Challenge, cred.PublicKey, 1, getClientData, authData, sig)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.verify(); !errors.Is(err, ErrInvalidCredential) {
+				t.Fatalf("got %v, want ErrInvalidCredential", err)
+			}
+		})
+	}
+}
+
+// testAuthenticator — программный аутентификатор ES256 для проверок, которых нет в записи
+type testAuthenticator struct {
+	t    *testing.T
+	rp   *RelyingParty
+	priv *ecdsa.PrivateKey
+	id   []byte
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{
+		t:    t,
+		rp:   &RelyingParty{ID: "auth.example.com", Origins: []string{"https://auth.example.com"}},
+		priv: priv,
+		id:   []byte("credential-1"),
+	}
+}
+
+func (a *testAuthenticator) publicKey() []byte {
+	return cborEncode(coseEC2Key(&a.priv.PublicKey))
+}
+
+func (a *testAuthenticator) clientData(typ, challenge string) []byte {
+	return []byte(`{"type":"` + typ + `","challenge":"` + challenge + `","origin":"https://auth.example.com"}`)
+}
+
+func (a *testAuthenticator) authData(rpID string, flags byte, count uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, count)
+}
+
+func (a *testAuthenticator) attestation(flags byte, count uint32) []byte {
+	data := a.authData(a.rp.ID, flags|flagAttested, count)
+	data = append(data, make([]byte, 16)...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.id)))
+	data = append(data, a.id...)
+	data = append(data, a.publicKey()...)
+	return cborEncode(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": data,
+	})
+}
+
+// assert подписывает authenticatorData || SHA-256(clientDataJSON), как это делает get()
+func (a *testAuthenticator) assert(challenge string, authData []byte) (clientData, sig []byte) {
+	clientData = a.clientData("webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.priv, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return clientData, sig
+}
+
+func TestVerifyAssertionFlags(t *testing.T) {
+	a := newTestAuthenticator(t)
+	tests := []struct {
+		name  string
+		flags byte
+		ok    bool
+	}{
+		{"UP and UV", flagUserPresent | flagUserVerified, true},
+		{"UP, UV and backup flags", flagUserPresent | flagUserVerified | 0x08 | 0x10, true},
+		{"UP only", flagUserPresent, false},
+		{"UV only", flagUserVerified, false},
+		{"no flags", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authData := a.authData(a.rp.ID, tt.flags, 1)
+			clientData, sig := a.assert("challenge", authData)
+			_, err := a.rp.VerifyAssertion("challenge", a.publicKey(), 0, clientData, authData, sig)
+			if tt.ok && err != nil {
+				t.Fatalf("VerifyAssertion: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidCredential) {
+				t.Fatalf("got %v, want ErrInvalidCredential", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationFlags(t *testing.T) {
+	a := newTestAuthenticator(t)
+	clientData := a.clientData("webauthn.create", "challenge")
+
+	if _, err := a.rp.VerifyRegistration("challenge", clientData, a.attestation(flagUserPresent|flagUserVerified, 0)); err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if _, err := a.rp.VerifyRegistration("challenge", clientData, a.attestation(flagUserPresent, 0)); !errors.Is(err, ErrInvalidCredential) {
+		t.Fatalf("without UV: got %v, want ErrInvalidCredential", err)
+	}
+	// Без флага AT в authenticatorData нет ключа
+	noKey := cborEncode(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authData(a.rp.ID, flagUserPresent|flagUserVerified, 0),
+	})
+	if _, err := a.rp.VerifyRegistration("challenge", clientData, noKey); !errors.Is(err, ErrInvalidCredential) {
+		t.Fatalf("without attested data: got %v, want ErrInvalidCredential", err)
+	}
+}
+
+func TestVerifyAssertionRPIDHash(t *testing.T) {
+	a := newTestAuthenticator(t)
+	for _, rpID := range []string{"example.com", "evil.auth.example.com", "AUTH.EXAMPLE.COM", ""} {
+		authData := a.authData(rpID, flagUserPresent|flagUserVerified, 1)
+		clientData, sig := a.assert("challenge", authData)
+		if _, err := a.rp.VerifyAssertion("challenge", a.publicKey(), 0, clientData, authData, sig); !errors.Is(err, ErrInvalidCredential) {
+			t.Errorf("rp id %q: got %v, want ErrInvalidCredential", rpID, err)
+		}
+	}
+}
+
+func TestVerifyAssertionSignCounter(t *testing.T) {
+	a := newTestAuthenticator(t)
+	tests := []struct {
+		name   string
+		stored uint32
+		sent   uint32
+		ok     bool
+	}{
+		{"counter not supported", 0, 0, true},
+		{"first use", 0, 1, true},
+		{"increased", 5, 6, true},
+		{"jumped", 5, 100, true},
+		{"same value", 5, 5, false},
+		{"regressed", 5, 4, false},
+		{"reset to zero", 5, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authData := a.authData(a.rp.ID, flagUserPresent|flagUserVerified, tt.sent)
+			clientData, sig := a.assert("challenge", authData)
+			count, err := a.rp.VerifyAssertion("challenge", a.publicKey(), tt.stored, clientData, authData, sig)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidCredential) || !strings.Contains(err.Error(), "sign counter") {
+					t.Fatalf("got %v, want sign counter error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyAssertion: %v", err)
+			}
+			if count != tt.sent {
+				t.Errorf("count = %d, want %d", count, tt.sent)
+			}
+		})
+	}
+}
+
+func TestParseAuthenticatorDataMalformed(t *testing.T) {
+	a := newTestAuthenticator(t)
+	flags := byte(flagUserPresent | flagUserVerified | flagAttested)
+	header := a.authData(a.rp.ID, flags, 0)
+	withID := func(idLen uint16, id []byte) []byte {
+		data := append(append([]byte(nil), header...), make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, idLen)
+		return append(data, id...)
+	}
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"empty", nil},
+		{"too short", header[:36]},
+		{"attested data too short", append(append([]byte(nil), header...), make([]byte, 10)...)},
+		{"zero id length", append(withID(0, nil), a.publicKey()...)},
+		{"id longer than data", withID(64, []byte("short"))},
+		{"id too long", append(withID(1024, make([]byte, 1024)), a.publicKey()...)},
+		{"missing public key", withID(uint16(len(a.id)), a.id)},
+		{"truncated public key", append(withID(uint16(len(a.id)), a.id), a.publicKey()[:20]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.rp.parseAuthenticatorData(tt.raw); !errors.Is(err, ErrInvalidCredential) {
+				t.Fatalf("got %v, want ErrInvalidCredential", err)
+			}
+		})
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	for _, raw := range []string{"", "not json", `{"type":"webauthn.get"}`, `{"challenge":""}`} {
+		if _, err := Challenge([]byte(raw)); !errors.Is(err, ErrInvalidCredential) {
+			t.Errorf("Challenge(%q): got %v, want ErrInvalidCredential", raw, err)
+		}
+	}
+}
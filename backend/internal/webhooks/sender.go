@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Sender отправляет события POST-запросом с JSON {"event", "data"}. Если задан секрет,
+// тело подписывается HMAC-SHA256 (заголовок X-Webhook-Signature: sha256=<hex>),
+// чтобы получатель мог убедиться, что запрос пришел от нас
+type Sender struct {
+	url    string
+	secret []byte
+}
+
+func NewSender(url, secret string) *Sender {
+	return &Sender{url: url, secret: []byte(secret)}
+}
+
+func (s *Sender) Send(ctx context.Context, event string, data any) error {
+	body, err := json.Marshal(map[string]any{"event": event, "data": data})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", event)
+	if len(s.secret) > 0 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %d", event, resp.StatusCode)
+	}
+	return nil
+}
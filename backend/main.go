@@ -3,12 +3,17 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"user-service/internal/config"
 	"user-service/internal/database"
 	"user-service/internal/handlers"
+	"user-service/internal/keys"
 	"user-service/internal/middleware"
+	"user-service/internal/providers"
+	"user-service/internal/secrets"
 	"user-service/internal/services"
+	"user-service/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -26,6 +31,15 @@ func main() {
 	logger.SetLevel(logrus.InfoLevel)
 	logger.SetFormatter(&logrus.TextFormatter{})
 
+	// Ключи подписи JWT
+	keyring, err := keys.LoadFromConfig(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load JWT signing key: %v", err)
+	}
+	if keyring.SigningKey().Symmetric() {
+		logger.Warn("JWT_ALGORITHM is HS256: ID tokens issued to OIDC clients can't be verified without the shared secret, use RS256/ES256/EdDSA")
+	}
+
 	// Подключаемся к БД
 	db, err := database.ConnectFromEnv()
 	if err != nil {
@@ -37,15 +51,106 @@ func main() {
 	userRepo := database.NewUserRepo(db)
 	tokenRepo := database.NewTokenRepo(db)
 	characterRepo := database.NewCharacterRepo(db)
+	stateRepo := database.NewStateRepo(db)
+	identityRepo := database.NewIdentityRepo(db)
+	signingKeyRepo := database.NewSigningKeyRepo(db)
+	oauthClientRepo := database.NewOAuthClientRepo(db)
+	authorizationRepo := database.NewAuthorizationRepo(db)
+	revokedTokenRepo := database.NewRevokedTokenRepo(db)
+	apiTokenRepo := database.NewAPITokenRepo(db)
+	deviceCodeRepo := database.NewDeviceCodeRepo(db)
+	impersonationRepo := database.NewImpersonationRepo(db)
+	mfaRepo := database.NewMFARepo(db)
+	passkeyRepo := database.NewPasskeyRepo(db)
+
+	// Токены провайдеров, секреты TOTP и ключи подписи хранятся зашифрованными
+	var secretCipher *secrets.Cipher
+	if len(cfg.TokenEncryptionKey) > 0 {
+		if secretCipher, err = secrets.NewCipher(cfg.TokenEncryptionKey, cfg.TokenEncryptionPreviousKeys...); err != nil {
+			logger.Fatalf("Invalid TOKEN_ENCRYPTION_KEY: %v", err)
+		}
+		logger.WithField("key_id", secretCipher.KeyID()).Info("Secrets encryption enabled")
+	} else {
+		logger.Warn("TOKEN_ENCRYPTION_KEY is not set: Discord tokens are not stored, TOTP secrets and signing keys are stored unencrypted")
+	}
+
+	// Ключи из БД (ротация) поверх ключа из конфигурации
+	keyRotationService := services.NewKeyRotationService(signingKeyRepo, keyring, cfg.JWTKeyGracePeriod, logger).
+		WithCipher(secretCipher)
+	if err := keyRotationService.Reload(); err != nil {
+		logger.WithError(err).Warn("Failed to load signing keys from database, using configured key")
+	}
+	go keyRotationService.Run(30*time.Second, nil)
+
+	// Отозванные access token
+	revocations := services.NewRevocationList(revokedTokenRepo, logger)
+	if err := revocations.Reload(); err != nil {
+		logger.WithError(err).Warn("Failed to load revoked tokens")
+	}
+	go revocations.Run(10*time.Second, nil)
+
+	// Роли и права
+	roles := services.NewRoleCache(database.NewRoleRepo(db), logger)
+	if err := roles.Reload(); err != nil {
+		logger.WithError(err).Warn("Failed to load roles")
+	}
+	go roles.Run(30*time.Second, nil)
+
+	// Заблокированные пользователи
+	bans := services.NewBanList(database.NewBanRepo(db), logger)
+	if err := bans.Reload(); err != nil {
+		logger.WithError(err).Warn("Failed to load user bans")
+	}
+	go bans.Run(10*time.Second, nil)
+
+	// Отключенные OAuth-клиенты
+	disabledClients := services.NewDisabledClients(oauthClientRepo, logger)
+	if err := disabledClients.Reload(); err != nil {
+		logger.WithError(err).Warn("Failed to load disabled OAuth clients")
+	}
+	go disabledClients.Run(10*time.Second, nil)
+
+	// Журнал аудита: вход, смена ролей, изменения персонажей
+	audit := services.NewAuditLog(database.NewAuditRepo(db), logger)
+
+	// Уведомления о входе с нового устройства дополнительно уходят во внешний сервис
+	var loginWebhook services.WebhookSender
+	if cfg.LoginAlertWebhookURL != "" {
+		loginWebhook = webhooks.NewSender(cfg.LoginAlertWebhookURL, cfg.LoginAlertWebhookSecret)
+	}
 
 	// Создаем сервисы (с БД)
-	authService := services.NewAuthService(cfg, logger).WithRepositories(userRepo, tokenRepo)
-	characterService := services.NewCharacterService(characterRepo, logger)
+	authService := services.NewAuthService(cfg, logger).
+		WithKeyring(keyring).
+		WithRepositories(userRepo, tokenRepo).
+		WithStateRepository(stateRepo).
+		WithIdentityRepository(identityRepo).
+		WithOAuthRepositories(oauthClientRepo, authorizationRepo).
+		WithRevocationList(revocations).
+		WithRoles(roles).
+		WithBans(bans).
+		WithDisabledClients(disabledClients).
+		WithGuildDirectory(providers.NewDiscordGuilds(cfg.DiscordBotToken)).
+		WithAPITokenRepository(apiTokenRepo).
+		WithDeviceCodeRepository(deviceCodeRepo).
+		WithImpersonationRepository(impersonationRepo).
+		WithMFARepository(mfaRepo).
+		WithPasskeyRepository(passkeyRepo).
+		WithSecretCipher(secretCipher).
+		WithAuditLog(audit).
+		WithLoginHistory(database.NewLoginEventRepo(db), loginWebhook).
+		WithProviderGrants(database.NewProviderGrantRepo(db), providers.NewDiscord(cfg.Discord))
+	if secretCipher != nil && cfg.DiscordSyncInterval > 0 {
+		go authService.RunDiscordSync(time.Minute, nil)
+	}
+	characterService := services.NewCharacterService(characterRepo, logger).WithAuditLog(audit)
 
 	// Создаем обработчики
-	authHandler := handlers.NewAuthHandler(authService, logger, cfg)
+	authHandler := handlers.NewAuthHandler(authService, providers.NewRegistry(cfg), logger, cfg)
 	userHandler := handlers.NewUserHandler(authService, logger)
 	characterHandler := handlers.NewCharacterHandler(characterService, logger)
+	keyHandler := handlers.NewKeyHandler(keyRotationService, keyring, logger)
+	oidcHandler := handlers.NewOIDCHandler(authService, keyring, logger, cfg)
 
 	// Настраиваем Gin
 	if cfg.Environment == "production" {
@@ -54,37 +159,149 @@ func main() {
 	router := gin.New()
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
+	router.Use(middleware.RequestID())
 
 	// Middleware для CORS
 	router.Use(middleware.CORS(cfg.FrontendURL))
 
 	// Публичные маршруты
 	router.GET("/health", handlers.HealthCheck)
+	router.GET("/.well-known/jwks.json", handlers.JWKS(keyring))
+	router.GET("/providers", authHandler.Providers)
 	router.GET("/login", authHandler.Login)
+	router.GET("/login/:provider", authHandler.Login)
 	router.GET("/callback", authHandler.Callback)
+	router.GET("/callback/:provider", authHandler.Callback)
 	router.POST("/refresh", authHandler.Refresh)
+	router.POST("/logout", authHandler.Logout)
+	// Второй фактор после входа через провайдера
+	router.POST("/mfa/enroll", authHandler.EnrollMFAChallenge)
+	router.POST("/mfa/verify", authHandler.VerifyMFAChallenge)
+	// Вход по passkey без внешнего провайдера
+	router.POST("/passkeys/login/begin", authHandler.BeginPasskeyLogin)
+	router.POST("/passkeys/login/finish", authHandler.FinishPasskeyLogin)
+
+	authMiddleware := middleware.AuthMiddleware(keyring, revocations, bans, disabledClients, authService, roles, logger)
+
+	// OpenID Connect провайдер для внутренних приложений
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+	router.GET("/authorize", oidcHandler.Authorize)
+	router.POST("/authorize", oidcHandler.Authorize)
+	router.POST("/token", oidcHandler.Token)
+	router.POST("/introspect", oidcHandler.Introspect)
+	router.POST("/revoke", oidcHandler.Revoke)
+	router.POST("/device/code", oidcHandler.DeviceAuthorization)
+	// Запросы администратора от имени пользователя попадают в журнал
+	impersonationAudit := middleware.ImpersonationAudit(authService, logger)
+	router.GET("/userinfo", authMiddleware, impersonationAudit, oidcHandler.UserInfo)
+	router.POST("/userinfo", authMiddleware, impersonationAudit, oidcHandler.UserInfo)
 
-	// Защищенные маршруты
+	// Защищенные маршруты (сессия фронтенда или личный API-ключ с нужным scope)
 	protected := router.Group("/")
-	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret, logger))
+	protected.Use(authMiddleware, impersonationAudit, middleware.FirstPartyOnly())
 	{
-		protected.GET("/me", userHandler.GetMe)
+		protected.GET("/me", middleware.RequireScope(services.ScopeProfile), userHandler.GetMe)
+		protected.DELETE("/me/impersonation", userHandler.EndCurrentImpersonation)
 
 		// Character routes
-		protected.POST("/characters", characterHandler.CreateCharacter)
-		protected.GET("/characters", characterHandler.GetUserCharacters)
-		protected.GET("/characters/:id", characterHandler.GetCharacter)
-		protected.PUT("/characters/:id", characterHandler.UpdateCharacter)
-		protected.DELETE("/characters/:id", characterHandler.DeleteCharacter)
-		protected.GET("/servers/:serverId/characters", characterHandler.GetCharactersByServer)
+		readCharacters := middleware.RequireScope(services.ScopeCharactersRead)
+		writeCharacters := middleware.RequireScope(services.ScopeCharactersWrite)
+		protected.POST("/characters", writeCharacters, characterHandler.CreateCharacter)
+		protected.GET("/characters", readCharacters, characterHandler.GetUserCharacters)
+		protected.GET("/characters/:id", readCharacters, characterHandler.GetCharacter)
+		protected.PUT("/characters/:id", writeCharacters, characterHandler.UpdateCharacter)
+		protected.DELETE("/characters/:id", writeCharacters, characterHandler.DeleteCharacter)
+		protected.GET("/servers/:serverId/characters", readCharacters, characterHandler.GetCharactersByServer)
 	}
 
-	// Админские маршруты
+	// Управление аккаунтом — только из сессии, не по API-ключу
+	account := protected.Group("/")
+	account.Use(middleware.RejectAPITokens(), middleware.RejectImpersonation(), middleware.RequireScope(services.ScopeAccount))
+	{
+		account.GET("/me/identities", authHandler.GetIdentities)
+		account.POST("/me/identities/:provider", authHandler.StartLink)
+		account.DELETE("/me/identities/:id", authHandler.UnlinkIdentity)
+		account.GET("/me/sessions", userHandler.GetSessions)
+		account.DELETE("/me/sessions", userHandler.RevokeAllSessions)
+		account.DELETE("/me/sessions/:id", userHandler.RevokeSession)
+		account.GET("/me/logins", userHandler.GetLoginHistory)
+		account.GET("/me/login-alerts", userHandler.GetLoginAlerts)
+		account.POST("/me/login-alerts/read", userHandler.MarkLoginAlertsRead)
+		account.GET("/me/tokens", userHandler.GetAPITokens)
+		account.POST("/me/tokens", userHandler.CreateAPIToken)
+		account.DELETE("/me/tokens/:id", userHandler.RevokeAPIToken)
+		account.GET("/me/mfa", userHandler.GetMFAStatus)
+		account.POST("/me/mfa", userHandler.BeginMFAEnrollment)
+		account.POST("/me/mfa/confirm", userHandler.ConfirmMFAEnrollment)
+		account.DELETE("/me/mfa", userHandler.DisableMFA)
+		account.POST("/me/mfa/backup-codes", userHandler.RegenerateBackupCodes)
+		account.GET("/me/passkeys", userHandler.GetPasskeys)
+		account.POST("/me/passkeys/register/begin", userHandler.BeginPasskeyRegistration)
+		account.POST("/me/passkeys/register/finish", userHandler.FinishPasskeyRegistration)
+		account.DELETE("/me/passkeys/:id", userHandler.DeletePasskey)
+		account.GET("/authorize/requests/:id", oidcHandler.GetAuthorizationRequest)
+		account.POST("/authorize/requests/:id/approve", oidcHandler.ApproveAuthorization)
+		account.POST("/authorize/requests/:id/deny", oidcHandler.DenyAuthorization)
+		account.GET("/device/requests/:code", oidcHandler.GetDeviceRequest)
+		account.POST("/device/requests/:code/approve", oidcHandler.ApproveDeviceRequest)
+		account.POST("/device/requests/:code/deny", oidcHandler.DenyDeviceRequest)
+	}
+
+	// Сервисные маршруты (токены client_credentials): работа с персонажами указанного пользователя
+	service := router.Group("/service/users/:userId")
+	service.Use(authMiddleware, middleware.UserFromPath("userId"))
+	{
+		service.GET("/characters", middleware.RequireScope(services.ScopeCharactersRead), characterHandler.GetUserCharacters)
+		service.GET("/characters/:id", middleware.RequireScope(services.ScopeCharactersRead), characterHandler.GetCharacter)
+		service.GET("/servers/:serverId/characters", middleware.RequireScope(services.ScopeCharactersRead), characterHandler.GetCharactersByServer)
+		service.PUT("/characters/:id", middleware.RequireScope(services.ScopeCharactersWrite), characterHandler.UpdateCharacter)
+	}
+
+	// Админские маршруты: право роли и scope токена на конкретный раздел
 	admin := protected.Group("/admin")
-	admin.Use(middleware.AdminMiddleware())
+	admin.Use(middleware.RejectAPITokens(), middleware.RejectImpersonation())
 	{
-		admin.GET("/users", userHandler.GetUsers)
-		admin.POST("/users/:id/role", userHandler.UpdateUserRole)
+		adminUsers := middleware.RequireScope(services.ScopeAdminUsers)
+		admin.GET("/users", adminUsers, middleware.RequirePermission(services.PermissionUsersRead), userHandler.GetUsers)
+		admin.POST("/users/:id/role", adminUsers, middleware.RequirePermission(services.PermissionUsersManage), userHandler.UpdateUserRole)
+		admin.DELETE("/users/:id/sessions", adminUsers, middleware.RequirePermission(services.PermissionSessionsRevoke), userHandler.RevokeUserSessions)
+		admin.DELETE("/users/:id/mfa", adminUsers, middleware.RequirePermission(services.PermissionUsersManage), userHandler.ResetMFA)
+
+		banUsers := middleware.RequirePermission(services.PermissionUsersBan)
+		admin.POST("/users/:id/ban", adminUsers, banUsers, userHandler.BanUser)
+		admin.DELETE("/users/:id/ban", adminUsers, banUsers, userHandler.LiftBan)
+		admin.GET("/users/:id/bans", adminUsers, banUsers, userHandler.GetUserBans)
+		admin.GET("/bans", adminUsers, banUsers, userHandler.ListBans)
+
+		impersonate := middleware.RequirePermission(services.PermissionUsersImpersonate)
+		admin.POST("/users/:id/impersonate", adminUsers, impersonate, userHandler.Impersonate)
+		admin.GET("/impersonations", adminUsers, impersonate, userHandler.ListImpersonations)
+		admin.GET("/impersonations/:id/requests", adminUsers, impersonate, userHandler.GetImpersonationRequests)
+		admin.POST("/impersonations/:id/end", adminUsers, impersonate, userHandler.EndImpersonation)
+
+		adminSystem := middleware.RequireScope(services.ScopeAdminSystem)
+		manageKeys := middleware.RequirePermission(services.PermissionKeysManage)
+		admin.GET("/keys", adminSystem, manageKeys, keyHandler.ListKeys)
+		admin.POST("/keys", adminSystem, manageKeys, keyHandler.GenerateKey)
+		admin.POST("/keys/:kid/promote", adminSystem, manageKeys, keyHandler.PromoteKey)
+		admin.POST("/keys/:kid/retire", adminSystem, manageKeys, keyHandler.RetireKey)
+
+		manageClients := middleware.RequirePermission(services.PermissionClientsManage)
+		admin.GET("/clients", adminSystem, manageClients, oidcHandler.ListClients)
+		admin.POST("/clients", adminSystem, manageClients, oidcHandler.CreateClient)
+		admin.POST("/clients/:id/rotate-secret", adminSystem, manageClients, oidcHandler.RotateClientSecret)
+		admin.POST("/clients/:id/disable", adminSystem, manageClients, oidcHandler.DisableClient)
+		admin.POST("/clients/:id/enable", adminSystem, manageClients, oidcHandler.EnableClient)
+
+		manageRoles := middleware.RequirePermission(services.PermissionRolesManage)
+		admin.GET("/roles", adminSystem, manageRoles, userHandler.ListRoles)
+		admin.POST("/roles", adminSystem, manageRoles, userHandler.CreateRole)
+		admin.PUT("/roles/:name", adminSystem, manageRoles, userHandler.UpdateRole)
+		admin.DELETE("/roles/:name", adminSystem, manageRoles, userHandler.DeleteRole)
+		admin.PUT("/roles/:name/mfa", adminSystem, manageRoles, userHandler.SetRoleRequireMFA)
+		admin.GET("/permissions", adminSystem, manageRoles, userHandler.ListPermissions)
+
+		admin.GET("/audit", adminSystem, middleware.RequirePermission(services.PermissionAuditRead), userHandler.ListAuditEvents)
 	}
 
 	// Запускаем сервер
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS oauth_states (
+    id SERIAL PRIMARY KEY,
+    state VARCHAR(128) NOT NULL UNIQUE,
+    code_verifier VARCHAR(128) NOT NULL,
+    return_to TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires_at ON oauth_states (expires_at);
@@ -0,0 +1,7 @@
+ALTER TABLE oauth_states DROP COLUMN IF EXISTS provider;
+
+UPDATE users u SET discord_id = i.subject
+FROM user_identities i
+WHERE i.user_id = u.id AND i.provider = 'discord' AND u.discord_id IS NULL;
+
+DROP TABLE IF EXISTS user_identities;
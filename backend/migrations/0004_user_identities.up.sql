@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    avatar TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+
+-- Переносим существующих пользователей Discord
+INSERT INTO user_identities (user_id, provider, subject, username, avatar, created_at)
+SELECT id, 'discord', discord_id, username, COALESCE(avatar, ''), created_at
+FROM users
+WHERE discord_id IS NOT NULL AND discord_id <> ''
+ON CONFLICT (provider, subject) DO NOTHING;
+
+-- discord_id остается только для совместимости и заполняется для входов через Discord
+ALTER TABLE users ALTER COLUMN discord_id DROP NOT NULL;
+
+ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS provider VARCHAR(32) NOT NULL DEFAULT 'discord';
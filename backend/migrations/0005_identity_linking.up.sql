@@ -0,0 +1 @@
+ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS link_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,8 @@
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id UUID;
+UPDATE refresh_tokens SET family_id = gen_random_uuid() WHERE family_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMP;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
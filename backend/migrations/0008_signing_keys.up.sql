@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid VARCHAR(128) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    -- pending: опубликован в JWKS, но еще не подписывает
+    -- active: текущий ключ подписи (не больше одного)
+    -- retiring: больше не подписывает, но принимается до retire_after
+    -- retired: не используется
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    activated_at TIMESTAMP,
+    retire_after TIMESTAMP,
+    retired_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_single_active ON signing_keys (status) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS signing_key_events (
+    id SERIAL PRIMARY KEY,
+    kid VARCHAR(128) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_key_events_kid ON signing_key_events (kid);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_authorization_requests;
+DROP TABLE IF EXISTS oauth_clients;
@@ -0,0 +1,43 @@
+-- Приложения, которые входят через нас как через OIDC-провайдер (вики, дашборд бота, ...)
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    -- SHA-256 от client_secret; NULL у публичных клиентов (SPA, нативные), им обязателен PKCE
+    secret_hash VARCHAR(64),
+    -- разделены пробелами
+    redirect_uris TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT 'openid profile email',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Запрос /authorize, ожидающий подтверждения пользователем на фронтенде
+CREATE TABLE IF NOT EXISTS oauth_authorization_requests (
+    id VARCHAR(64) PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    state TEXT NOT NULL DEFAULT '',
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_authorization_requests_expires_at ON oauth_authorization_requests (expires_at);
+
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL DEFAULT '',
+    auth_time TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes (expires_at);
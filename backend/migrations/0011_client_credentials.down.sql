@@ -0,0 +1,2 @@
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS disabled_at;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS grant_types;
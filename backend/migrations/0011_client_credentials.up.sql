@@ -0,0 +1,3 @@
+-- authorization_code — приложения с входом пользователя, client_credentials — сервисы (бот, синхронизация)
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS grant_types TEXT NOT NULL DEFAULT 'authorization_code';
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
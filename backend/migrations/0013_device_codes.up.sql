@@ -0,0 +1,17 @@
+-- OAuth 2.0 device authorization grant (RFC 8628) для CLI и оверлея в игре
+CREATE TABLE IF NOT EXISTS device_codes (
+    id SERIAL PRIMARY KEY,
+    device_code_hash VARCHAR(64) NOT NULL UNIQUE,
+    -- без дефиса, в верхнем регистре
+    user_code VARCHAR(16) NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    -- pending -> approved | denied; approved -> consumed после выдачи токенов
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    interval_seconds INTEGER NOT NULL DEFAULT 5,
+    last_polled_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_codes_expires_at ON device_codes (expires_at);
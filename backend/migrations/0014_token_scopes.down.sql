@@ -0,0 +1,3 @@
+ALTER TABLE device_codes DROP COLUMN IF EXISTS scope;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS scope;
+ALTER TABLE oauth_states DROP COLUMN IF EXISTS scope;
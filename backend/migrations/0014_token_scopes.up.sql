@@ -0,0 +1,4 @@
+-- Scope, запрошенные при входе: пусто — все, что разрешает роль пользователя
+ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
+ALTER TABLE device_codes ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
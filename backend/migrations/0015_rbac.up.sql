@@ -0,0 +1,50 @@
+-- Роли и права доступа; проверки в коде идут по правам, а не по имени роли
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    -- встроенные роли нельзя удалить
+    builtin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Список прав задается миграциями: на каждое право есть проверка в коде
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description, builtin) VALUES
+    ('user', 'Обычный пользователь', TRUE),
+    ('moderator', 'Модератор', TRUE),
+    ('admin', 'Администратор, все права', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('users.read', 'Просмотр списка пользователей'),
+    ('users.manage', 'Назначение ролей пользователям'),
+    ('sessions.revoke', 'Завершение сессий других пользователей'),
+    ('roles.manage', 'Управление ролями и их правами'),
+    ('keys.manage', 'Ключи подписи JWT'),
+    ('clients.manage', 'OAuth-клиенты')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('moderator', 'users.read'),
+    ('moderator', 'sessions.revoke')
+ON CONFLICT DO NOTHING;
+
+-- Роли, назначенные до появления таблицы, тоже должны существовать
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users
+ON CONFLICT (name) DO NOTHING;
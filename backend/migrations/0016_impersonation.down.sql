@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS impersonation_requests;
+DROP TABLE IF EXISTS impersonations;
+DELETE FROM permissions WHERE name = 'users.impersonate';
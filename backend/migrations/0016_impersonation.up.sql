@@ -0,0 +1,32 @@
+-- Вход администратора от имени пользователя; журнал не зависит от удаления пользователей
+INSERT INTO permissions (name, description) VALUES ('users.impersonate', 'Вход от имени пользователя')
+ON CONFLICT (name) DO NOTHING;
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'users.impersonate')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS impersonations (
+    -- совпадает с jti выданного токена
+    id VARCHAR(36) PRIMARY KEY,
+    actor_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonations_created_at ON impersonations (created_at DESC);
+
+-- Каждый запрос, сделанный с токеном impersonation
+CREATE TABLE IF NOT EXISTS impersonation_requests (
+    id BIGSERIAL PRIMARY KEY,
+    impersonation_id VARCHAR(36) NOT NULL REFERENCES impersonations(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_requests_impersonation_id ON impersonation_requests (impersonation_id, id);
@@ -0,0 +1,4 @@
+ALTER TABLE roles DROP COLUMN IF EXISTS require_mfa;
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS mfa_backup_codes;
+DROP TABLE IF EXISTS user_mfa;
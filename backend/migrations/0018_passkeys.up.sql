@@ -0,0 +1,27 @@
+-- Passkey (WebAuthn): второй способ входа, не зависящий от Discord
+CREATE TABLE IF NOT EXISTS passkeys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- base64url, как его присылает браузер
+    credential_id VARCHAR(1400) NOT NULL UNIQUE,
+    -- COSE_Key
+    public_key BYTEA NOT NULL,
+    -- счетчик подписей аутентификатора: если он не растет, ключ скопирован
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid VARCHAR(36) NOT NULL DEFAULT '',
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_passkeys_user_id ON passkeys (user_id);
+
+-- Незавершенные регистрации и входы; user_id пуст у входа (ключ сам скажет, чей он)
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    challenge_hash VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(20) NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
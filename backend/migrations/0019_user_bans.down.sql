@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_bans;
+DELETE FROM permissions WHERE name = 'users.ban';
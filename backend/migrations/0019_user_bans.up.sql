@@ -0,0 +1,21 @@
+-- Блокировка пользователей: временная (suspension) или бессрочная (ban); expires_at пуст — до снятия
+INSERT INTO permissions (name, description) VALUES ('users.ban', 'Блокировка пользователей')
+ON CONFLICT (name) DO NOTHING;
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'users.ban'), ('moderator', 'users.ban')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_bans (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    banned_by INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP,
+    lifted_at TIMESTAMP,
+    lifted_by INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_bans_user_id ON user_bans (user_id);
+-- У пользователя не больше одной действующей блокировки
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_bans_active ON user_bans (user_id) WHERE lifted_at IS NULL;
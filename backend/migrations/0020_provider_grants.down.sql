@@ -0,0 +1,2 @@
+ALTER TABLE user_identities DROP COLUMN IF EXISTS grant_revoked_at;
+DROP TABLE IF EXISTS provider_grants;
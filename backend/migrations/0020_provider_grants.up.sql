@@ -0,0 +1,17 @@
+-- OAuth-токены провайдера (пока только Discord) для фонового обновления профиля и ролей.
+-- Токены зашифрованы ключом TOKEN_ENCRYPTION_KEY
+CREATE TABLE IF NOT EXISTS provider_grants (
+    identity_id INTEGER PRIMARY KEY REFERENCES user_identities(id) ON DELETE CASCADE,
+    access_token BYTEA NOT NULL,
+    refresh_token BYTEA NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    synced_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_provider_grants_synced_at ON provider_grants (synced_at);
+
+-- Пользователь отозвал доступ приложения у провайдера; сбрасывается при следующем входе
+ALTER TABLE user_identities ADD COLUMN IF NOT EXISTS grant_revoked_at TIMESTAMP;
@@ -0,0 +1,8 @@
+-- Открытые токены из хешей не восстановить: после отката все сессии отзываются
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token VARCHAR(255);
+UPDATE refresh_tokens SET token = token_hash, revoked_at = COALESCE(revoked_at, NOW());
+ALTER TABLE refresh_tokens ALTER COLUMN token SET NOT NULL;
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_token_key UNIQUE (token);
+
+DROP INDEX IF EXISTS idx_refresh_tokens_token_hash;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token_hash;
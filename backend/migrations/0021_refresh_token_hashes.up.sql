@@ -0,0 +1,8 @@
+-- Refresh token хранится только как SHA-256 (hex): дамп БД больше не дает живых сессий.
+-- Хеш существующих токенов совпадает с тем, что считает сервис, поэтому сессии не теряются
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
+UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex') WHERE token_hash IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token;
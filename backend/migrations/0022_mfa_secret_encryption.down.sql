@@ -0,0 +1,3 @@
+-- Зашифрованные секреты прежний код прочитать не сможет: такой второй фактор придется подключить заново
+DELETE FROM user_mfa WHERE secret LIKE 'enc:%';
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE VARCHAR(64);
@@ -0,0 +1,3 @@
+-- Секрет TOTP шифруется (envelope, TOKEN_ENCRYPTION_KEY) и в открытый VARCHAR(64) не помещается.
+-- Записанные раньше секреты остаются открытыми и перешифровываются при следующем успешном коде
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE TEXT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DELETE FROM permissions WHERE name = 'audit.read';
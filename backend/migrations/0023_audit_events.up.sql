@@ -0,0 +1,39 @@
+-- Журнал аудита: кто (actor), что (action) и с чем (target) сделал, состояние до и после.
+-- actor и target — строки вида "user:5", "character:<uuid>", "client:<id>", "system"; внешних
+-- ключей нет, чтобы история переживала удаление пользователей и персонажей
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    target VARCHAR(100) NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action, id);
+
+-- Только добавление: изменить или удалить запись нельзя даже владельцу таблицы без отключения триггера
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_change ON audit_events;
+CREATE TRIGGER audit_events_no_change BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+INSERT INTO permissions (name, description) VALUES ('audit.read', 'Просмотр и выгрузка журнала аудита')
+ON CONFLICT (name) DO NOTHING;
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'audit.read')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,34 @@
+-- История входов: успешные и неудачные входы и обновления сессии (refresh) пользователя.
+-- device — браузер и ОС по User-Agent, fingerprint — хеш User-Agent без номеров версий,
+-- ip_range — подсеть /24 (IPv6 — /48); по ним определяется вход с нового устройства или из новой сети
+CREATE TABLE IF NOT EXISTS login_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(50) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    ip_range VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    device VARCHAR(100) NOT NULL DEFAULT '',
+    fingerprint VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_user_id ON login_events (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_login_events_user_created_at ON login_events (user_id, created_at);
+
+-- Уведомления о входе с нового устройства или из новой сети
+CREATE TABLE IF NOT EXISTS login_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_device BOOLEAN NOT NULL,
+    new_ip_range BOOLEAN NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    device VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_alerts_user_id ON login_alerts (user_id, id);
@@ -0,0 +1,2 @@
+-- Прежний код scope клиента для device flow не смотрит, поэтому откатывать нечего
+SELECT 1;
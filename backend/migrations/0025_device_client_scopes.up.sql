@@ -0,0 +1,13 @@
+-- Клиентам device flow разрешаются scope пользователя, кроме account: без явного scope
+-- устройство получает именно их, а account и admin:* нужно добавить клиенту и запросить отдельно
+UPDATE oauth_clients c SET scopes = (
+    SELECT string_agg(scope, ' ' ORDER BY position)
+    FROM (
+        SELECT scope, MIN(position) AS position
+        FROM unnest(string_to_array(c.scopes || ' profile characters:read characters:write', ' '))
+            WITH ORDINALITY AS t(scope, position)
+        WHERE scope <> ''
+        GROUP BY scope
+    ) merged
+)
+WHERE ' ' || c.grant_types || ' ' LIKE '% urn:ietf:params:oauth:grant-type:device_code %';
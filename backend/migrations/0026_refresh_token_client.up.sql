@@ -0,0 +1,3 @@
+-- OAuth-клиент, которому выдана сессия (device flow): после отключения клиента
+-- ее refresh token не обменивается. Пусто — вход через фронтенд
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS client_id TEXT NOT NULL DEFAULT '';
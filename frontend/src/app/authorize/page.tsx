@@ -0,0 +1,132 @@
+"use client";
+
+import { Suspense, useEffect, useState } from "react";
+import { useSearchParams } from "next/navigation";
+import styled from "@emotion/styled";
+import { PageContainer, Container } from "@/shared/ui/container";
+import { Card, CardTitle, CardContent } from "@/shared/ui/card";
+import { Button } from "@/shared/ui/button";
+import { ErrorMessage } from "@/shared/ui/input";
+import { ScopeList } from "@/shared/components/scopeList";
+import { api } from "@/shared/lib/api";
+import type {
+  AuthorizationDecision,
+  AuthorizationRequest,
+} from "@/shared/types/auth";
+
+const ConsentCard = styled(Card)`
+  max-width: 480px;
+  margin: 60px auto;
+`;
+
+const RedirectHost = styled.div`
+  margin-top: 8px;
+  font-size: 14px;
+  color: #8b95a5;
+`;
+
+const Actions = styled.div`
+  display: flex;
+  gap: 12px;
+`;
+
+// Куда приложение вернет пользователя — показываем только хост, чтобы его было легко сверить
+function redirectHost(redirectUri: string): string {
+  try {
+    return new URL(redirectUri).host || redirectUri;
+  } catch {
+    return redirectUri;
+  }
+}
+
+function AuthorizePageInner() {
+  const searchParams = useSearchParams();
+  const requestId = searchParams.get("request_id") ?? "";
+
+  const [request, setRequest] = useState<AuthorizationRequest | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+
+  useEffect(() => {
+    if (!requestId) {
+      setError("Не указан запрос на вход");
+      return;
+    }
+    api
+      .get<AuthorizationRequest>(
+        `/authorize/requests/${encodeURIComponent(requestId)}`,
+        { requiresAuth: true }
+      )
+      .then(setRequest)
+      .catch((err: Error) => setError(err.message));
+  }, [requestId]);
+
+  const decide = async (decision: "approve" | "deny") => {
+    setSubmitting(true);
+    setError(null);
+    try {
+      const { redirectUrl } = await api.post<AuthorizationDecision>(
+        `/authorize/requests/${encodeURIComponent(requestId)}/${decision}`,
+        undefined,
+        { requiresAuth: true }
+      );
+      // redirect_uri проверен бэкендом при /authorize: уходим обратно в приложение
+      window.location.href = redirectUrl;
+    } catch (err) {
+      setError((err as Error).message);
+      setSubmitting(false);
+    }
+  };
+
+  return (
+    <ConsentCard>
+      <CardTitle>Вход в приложение</CardTitle>
+      <CardContent>
+        {request ? (
+          <>
+            <strong>{request.clientName}</strong> просит войти через ваш
+            аккаунт.
+            <RedirectHost>
+              После подтверждения вы вернетесь на{" "}
+              {redirectHost(request.redirectUri)}
+            </RedirectHost>
+            <p>Приложение получит:</p>
+            <ScopeList scopes={request.scopes} />
+            <Actions>
+              <Button
+                fullWidth
+                disabled={submitting}
+                onClick={() => void decide("approve")}
+              >
+                Разрешить
+              </Button>
+              <Button
+                variant="secondary"
+                fullWidth
+                disabled={submitting}
+                onClick={() => void decide("deny")}
+              >
+                Отклонить
+              </Button>
+            </Actions>
+          </>
+        ) : (
+          !error && "Загрузка…"
+        )}
+        {error && <ErrorMessage>{error}</ErrorMessage>}
+      </CardContent>
+    </ConsentCard>
+  );
+}
+
+export default function AuthorizePage() {
+  return (
+    <PageContainer>
+      <Container>
+        <Suspense fallback={null}>
+          <AuthorizePageInner />
+        </Suspense>
+      </Container>
+    </PageContainer>
+  );
+}
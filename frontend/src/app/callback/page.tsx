@@ -4,7 +4,8 @@ import { Suspense, useEffect } from "react";
 import { useRouter, useSearchParams } from "next/navigation";
 import styled from "@emotion/styled";
 import { PageContainer, Container } from "@/shared/ui/container";
-import Cookies from "js-cookie";
+import { saveTokens } from "@/features/auth/hooks";
+import { safeReturnTo } from "@/shared/lib/api";
 
 const LoadingCard = styled.div`
   background: white;
@@ -57,10 +58,9 @@ function CallbackPageInner() {
 
     if (accessToken && refreshToken) {
       // Сохраняем токены в cookies
-      Cookies.set("accessToken", accessToken, { expires: 7 });
-      Cookies.set("refreshToken", refreshToken, { expires: 30 });
-      // Перенаправляем на список серверов
-      router.push("/servers");
+      saveTokens(accessToken, refreshToken);
+      // Возвращаем туда, откуда уходили на вход, иначе на список серверов
+      router.push(safeReturnTo(searchParams.get("return_to")) ?? "/servers");
     } else {
       router.push("/login?error=missing_tokens");
     }
@@ -0,0 +1,191 @@
+"use client";
+
+import { FormEvent, Suspense, useEffect, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import styled from "@emotion/styled";
+import { PageContainer, Container } from "@/shared/ui/container";
+import { Card, CardTitle, CardContent } from "@/shared/ui/card";
+import { Button } from "@/shared/ui/button";
+import { Input, ErrorMessage } from "@/shared/ui/input";
+import { ScopeList } from "@/shared/components/scopeList";
+import { api } from "@/shared/lib/api";
+import type { DeviceRequest } from "@/shared/types/auth";
+
+const DeviceCard = styled(Card)`
+  max-width: 480px;
+  margin: 60px auto;
+`;
+
+const Form = styled.form`
+  display: flex;
+  flex-direction: column;
+  gap: 16px;
+  margin-top: 16px;
+`;
+
+const Code = styled.div`
+  margin: 12px 0;
+  font-family: monospace;
+  font-size: 24px;
+  letter-spacing: 0.15em;
+  text-align: center;
+  color: #f1f3f5;
+`;
+
+const Actions = styled.div`
+  display: flex;
+  gap: 12px;
+`;
+
+type Decision = "approve" | "deny";
+
+function DevicePageInner() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  // verification_uri_complete сразу содержит код, на verification_uri его вводят вручную
+  const userCode = searchParams.get("user_code") ?? "";
+
+  const [input, setInput] = useState("");
+  const [request, setRequest] = useState<DeviceRequest | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+  const [decision, setDecision] = useState<Decision | null>(null);
+
+  useEffect(() => {
+    setRequest(null);
+    setError(null);
+    if (!userCode) {
+      return;
+    }
+    api
+      .get<DeviceRequest>(`/device/requests/${encodeURIComponent(userCode)}`, {
+        requiresAuth: true,
+      })
+      .then(setRequest)
+      .catch((err: Error) => setError(err.message));
+  }, [userCode]);
+
+  const handleCode = (e: FormEvent) => {
+    e.preventDefault();
+    router.push(`/device?user_code=${encodeURIComponent(input.trim())}`);
+  };
+
+  const decide = async (next: Decision) => {
+    if (!request) {
+      return;
+    }
+    setSubmitting(true);
+    setError(null);
+    try {
+      await api.post(
+        `/device/requests/${encodeURIComponent(request.userCode)}/${next}`,
+        undefined,
+        { requiresAuth: true }
+      );
+      setDecision(next);
+    } catch (err) {
+      setError((err as Error).message);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  if (decision) {
+    return (
+      <DeviceCard>
+        <CardTitle>
+          {decision === "approve" ? "Устройство подключено" : "Вход отклонен"}
+        </CardTitle>
+        <CardContent>
+          {decision === "approve"
+            ? "Вернитесь к устройству: вход завершится в течение нескольких секунд."
+            : "Устройство не получит доступ к аккаунту. Эту страницу можно закрыть."}
+        </CardContent>
+      </DeviceCard>
+    );
+  }
+
+  if (!userCode) {
+    return (
+      <DeviceCard>
+        <CardTitle>Вход на устройстве</CardTitle>
+        <CardContent>
+          Введите код, который показывает устройство.
+          <Form onSubmit={handleCode}>
+            <Input
+              value={input}
+              onChange={(e) => setInput(e.target.value.toUpperCase())}
+              autoComplete="off"
+              placeholder="ABCD-EFGH"
+              autoFocus
+              required
+            />
+            <Button type="submit" fullWidth disabled={!input.trim()}>
+              Продолжить
+            </Button>
+          </Form>
+        </CardContent>
+      </DeviceCard>
+    );
+  }
+
+  return (
+    <DeviceCard>
+      <CardTitle>Вход на устройстве</CardTitle>
+      <CardContent>
+        {request ? (
+          <>
+            Приложение <strong>{request.clientName}</strong> просит доступ к
+            вашему аккаунту. Убедитесь, что устройство показывает тот же код:
+            <Code>{request.userCode}</Code>
+            Приложение получит:
+            <ScopeList scopes={request.scopes} />
+            <Actions>
+              <Button
+                fullWidth
+                disabled={submitting}
+                onClick={() => void decide("approve")}
+              >
+                Разрешить
+              </Button>
+              <Button
+                variant="secondary"
+                fullWidth
+                disabled={submitting}
+                onClick={() => void decide("deny")}
+              >
+                Отклонить
+              </Button>
+            </Actions>
+          </>
+        ) : (
+          !error && "Загрузка…"
+        )}
+        {error && (
+          <>
+            <ErrorMessage>{error}</ErrorMessage>
+            <Button
+              variant="secondary"
+              fullWidth
+              onClick={() => router.push("/device")}
+            >
+              Ввести другой код
+            </Button>
+          </>
+        )}
+      </CardContent>
+    </DeviceCard>
+  );
+}
+
+export default function DevicePage() {
+  return (
+    <PageContainer>
+      <Container>
+        <Suspense fallback={null}>
+          <DevicePageInner />
+        </Suspense>
+      </Container>
+    </PageContainer>
+  );
+}
@@ -0,0 +1,173 @@
+"use client";
+
+import { FormEvent, Suspense, useEffect, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import styled from "@emotion/styled";
+import { PageContainer, Container } from "@/shared/ui/container";
+import { Card, CardTitle, CardContent } from "@/shared/ui/card";
+import { Button } from "@/shared/ui/button";
+import { Input, ErrorMessage } from "@/shared/ui/input";
+import { api, safeReturnTo } from "@/shared/lib/api";
+import { saveTokens } from "@/features/auth/hooks";
+import type { MFAEnrollment, MFALoginResponse } from "@/shared/types/auth";
+
+const MFACard = styled(Card)`
+  max-width: 480px;
+  margin: 60px auto;
+`;
+
+const Form = styled.form`
+  display: flex;
+  flex-direction: column;
+  gap: 16px;
+  margin-top: 16px;
+`;
+
+const Secret = styled.code`
+  display: block;
+  padding: 12px;
+  margin: 12px 0;
+  border-radius: 8px;
+  background: #0f1115;
+  color: #f1f3f5;
+  word-break: break-all;
+  text-align: center;
+`;
+
+const BackupCodes = styled.ul`
+  display: grid;
+  grid-template-columns: repeat(2, 1fr);
+  gap: 8px;
+  padding: 0;
+  margin: 16px 0 24px;
+  list-style: none;
+  font-family: monospace;
+  font-size: 16px;
+  color: #f1f3f5;
+`;
+
+function MFAPageInner() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const challenge = searchParams.get("challenge") ?? "";
+  // enroll=1: роль требует второй фактор, а он еще не подключен
+  const enroll = searchParams.get("enroll") === "1";
+
+  const [enrollment, setEnrollment] = useState<MFAEnrollment | null>(null);
+  const [code, setCode] = useState("");
+  const [error, setError] = useState<string | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+  const [backupCodes, setBackupCodes] = useState<string[] | null>(null);
+  const [returnTo, setReturnTo] = useState("/servers");
+
+  useEffect(() => {
+    if (!challenge) {
+      router.push("/login?error=missing_challenge");
+      return;
+    }
+    if (!enroll) {
+      return;
+    }
+    api
+      .post<MFAEnrollment>("/mfa/enroll", { challenge })
+      .then(setEnrollment)
+      .catch((err: Error) => setError(err.message));
+  }, [challenge, enroll, router]);
+
+  const handleSubmit = async (e: FormEvent) => {
+    e.preventDefault();
+    setSubmitting(true);
+    setError(null);
+    try {
+      const login = await api.post<MFALoginResponse>("/mfa/verify", {
+        challenge,
+        code,
+      });
+      saveTokens(login.accessToken, login.refreshToken);
+      const next = safeReturnTo(login.returnTo ?? null) ?? "/servers";
+      if (login.backupCodes?.length) {
+        // Резервные коды показываются один раз: уходим со страницы только по кнопке
+        setReturnTo(next);
+        setBackupCodes(login.backupCodes);
+        return;
+      }
+      router.push(next);
+    } catch (err) {
+      setError((err as Error).message);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  if (backupCodes) {
+    return (
+      <MFACard>
+        <CardTitle>Резервные коды</CardTitle>
+        <CardContent>
+          Сохраните коды в надежном месте. Каждый можно использовать один раз
+          вместо кода из приложения, если телефон будет недоступен. Больше они
+          показаны не будут.
+          <BackupCodes>
+            {backupCodes.map((c) => (
+              <li key={c}>{c}</li>
+            ))}
+          </BackupCodes>
+          <Button fullWidth onClick={() => router.push(returnTo)}>
+            Я сохранил коды
+          </Button>
+        </CardContent>
+      </MFACard>
+    );
+  }
+
+  return (
+    <MFACard>
+      <CardTitle>Двухфакторная аутентификация</CardTitle>
+      <CardContent>
+        {enroll ? (
+          <>
+            Для вашей роли обязателен второй фактор. Добавьте аккаунт в
+            приложение-аутентификатор (Google Authenticator, Aegis, 1Password
+            и т. п.) по ссылке или введите ключ вручную, затем введите код из
+            приложения.
+            {enrollment && (
+              <>
+                <Secret>{enrollment.secret}</Secret>
+                <a href={enrollment.otpauthUri}>Открыть в приложении</a>
+              </>
+            )}
+          </>
+        ) : (
+          <>Введите код из приложения-аутентификатора или резервный код.</>
+        )}
+        <Form onSubmit={(e) => void handleSubmit(e)}>
+          <Input
+            value={code}
+            onChange={(e) => setCode(e.target.value)}
+            autoComplete="one-time-code"
+            inputMode="numeric"
+            placeholder="123456"
+            autoFocus
+            required
+          />
+          <Button type="submit" fullWidth disabled={submitting || !code}>
+            Подтвердить
+          </Button>
+        </Form>
+        {error && <ErrorMessage>{error}</ErrorMessage>}
+      </CardContent>
+    </MFACard>
+  );
+}
+
+export default function MFAPage() {
+  return (
+    <PageContainer>
+      <Container>
+        <Suspense fallback={null}>
+          <MFAPageInner />
+        </Suspense>
+      </Container>
+    </PageContainer>
+  );
+}
@@ -32,10 +32,20 @@ export function useLogout() {
   });
 }
 
+// useLogin уводит на вход через Discord; returnTo — страница фронтенда, куда вернуться после входа
 export function useLogin() {
-  return () => {
+  return (returnTo?: string | null) => {
     const apiUrl = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
-    window.location.href = `${apiUrl}/login`;
+    const query = returnTo ? `?return_to=${encodeURIComponent(returnTo)}` : "";
+    window.location.href = `${apiUrl}/login${query}`;
   };
 }
 
+// saveTokens сохраняет токены после входа; в cookie-режиме refresh token приходит HttpOnly cookie
+export function saveTokens(accessToken: string, refreshToken?: string) {
+  Cookies.set("accessToken", accessToken, { expires: 7 });
+  if (refreshToken) {
+    Cookies.set("refreshToken", refreshToken, { expires: 30 });
+  }
+}
+
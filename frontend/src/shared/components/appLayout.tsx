@@ -39,7 +39,8 @@ interface AppLayoutProps {
 export function AppLayout({ children }: AppLayoutProps) {
   const { isOpen, toggleSidebar } = useSidebar();
   const pathname = usePathname();
-  const isLoginPage = pathname === "/login";
+  // Пока вход не завершен (второй фактор), навигация по приложению не нужна
+  const isLoginPage = pathname === "/login" || pathname === "/mfa";
 
   return (
     <AppContainer>
@@ -0,0 +1,33 @@
+import styled from "@emotion/styled";
+import { describeScope } from "@/shared/lib/scopes";
+
+const List = styled.ul`
+  margin: 12px 0 24px;
+  padding-left: 20px;
+  color: #f1f3f5;
+
+  li + li {
+    margin-top: 6px;
+  }
+`;
+
+const Admin = styled.span`
+  color: #ff6b6b;
+`;
+
+// ScopeList — что получит приложение или устройство после подтверждения
+export function ScopeList({ scopes }: { scopes: string[] }) {
+  return (
+    <List>
+      {scopes.map((scope) => (
+        <li key={scope}>
+          {scope.startsWith("admin:") ? (
+            <Admin>{describeScope(scope)}</Admin>
+          ) : (
+            describeScope(scope)
+          )}
+        </li>
+      ))}
+    </List>
+  );
+}
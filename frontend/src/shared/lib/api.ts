@@ -64,11 +64,14 @@ async function request<T>(
   });
 
   if (!response.ok) {
-    if (response.status === 401) {
+    // 401 на публичном маршруте (например, неверный код 2FA) — не истекшая сессия
+    if (response.status === 401 && requiresAuth) {
       Cookies.remove("accessToken");
       Cookies.remove("refreshToken");
       if (typeof window !== "undefined") {
-        window.location.href = "/login";
+        const returnTo = window.location.pathname + window.location.search;
+        window.location.href =
+          "/login?return_to=" + encodeURIComponent(returnTo);
       }
     }
     const errorPayload = (await response
@@ -77,6 +80,8 @@ async function request<T>(
     const errorMessage =
       isPlainObject(errorPayload) && typeof errorPayload.message === "string"
         ? errorPayload.message
+        : isPlainObject(errorPayload) && typeof errorPayload.error === "string"
+        ? errorPayload.error
         : response.statusText;
     throw new Error(errorMessage || "API request failed");
   }
@@ -91,6 +96,14 @@ async function request<T>(
   return camelCaseKeys(json as T);
 }
 
+// safeReturnTo пропускает только относительные пути фронтенда
+export function safeReturnTo(returnTo: string | null): string | null {
+  if (!returnTo || !returnTo.startsWith("/") || returnTo.startsWith("//")) {
+    return null;
+  }
+  return returnTo;
+}
+
 export const api = {
   get: <T>(endpoint: string, options?: RequestOptions) =>
     request<T>(endpoint, { ...options, method: "GET" }),
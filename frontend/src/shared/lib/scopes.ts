@@ -0,0 +1,15 @@
+// Описания scope для страниц подтверждения входа приложений и устройств
+const scopeDescriptions: Record<string, string> = {
+  openid: "Подтвердить, кто вы",
+  profile: "Имя и аватар профиля",
+  email: "Адрес электронной почты",
+  "characters:read": "Просмотр ваших персонажей",
+  "characters:write": "Создание и изменение ваших персонажей",
+  account: "Управление сессиями, привязанными аккаунтами и API-ключами",
+  "admin:users": "Управление пользователями (админка)",
+  "admin:system": "Управление ключами подписи и приложениями (админка)",
+};
+
+export function describeScope(scope: string): string {
+  return scopeDescriptions[scope] ?? scope;
+}
@@ -21,3 +21,41 @@ export interface AuthState {
   isAuthenticated: boolean;
   isLoading: boolean;
 }
+
+// Ответ POST /mfa/enroll: секрет для приложения-аутентификатора
+export interface MFAEnrollment {
+  secret: string;
+  otpauthUri: string;
+}
+
+// Ответ POST /mfa/verify; backupCodes — только если второй фактор подключался при этом входе
+export interface MFALoginResponse {
+  accessToken: string;
+  refreshToken?: string;
+  returnTo?: string;
+  backupCodes?: string[];
+}
+
+// Ответ GET /device/requests/:code: устройство, которое просит вход
+export interface DeviceRequest {
+  userCode: string;
+  clientId: string;
+  clientName: string;
+  scopes: string[];
+  expiresAt: string;
+}
+
+// Ответ GET /authorize/requests/:id: приложение, которое просит вход через наш аккаунт
+export interface AuthorizationRequest {
+  id: string;
+  clientId: string;
+  clientName: string;
+  redirectUri: string;
+  scopes: string[];
+  expiresAt: string;
+}
+
+// Ответ на подтверждение или отказ: куда вернуть пользователя в приложение
+export interface AuthorizationDecision {
+  redirectUrl: string;
+}
@@ -0,0 +1,24 @@
+import styled from "@emotion/styled";
+
+export const Input = styled.input`
+  width: 100%;
+  padding: 12px 16px;
+  font-size: 18px;
+  border-radius: 8px;
+  border: 1px solid #343a40;
+  background: #0f1115;
+  color: #f1f3f5;
+  letter-spacing: 0.1em;
+  text-align: center;
+
+  &:focus {
+    outline: none;
+    border-color: #5562e0;
+  }
+`;
+
+export const ErrorMessage = styled.p`
+  margin: 12px 0 0;
+  color: #ff6b6b;
+  font-size: 14px;
+`;